@@ -0,0 +1,205 @@
+/*
+Copyright 2021 Giant Swarm.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterMigrationPhase is a step of the migration of a single cluster.
+type ClusterMigrationPhase string
+
+const (
+	// ClusterMigrationPhasePending means migration has not started yet.
+	ClusterMigrationPhasePending = ClusterMigrationPhase("Pending")
+	// ClusterMigrationPhaseValidating means pre-flight checks are running.
+	// Nothing has been changed yet.
+	ClusterMigrationPhaseValidating = ClusterMigrationPhase("Validating")
+	// ClusterMigrationPhasePreparing means missing CRs are being created and
+	// existing CRs are being transformed.
+	ClusterMigrationPhasePreparing = ClusterMigrationPhase("Preparing")
+	// ClusterMigrationPhasePrepared means all CRs are in place, but
+	// reconciliation has not been handed over to upstream controllers yet.
+	ClusterMigrationPhasePrepared = ClusterMigrationPhase("Prepared")
+	// ClusterMigrationPhaseTriggered means reconciliation has been handed over
+	// to upstream controllers.
+	ClusterMigrationPhaseTriggered = ClusterMigrationPhase("Triggered")
+	// ClusterMigrationPhaseWaitingForControlPlane means upstream controllers
+	// are bringing up the new control plane.
+	ClusterMigrationPhaseWaitingForControlPlane = ClusterMigrationPhase("WaitingForControlPlane")
+	// ClusterMigrationPhaseCleaningUp means legacy resources are being
+	// removed.
+	ClusterMigrationPhaseCleaningUp = ClusterMigrationPhase("CleaningUp")
+	// ClusterMigrationPhaseCompleted means migration is done.
+	ClusterMigrationPhaseCompleted = ClusterMigrationPhase("Completed")
+	// ClusterMigrationPhaseRollingBack means the cluster is being returned to
+	// the legacy operators.
+	ClusterMigrationPhaseRollingBack = ClusterMigrationPhase("RollingBack")
+	// ClusterMigrationPhaseRolledBack means the cluster has been returned to
+	// the legacy operators and is not migrated anymore.
+	ClusterMigrationPhaseRolledBack = ClusterMigrationPhase("RolledBack")
+	// ClusterMigrationPhaseFailed means the last migration step failed. The
+	// phase in which it happened is kept in FailedPhase.
+	ClusterMigrationPhaseFailed = ClusterMigrationPhase("Failed")
+)
+
+// ClusterMigrationFindingSeverity tells whether a validation finding blocks
+// the migration.
+type ClusterMigrationFindingSeverity string
+
+const (
+	// ClusterMigrationFindingSeverityBlocking means migration can't start
+	// until the finding is resolved.
+	ClusterMigrationFindingSeverityBlocking = ClusterMigrationFindingSeverity("Blocking")
+	// ClusterMigrationFindingSeverityWarning means migration can start, but
+	// the finding should be looked at.
+	ClusterMigrationFindingSeverityWarning = ClusterMigrationFindingSeverity("Warning")
+)
+
+// ClusterMigrationSpec defines the desired state of ClusterMigration
+type ClusterMigrationSpec struct {
+	// ClusterName is the name of the migrated Cluster CR.
+	ClusterName string `json:"clusterName"`
+
+	// Provider is the name of the provider which migrates the cluster.
+	// +optional
+	Provider string `json:"provider,omitempty"`
+}
+
+// ClusterMigrationStatus defines the observed state of ClusterMigration
+type ClusterMigrationStatus struct {
+	// Phase is the current migration phase.
+	// +optional
+	Phase ClusterMigrationPhase `json:"phase,omitempty"`
+
+	// Attempt is the number of the current migration attempt. It is
+	// increased every time migration starts from Pending or RolledBack
+	// phase. Backups of the original CRs are kept per attempt.
+	// +optional
+	Attempt int `json:"attempt,omitempty"`
+
+	// FailedPhase is the phase in which the last error happened. Set only
+	// when Phase is Failed.
+	// +optional
+	FailedPhase ClusterMigrationPhase `json:"failedPhase,omitempty"`
+
+	// LastError is the message of the last error which happened during
+	// migration.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// StartedAt is the time when migration left Pending phase.
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// CompletedAt is the time when migration reached Completed phase.
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// LastTransitionTime is the time of the last phase change.
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Findings lists results of the last pre-flight validation.
+	// +optional
+	Findings []ClusterMigrationFinding `json:"findings,omitempty"`
+
+	// ValidatedAt is the time of the last pre-flight validation.
+	// +optional
+	ValidatedAt *metav1.Time `json:"validatedAt,omitempty"`
+
+	// Mutations lists changes the migrator applied to existing CRs.
+	// +optional
+	Mutations []ClusterMigrationMutation `json:"mutations,omitempty"`
+}
+
+// ClusterMigrationFinding is a problem found by pre-flight validation.
+type ClusterMigrationFinding struct {
+	// Severity tells whether the finding blocks the migration.
+	Severity ClusterMigrationFindingSeverity `json:"severity"`
+
+	// Check is the name of the check which produced the finding.
+	Check string `json:"check"`
+
+	// Message describes the finding.
+	Message string `json:"message"`
+}
+
+// ClusterMigrationMutation is a change applied by the migrator to an existing
+// CR.
+type ClusterMigrationMutation struct {
+	// Kind is the kind of the mutated CR.
+	Kind string `json:"kind"`
+
+	// Name is the name of the mutated CR.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the mutated CR.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Patch is a RFC 6902 JSON patch transforming the CR as it was before
+	// the mutation into the mutated CR. It can be used to revert the
+	// mutation by hand.
+	Patch string `json:"patch"`
+
+	// Time is when the mutation was applied.
+	Time metav1.Time `json:"time"`
+}
+
+// EffectivePhase returns the phase the migration is in, ignoring failure.
+// For failed migrations this is the phase in which the failure happened.
+func (s ClusterMigrationStatus) EffectivePhase() ClusterMigrationPhase {
+	if s.Phase == ClusterMigrationPhaseFailed {
+		return s.FailedPhase
+	}
+	if s.Phase == "" {
+		return ClusterMigrationPhasePending
+	}
+
+	return s.Phase
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName"
+// +kubebuilder:printcolumn:name="Provider",type="string",JSONPath=".spec.provider"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Attempt",type="integer",JSONPath=".status.attempt"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterMigration is the Schema for the clustermigrations API
+type ClusterMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterMigrationSpec   `json:"spec,omitempty"`
+	Status ClusterMigrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterMigrationList contains a list of ClusterMigration
+type ClusterMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterMigration{}, &ClusterMigrationList{})
+}
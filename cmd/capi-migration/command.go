@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/migration"
+)
+
+func newPlanCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "plan",
+		Short: "Render all changes of the migration without applying them.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, true)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			err = r.run(ctx, func(ctx context.Context) error {
+				err := r.migrator.Validate(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				err = r.migrator.Prepare(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				return r.migrator.TriggerMigration(ctx)
+			})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			plan, err := migration.GetDryRunPlan(ctx, r.client, ctrl.ObjectKey{Namespace: r.cluster.Namespace, Name: r.cluster.Name})
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			fmt.Fprint(cmd.OutOrStdout(), plan)
+
+			return nil
+		},
+	}
+}
+
+func newValidateCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Run pre-flight checks and print their findings.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			validateErr := r.run(ctx, r.migrator.Validate)
+
+			cm, err := r.getClusterMigration(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			printFindings(cmd.OutOrStdout(), cm.Status.Findings)
+
+			if validateErr != nil {
+				return microerror.Mask(validateErr)
+			}
+
+			return nil
+		},
+	}
+}
+
+func newPrepareCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "prepare",
+		Short: "Validate the cluster and create CRs for upstream controllers.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return r.run(ctx, func(ctx context.Context) error {
+				err := r.migrator.Validate(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				err = r.addFinalizer(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				return r.migrator.Prepare(ctx)
+			})
+		},
+	}
+}
+
+func newTriggerCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trigger",
+		Short: "Hand over reconciliation of a prepared cluster to upstream controllers.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return r.run(ctx, r.migrator.TriggerMigration)
+		},
+	}
+}
+
+func newCleanupCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete legacy resources of a migrated cluster.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return r.run(ctx, func(ctx context.Context) error {
+				err := r.migrator.Cleanup(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				return r.removeFinalizer(ctx)
+			})
+		},
+	}
+}
+
+func newRollbackCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback",
+		Short: "Return a cluster which has not been cleaned up yet to legacy operators.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return r.run(ctx, func(ctx context.Context) error {
+				err := r.migrator.Rollback(ctx)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+
+				return r.removeFinalizer(ctx)
+			})
+		},
+	}
+}
+
+func newRestoreCommand(f *flags) *cobra.Command {
+	var attempt int
+
+	c := &cobra.Command{
+		Use:   "restore",
+		Short: "Write CRs and secrets stored in the backup of a migration attempt back.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if attempt < 1 {
+				return microerror.Maskf(invalidFlagError, "--%s must be a migration attempt number greater than 0", flagAttempt)
+			}
+
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return r.run(ctx, func(ctx context.Context) error {
+				return migration.RestoreBackup(ctx, r.client, ctrl.ObjectKey{Namespace: r.cluster.Namespace, Name: r.cluster.Name}, attempt)
+			})
+		},
+	}
+
+	c.Flags().IntVar(&attempt, flagAttempt, 0, "Migration attempt whose backup is restored, see .status.attempt of the ClusterMigration CR.")
+
+	return c
+}
+
+func newStatusCommand(f *flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Print migration progress of the cluster.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			r, err := newRunner(ctx, f, false)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			migrating, err := r.migrator.IsMigrating(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			migrated, err := r.migrator.IsMigrated(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			cm, err := r.getClusterMigration(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintf(w, "Cluster:\t%s/%s\n", r.cluster.Namespace, r.cluster.Name)
+			fmt.Fprintf(w, "Provider:\t%s\n", cm.Spec.Provider)
+			fmt.Fprintf(w, "Phase:\t%s\n", cm.Status.Phase)
+			if cm.Status.FailedPhase != "" {
+				fmt.Fprintf(w, "Failed phase:\t%s\n", cm.Status.FailedPhase)
+			}
+			if cm.Status.LastError != "" {
+				fmt.Fprintf(w, "Last error:\t%s\n", cm.Status.LastError)
+			}
+			fmt.Fprintf(w, "Attempt:\t%d\n", cm.Status.Attempt)
+			fmt.Fprintf(w, "Started:\t%s\n", formatTime(cm.Status.StartedAt))
+			fmt.Fprintf(w, "Completed:\t%s\n", formatTime(cm.Status.CompletedAt))
+			fmt.Fprintf(w, "Migrating:\t%t\n", migrating)
+			fmt.Fprintf(w, "Migrated:\t%t\n", migrated)
+			fmt.Fprintf(w, "Mutations:\t%d\n", len(cm.Status.Mutations))
+			err = w.Flush()
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			if len(cm.Status.Findings) > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "\nFindings of validation at %s:\n", formatTime(cm.Status.ValidatedAt))
+				printFindings(cmd.OutOrStdout(), cm.Status.Findings)
+			}
+
+			return nil
+		},
+	}
+}
+
+func printFindings(out io.Writer, findings []migrationv1alpha1.ClusterMigrationFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "No findings.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tCHECK\tMESSAGE")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Severity, f.Check, f.Message)
+	}
+	_ = w.Flush()
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/micrologger/microloggertest"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+// testTenantCluster returns the same REST config for every workload cluster.
+type testTenantCluster struct {
+	config *rest.Config
+}
+
+func (t *testTenantCluster) NewRestConfig(ctx context.Context, clusterID, apiDomain string) (*rest.Config, error) {
+	return rest.CopyConfig(t.config), nil
+}
+
+// newWorkloadAPI serves API discovery of an empty workload cluster, which is
+// all migrators need to be created.
+func newWorkloadAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	responses := map[string]string{
+		"/api":    `{"kind":"APIVersions","versions":["v1"]}`,
+		"/apis":   `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`,
+		"/api/v1": `{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func Test_triggerCommand(t *testing.T) {
+	ctx := context.Background()
+
+	clusterLabels := map[string]string{
+		capi.ClusterLabelName: "abc12",
+		label.ReleaseVersion:  "14.1.0",
+	}
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "default", Labels: clusterLabels}
+	}
+
+	// Cluster prepared by an earlier "prepare" run.
+	objs := []runtime.Object{
+		&capi.Cluster{
+			ObjectMeta: objectMeta("abc12"),
+			Spec: capi.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: giantswarmawsalpha3.SchemeGroupVersion.String(),
+					Kind:       "AWSCluster",
+					Name:       "abc12",
+					Namespace:  "default",
+				},
+			},
+		},
+		&corev1.Secret{ObjectMeta: objectMeta("abc12-encryption")},
+		&giantswarmawsalpha3.AWSCluster{ObjectMeta: objectMeta("abc12")},
+		&giantswarmawsalpha3.AWSControlPlane{ObjectMeta: objectMeta("a0b1c")},
+		&giantswarmawsalpha3.G8sControlPlane{ObjectMeta: objectMeta("a0b1c")},
+		&giantswarmawsalpha3.AWSMachineDeployment{ObjectMeta: objectMeta("d3e4f")},
+		&release.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: "v14.1.0"},
+			Spec: release.ReleaseSpec{
+				Components: []release.ReleaseSpecComponent{
+					{Name: "cluster-api-bootstrap-provider-kubeadm", Version: "0.3.13"},
+					{Name: "cluster-api-control-plane", Version: "0.3.13"},
+					{Name: "cluster-api-core", Version: "0.3.13"},
+					{Name: "cluster-api-provider-aws", Version: "0.6.5"},
+					{Name: "cluster-operator", Version: "3.6.0"},
+				},
+			},
+		},
+		&kubeadm.KubeadmControlPlane{ObjectMeta: objectMeta("abc12-control-plane")},
+		&capa.AWSMachineTemplate{ObjectMeta: objectMeta("abc12-control-plane")},
+		&bootstrap.KubeadmConfig{ObjectMeta: objectMeta("abc12-worker-d3e4f")},
+		&capaexp.AWSMachinePool{ObjectMeta: objectMeta("abc12-worker-d3e4f")},
+		&capiexp.MachinePool{ObjectMeta: objectMeta("abc12-worker-d3e4f")},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	workloadAPI := newWorkloadAPI(t)
+	defer workloadAPI.Close()
+
+	vaultClient, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	defer func(f func(*flags) (runnerConfig, error)) { newRunnerConfig = f }(newRunnerConfig)
+	newRunnerConfig = func(f *flags) (runnerConfig, error) {
+		return runnerConfig{
+			CtrlClient:    c,
+			Logger:        microloggertest.New(),
+			TenantCluster: &testTenantCluster{config: &rest.Config{Host: workloadAPI.URL}},
+			VaultClient:   vaultClient,
+
+			EventRecorder: record.NewFakeRecorder(10),
+		}, nil
+	}
+
+	cmd := newRootCommand()
+	cmd.SetArgs([]string{
+		"trigger",
+		"--cluster", "abc12",
+		"--ssh-disabled",
+		"--vault-addr", "https://vault.example.com",
+		"--vault-token", "token",
+		"--aws-access-id", "id",
+		"--aws-access-secret", "secret",
+	})
+
+	err = cmd.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	cm := &migrationv1alpha1.ClusterMigration{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12"}, cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if cm.Status.Phase != migrationv1alpha1.ClusterMigrationPhaseTriggered {
+		t.Fatalf("phase = %q, want %q", cm.Status.Phase, migrationv1alpha1.ClusterMigrationPhaseTriggered)
+	}
+
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-control-plane"}, kcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if kcp.Labels[label.ReleaseVersion] != "v14.1.0" {
+		t.Fatalf("expected KubeadmControlPlane to be handed over, got labels %#v", kcp.Labels)
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/giantswarm/microerror"
+
+var invalidFlagError = &microerror.Error{
+	Kind: "invalidFlagError",
+}
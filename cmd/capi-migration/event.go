@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+
+	"github.com/giantswarm/capi-migration/pkg/project"
+)
+
+// eventRecorder creates events right away. Recorders of
+// record.EventBroadcaster send events in the background, so events of the
+// last step would be lost when the CLI exits, and sending them after the
+// broadcaster is shut down panics.
+type eventRecorder struct {
+	client typedcorev1.EventsGetter
+	logger micrologger.Logger
+}
+
+func newEventRecorder(client typedcorev1.EventsGetter, logger micrologger.Logger) record.EventRecorder {
+	return &eventRecorder{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (r *eventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+func (r *eventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+func (r *eventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	ctx := context.Background()
+
+	ref, err := reference.GetReference(scheme, object)
+	if err != nil {
+		r.logger.Errorf(ctx, err, "failed to reference object of event %#q", reason)
+		return
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	t := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%v.%x", ref.Name, t.UnixNano()),
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        fmt.Sprintf(messageFmt, args...),
+		FirstTimestamp: t,
+		LastTimestamp:  t,
+		Count:          1,
+		Type:           eventtype,
+		Source:         corev1.EventSource{Component: project.Name() + "-cli"},
+	}
+
+	_, err = r.client.Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		r.logger.Errorf(ctx, err, "failed to create event %#q", reason)
+	}
+}
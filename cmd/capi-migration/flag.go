@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration"
+)
+
+const (
+	flagAttempt            = "attempt"
+	flagCluster            = "cluster"
+	flagKubeconfig         = "kubeconfig"
+	flagNamespace          = "namespace"
+	flagSSHAWSKeyPair      = "ssh-aws-key-pair"
+	flagSSHAzurePublicKey  = "ssh-azure-public-key"
+	flagSSHConfigMap       = "ssh-config-map"
+	flagSSHDisabled        = "ssh-disabled"
+	flagSSHUser            = "ssh-user"
+	flagTemplatesConfigMap = "templates-config-map"
+	flagVaultAddr          = "vault-addr"
+	flagVaultToken         = "vault-token"
+)
+
+type flags struct {
+	Cluster            string
+	Kubeconfig         string
+	Namespace          string
+	SSHAWSKeyPair      string
+	SSHAzurePublicKey  string
+	SSHConfigMap       string
+	SSHDisabled        bool
+	SSHUsers           []string
+	TemplatesConfigMap string
+	VaultAddr          string
+	VaultToken         string
+
+	// Parsed from SSH flags.
+	ssh             migration.SSHConfig
+	sshConfigMapKey ctrl.ObjectKey
+
+	// Parsed from templates flag.
+	templatesConfigMapKey ctrl.ObjectKey
+}
+
+func (f *flags) init(c *cobra.Command) {
+	c.PersistentFlags().StringVar(&f.Cluster, flagCluster, "", "ID of the cluster to migrate.")
+	c.PersistentFlags().StringVar(&f.Kubeconfig, flagKubeconfig, "", "Kubeconfig of the management cluster. Defaults to KUBECONFIG or ~/.kube/config.")
+	c.PersistentFlags().StringVar(&f.Namespace, flagNamespace, "default", "Namespace of the Cluster CR.")
+	c.PersistentFlags().StringVar(&f.SSHAWSKeyPair, flagSSHAWSKeyPair, "", "EC2 key pair name set on AWS machines.")
+	c.PersistentFlags().StringVar(&f.SSHAzurePublicKey, flagSSHAzurePublicKey, "", "Base64 encoded SSH public key set on Azure machines.")
+	c.PersistentFlags().StringVar(&f.SSHConfigMap, flagSSHConfigMap, "", "ConfigMap in \"namespace/name\" form with SSH access policy overriding SSH flags.")
+	c.PersistentFlags().BoolVar(&f.SSHDisabled, flagSSHDisabled, false, "Disable SSH access to machines of the migrated cluster.")
+	c.PersistentFlags().StringArrayVar(&f.SSHUsers, flagSSHUser, nil, "SSH user in \"name=authorized-key\" form. Can be given multiple times.")
+	c.PersistentFlags().StringVar(&f.TemplatesConfigMap, flagTemplatesConfigMap, "", "ConfigMap in \"namespace/name\" form with CR templates overriding embedded ones.")
+	c.PersistentFlags().StringVar(&f.VaultAddr, flagVaultAddr, "", "The address of the vault to connect to. Defaults to VAULT_ADDR.")
+	c.PersistentFlags().StringVar(&f.VaultToken, flagVaultToken, "", "The token to use to authenticate to vault. Defaults to VAULT_TOKEN.")
+
+	// Provider specific flags, e.g. AWS credentials. The provider itself is
+	// picked by infrastructureRef kind of the cluster.
+	migration.InitProviderFlags(c.PersistentFlags())
+}
+
+func (f *flags) validate() error {
+	if f.Cluster == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagCluster)
+	}
+	if f.Namespace == "" {
+		return microerror.Maskf(invalidFlagError, "--%s must not be empty", flagNamespace)
+	}
+
+	if f.VaultAddr == "" {
+		f.VaultAddr = os.Getenv("VAULT_ADDR")
+	}
+	if f.VaultToken == "" {
+		f.VaultToken = os.Getenv("VAULT_TOKEN")
+	}
+	if f.VaultAddr == "" || f.VaultToken == "" {
+		return microerror.Maskf(invalidFlagError, "--%s and --%s or VAULT_ADDR and VAULT_TOKEN environment variables must be set", flagVaultAddr, flagVaultToken)
+	}
+
+	users, err := migration.ParseSSHUsers(f.SSHUsers)
+	if err != nil {
+		return microerror.Maskf(invalidFlagError, "--%s: %s", flagSSHUser, microerror.Pretty(err, false))
+	}
+	f.ssh = migration.SSHConfig{
+		Disabled:          f.SSHDisabled,
+		Users:             users,
+		AWSKeyPairName:    f.SSHAWSKeyPair,
+		AzureSSHPublicKey: f.SSHAzurePublicKey,
+	}
+
+	f.sshConfigMapKey, err = migration.ParseSSHConfigMapKey(f.SSHConfigMap)
+	if err != nil {
+		return microerror.Maskf(invalidFlagError, "--%s: %s", flagSSHConfigMap, microerror.Pretty(err, false))
+	}
+
+	f.templatesConfigMapKey, err = migration.ParseTemplatesConfigMapKey(f.TemplatesConfigMap)
+	if err != nil {
+		return microerror.Maskf(invalidFlagError, "--%s: %s", flagTemplatesConfigMap, microerror.Pretty(err, false))
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// capi-migration runs single migration steps against one cluster outside of
+// the controller. It uses the same migrators as the controller, so it can be
+// used to step through a migration by hand or to reproduce a controller
+// failure locally.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	releasev1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapkubeadmv1alpha3 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	controlplanekubeadmv1alpha3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	expcapiv1alpha3 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/migration"
+	"github.com/giantswarm/capi-migration/pkg/project"
+)
+
+var (
+	scheme = runtime.NewScheme()
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	_ = capiv1alpha3.AddToScheme(scheme)
+	_ = expcapiv1alpha3.AddToScheme(scheme)
+	_ = releasev1alpha1.AddToScheme(scheme)
+	_ = bootstrapkubeadmv1alpha3.AddToScheme(scheme)
+	_ = controlplanekubeadmv1alpha3.AddToScheme(scheme)
+	_ = migrationv1alpha1.AddToScheme(scheme)
+	_ = migration.AddProvidersToScheme(scheme)
+}
+
+func main() {
+	err := newRootCommand().Execute()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", microerror.Pretty(err, true))
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	f := &flags{}
+
+	c := &cobra.Command{
+		Use:           project.Name(),
+		Short:         "Run migration steps for a single cluster outside of the controller.",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return f.validate()
+		},
+	}
+
+	f.init(c)
+
+	c.AddCommand(
+		newPlanCommand(f),
+		newValidateCommand(f),
+		newPrepareCommand(f),
+		newTriggerCommand(f),
+		newCleanupCommand(f),
+		newStatusCommand(f),
+		newRollbackCommand(f),
+		newRestoreCommand(f),
+	)
+
+	return c
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/giantswarm/certs/v3/pkg/certs"
+	"github.com/giantswarm/k8sclient/v4/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
+	vaultapi "github.com/hashicorp/vault/api"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/meta"
+	"github.com/giantswarm/capi-migration/pkg/migration"
+	"github.com/giantswarm/capi-migration/pkg/vault"
+)
+
+// runner holds clients and the migrator of the cluster given by flags.
+type runner struct {
+	cluster  *capi.Cluster
+	client   ctrl.Client
+	logger   micrologger.Logger
+	migrator migration.Migrator
+}
+
+// runnerConfig holds clients of the management cluster and Vault. They are
+// created from flags by newRunnerConfig.
+type runnerConfig struct {
+	CtrlClient    ctrl.Client
+	Logger        micrologger.Logger
+	TenantCluster tenantcluster.Interface
+	VaultClient   *vaultapi.Client
+
+	// EventRecorder emits events on the Cluster CR like when the controller
+	// runs the migration.
+	EventRecorder record.EventRecorder
+}
+
+// newRunnerConfig is a variable, so that tests can run commands against fake
+// clients.
+var newRunnerConfig = newRunnerConfigFromFlags
+
+func newRunnerConfigFromFlags(f *flags) (runnerConfig, error) {
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		return runnerConfig{}, microerror.Mask(err)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = f.Kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return runnerConfig{}, microerror.Mask(err)
+	}
+
+	k8sClients, err := k8sclient.NewClients(k8sclient.ClientsConfig{
+		Logger:     logger,
+		RestConfig: restConfig,
+	})
+	if err != nil {
+		return runnerConfig{}, microerror.Mask(err)
+	}
+
+	ctrlClient, err := ctrl.New(restConfig, ctrl.Options{Scheme: scheme})
+	if err != nil {
+		return runnerConfig{}, microerror.Mask(err)
+	}
+
+	var tenantCluster *tenantcluster.TenantCluster
+	{
+		certsSearcher, err := certs.NewSearcher(certs.Config{
+			K8sClient: k8sClients.K8sClient(),
+			Logger:    logger,
+
+			WatchTimeout: 30 * time.Second,
+		})
+		if err != nil {
+			return runnerConfig{}, microerror.Mask(err)
+		}
+
+		tenantCluster, err = tenantcluster.New(tenantcluster.Config{
+			CertsSearcher: certsSearcher,
+			Logger:        logger,
+			CertID:        certs.APICert,
+		})
+		if err != nil {
+			return runnerConfig{}, microerror.Mask(err)
+		}
+	}
+
+	// The CLI exits before a token would need renewal, so the client is
+	// not started.
+	vaultClient, err := vault.New(vault.Config{
+		Logger: logger,
+
+		Address: f.VaultAddr,
+		Token:   f.VaultToken,
+	})
+	if err != nil {
+		return runnerConfig{}, microerror.Mask(err)
+	}
+
+	c := runnerConfig{
+		CtrlClient:    ctrlClient,
+		Logger:        logger,
+		TenantCluster: tenantCluster,
+		VaultClient:   vaultClient.API(),
+
+		EventRecorder: newEventRecorder(k8sClients.K8sClient().CoreV1(), logger),
+	}
+
+	return c, nil
+}
+
+func newRunner(ctx context.Context, f *flags, dryRun bool) (*runner, error) {
+	c, err := newRunnerConfig(f)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	cluster := &capi.Cluster{}
+	err = c.CtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: f.Namespace, Name: f.Cluster}, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	provider, err := migration.ClusterProvider(cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Only the provider of the cluster is enabled, so flags of other
+	// providers don't have to be set.
+	migratorFactory, err := migration.NewProviderSelector(migration.ProviderConfig{
+		CtrlClient:    c.CtrlClient,
+		EventRecorder: c.EventRecorder,
+		Logger:        c.Logger,
+		Scheme:        scheme,
+		TenantCluster: c.TenantCluster,
+		VaultClient:   c.VaultClient,
+
+		DryRun:       dryRun,
+		SSH:          f.ssh,
+		SSHConfigMap: f.sshConfigMapKey,
+
+		TemplatesConfigMap: f.templatesConfigMapKey,
+	}, []string{provider})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	_, err = migration.EnsureClusterMigration(ctx, c.CtrlClient, scheme, cluster, provider)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	migrator, err := migratorFactory.NewMigrator(cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r := &runner{
+		cluster:  cluster,
+		client:   c.CtrlClient,
+		logger:   c.Logger,
+		migrator: migrator,
+	}
+
+	return r, nil
+}
+
+// run executes given migration step. Failures are recorded in the
+// ClusterMigration CR the same way the controller does.
+func (r *runner) run(ctx context.Context, step func(context.Context) error) error {
+	err := step(ctx)
+	if migration.IsWaiting(err) {
+		// Waiting for nodes or legacy resources is not a failure. The step
+		// has to be run again later.
+		return microerror.Mask(err)
+	} else if err != nil {
+		r.recordFailure(ctx, err)
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) recordFailure(ctx context.Context, cause error) {
+	cm, err := r.getClusterMigration(ctx)
+	if err == nil {
+		err = migration.SetFailed(ctx, r.client, cm, cause)
+	}
+	if err != nil {
+		r.logger.Errorf(ctx, err, "failed to record migration failure")
+	}
+}
+
+func (r *runner) getClusterMigration(ctx context.Context) (*migrationv1alpha1.ClusterMigration, error) {
+	cm := &migrationv1alpha1.ClusterMigration{}
+	err := r.client.Get(ctx, ctrl.ObjectKey{Namespace: r.cluster.Namespace, Name: r.cluster.Name}, cm)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return cm, nil
+}
+
+// addFinalizer makes sure objects created during migration are removed when
+// the cluster is deleted before the migration finishes.
+func (r *runner) addFinalizer(ctx context.Context) error {
+	if controllerutil.ContainsFinalizer(r.cluster, meta.Finalizer.Migration.Key()) {
+		return nil
+	}
+
+	controllerutil.AddFinalizer(r.cluster, meta.Finalizer.Migration.Key())
+	err := r.client.Update(ctx, r.cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// removeFinalizer re-reads the cluster, because migrators update it on their
+// own, and removes meta.Finalizer.Migration from it.
+func (r *runner) removeFinalizer(ctx context.Context) error {
+	err := r.client.Get(ctx, ctrl.ObjectKey{Namespace: r.cluster.Namespace, Name: r.cluster.Name}, r.cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !controllerutil.ContainsFinalizer(r.cluster, meta.Finalizer.Migration.Key()) {
+		return nil
+	}
+
+	controllerutil.RemoveFinalizer(r.cluster, meta.Finalizer.Migration.Key())
+	err = r.client.Update(ctx, r.cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
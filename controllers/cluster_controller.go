@@ -26,14 +26,17 @@ import (
 	"github.com/giantswarm/micrologger"
 	"github.com/giantswarm/micrologger/loggermeta"
 	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
-	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
 	"github.com/giantswarm/capi-migration/pkg/meta"
 	"github.com/giantswarm/capi-migration/pkg/migration"
 )
@@ -42,9 +45,9 @@ import (
 type ClusterReconciler struct {
 	client.Client
 	Log             micrologger.Logger
-	MigratorFactory migration.MigratorFactory
+	MigratorFactory *migration.ProviderSelector
+	Recorder        record.EventRecorder
 	TenantCluster   tenantcluster.TenantCluster
-	VaultClient     *vaultapi.Client
 	Scheme          *runtime.Scheme
 
 	loopSeq int64
@@ -52,6 +55,8 @@ type ClusterReconciler struct {
 
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cluster.x-k8s.io.giantswarm.io,resources=clusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=migration.giantswarm.io,resources=clustermigrations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=migration.giantswarm.io,resources=clustermigrations/status,verbs=get;update;patch
 
 func (r *ClusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	// TODO get context as parameter as soon as we bump sigs.k8s.io/controller-runtime to 0.7+.
@@ -103,6 +108,90 @@ func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *capiv1alpha3.Cluster) (ctrl.Result, error) {
 	r.Log.Debugf(ctx, "calling reconcile")
 
+	// Clusters of providers which are not enabled are left to other
+	// deployments.
+	provider, err := r.MigratorFactory.Provider(cluster)
+	if migration.IsProviderNotFound(err) {
+		r.Log.Debugf(ctx, "skipping cluster: %s", microerror.Pretty(err, false))
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	clusterMigration, err := migration.EnsureClusterMigration(ctx, r.Client, r.Scheme, cluster, provider)
+	if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	if clusterMigration.Status.Phase == migrationv1alpha1.ClusterMigrationPhaseCompleted {
+		r.Log.Debugf(ctx, "cluster migration is completed")
+
+		err = r.removeFinalizer(ctx, cluster)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if clusterMigration.Status.EffectivePhase() == migrationv1alpha1.ClusterMigrationPhaseRollingBack {
+		r.Log.Debugf(ctx, "cluster migration is rolling back")
+		return ctrl.Result{}, nil
+	}
+
+	// Rollback removes the migrate annotation. Setting it again starts a
+	// new migration attempt.
+	if clusterMigration.Status.Phase == migrationv1alpha1.ClusterMigrationPhaseRolledBack && !meta.Annotation.Migrate.IsSet(cluster) {
+		r.Log.Debugf(ctx, "cluster migration is rolled back")
+
+		err = r.removeFinalizer(ctx, cluster)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// Dry run doesn't move the ClusterMigration CR through phases, so the
+	// emitted plan is the only sign the cluster has been planned already.
+	if r.MigratorFactory.IsDryRun(cluster) && meta.Annotation.DryRunPlanHash.Get(clusterMigration) != "" {
+		r.Log.Debugf(ctx, "dry-run migration plan already emitted")
+		return ctrl.Result{}, nil
+	}
+
+	res, err := r.reconcileMigration(ctx, cluster)
+	if migration.IsWaiting(err) {
+		// Cleanup waits for new nodes or for legacy resources to go away.
+		// This is not a failure.
+		r.Log.Debugf(ctx, "cluster migration is waiting: %s", microerror.Pretty(err, false))
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	} else if err != nil && r.MigratorFactory.IsDryRun(cluster) {
+		// Dry run must not change the ClusterMigration CR. The failure is
+		// stored next to the plan instead, so the cluster is not planned
+		// again on every resync.
+		r.Log.Errorf(ctx, err, "dry-run migration failed")
+
+		emitErr := migration.EmitDryRunFailure(ctx, r.Client, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, err)
+		if emitErr != nil {
+			return ctrl.Result{}, microerror.Mask(emitErr)
+		}
+
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, migration.EventReasonMigrationFailed, "migration failed: %s", err)
+
+		setErr := r.setMigrationFailed(ctx, cluster, err)
+		if setErr != nil {
+			r.Log.Errorf(ctx, setErr, "failed to record migration failure")
+		}
+
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	return res, nil
+}
+
+func (r *ClusterReconciler) reconcileMigration(ctx context.Context, cluster *capiv1alpha3.Cluster) (ctrl.Result, error) {
 	migrator, err := r.MigratorFactory.NewMigrator(cluster)
 	if err != nil {
 		return ctrl.Result{}, microerror.Mask(err)
@@ -121,6 +210,8 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *capiv1alpha3
 			return ctrl.Result{}, microerror.Mask(err)
 		}
 
+		r.Recorder.Event(cluster, corev1.EventTypeNormal, migration.EventReasonMigrationCompleted, "migration completed, legacy resources deleted")
+
 		return ctrl.Result{}, nil
 	}
 
@@ -132,9 +223,52 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *capiv1alpha3
 	if migrating {
 		// Migration has been triggered but it's not complete yet.
 		r.Log.Debugf(ctx, "cluster migration is in progress")
+
+		err = r.setMigrationPhase(ctx, cluster, migrationv1alpha1.ClusterMigrationPhaseWaitingForControlPlane)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	// Migration has not started yet. Only start it when it was requested
+	// explicitly and the scheduled time has come.
+	if !meta.Annotation.Migrate.IsSet(cluster) {
+		r.Log.Debugf(ctx, "cluster migration not requested, annotation %q is not set to %q", meta.Annotation.Migrate.Key(), meta.Annotation.Migrate.Val())
 		return ctrl.Result{}, nil
 	}
 
+	notBefore, err := meta.Annotation.MigrateNotBefore.Get(cluster)
+	if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	if wait := time.Until(notBefore); wait > 0 {
+		r.Log.Debugf(ctx, "cluster migration scheduled at %s, requeuing after %s", notBefore.Format(time.RFC3339), wait.Round(time.Second))
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, migration.EventReasonMigrationScheduled, "migration scheduled at %s", notBefore.Format(time.RFC3339))
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// Nothing has been changed yet. Make sure the cluster can be migrated
+	// before touching it.
+	r.Log.Debugf(ctx, "validating cluster migration")
+	r.Recorder.Event(cluster, corev1.EventTypeNormal, migration.EventReasonMigrationStarted, "migration started, running pre-flight checks")
+	err = migrator.Validate(ctx)
+	if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	// Make sure objects created during migration are removed when the
+	// cluster is deleted before the migration finishes. Dry run creates no
+	// objects and must not change the cluster.
+	if !r.MigratorFactory.IsDryRun(cluster) {
+		err = r.addFinalizer(ctx, cluster)
+		if err != nil {
+			return ctrl.Result{}, microerror.Mask(err)
+		}
+	}
+
 	r.Log.Debugf(ctx, "preparing cluster migration")
 	err = migrator.Prepare(ctx)
 	if err != nil {
@@ -147,10 +281,117 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *capiv1alpha3
 		return ctrl.Result{}, microerror.Mask(err)
 	}
 
-	return ctrl.Result{}, nil
+	if r.MigratorFactory.IsDryRun(cluster) {
+		r.Log.Debugf(ctx, "dry-run migration plan emitted")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{RequeueAfter: time.Minute}, nil
+}
+
+// setMigrationPhase re-reads ClusterMigration CR of given cluster, because
+// migrators update it on their own, and moves it to the given phase.
+func (r *ClusterReconciler) setMigrationPhase(ctx context.Context, cluster *capiv1alpha3.Cluster, phase migrationv1alpha1.ClusterMigrationPhase) error {
+	clusterMigration := &migrationv1alpha1.ClusterMigration{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, clusterMigration)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = migration.SetPhase(ctx, r.Client, clusterMigration, phase)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *ClusterReconciler) setMigrationFailed(ctx context.Context, cluster *capiv1alpha3.Cluster, cause error) error {
+	clusterMigration := &migrationv1alpha1.ClusterMigration{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, clusterMigration)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = migration.SetFailed(ctx, r.Client, clusterMigration, cause)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
 }
 
 func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *capiv1alpha3.Cluster) (ctrl.Result, error) {
 	r.Log.Debugf(ctx, "calling reconcileDelete")
+
+	provider, err := r.MigratorFactory.Provider(cluster)
+	if err == nil {
+		migration.DeleteMetrics(cluster.Name, provider)
+	}
+
+	if !controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key()) {
+		return ctrl.Result{}, nil
+	}
+
+	r.Log.Debugf(ctx, "cluster deleted during migration, deleting objects created during migration")
+
+	// The workload cluster may be gone already. Its objects go away
+	// together with it then, so failures are not fatal.
+	migrator, err := r.MigratorFactory.NewMigrator(cluster)
+	if err == nil {
+		err = migrator.DeleteWorkloadArtifacts(ctx)
+	}
+	if err != nil {
+		r.Log.Errorf(ctx, err, "failed to delete objects created in the workload cluster, leaving them alone")
+	}
+
+	err = migration.DeleteArtifacts(ctx, r.Client, cluster)
+	if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
+	r.Log.Debugf(ctx, "deleted objects created during migration")
+	r.Recorder.Event(cluster, corev1.EventTypeNormal, migration.EventReasonArtifactsDeleted, "cluster deleted during migration, objects created during migration deleted")
+
+	err = r.removeFinalizer(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, microerror.Mask(err)
+	}
+
 	return ctrl.Result{}, nil
 }
+
+func (r *ClusterReconciler) addFinalizer(ctx context.Context, cluster *capiv1alpha3.Cluster) error {
+	if controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key()) {
+		return nil
+	}
+
+	controllerutil.AddFinalizer(cluster, meta.Finalizer.Migration.Key())
+	err := r.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// removeFinalizer re-reads given cluster, because migrators update it on
+// their own, and removes meta.Finalizer.Migration from it.
+func (r *ClusterReconciler) removeFinalizer(ctx context.Context, cluster *capiv1alpha3.Cluster) error {
+	err := r.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key()) {
+		return nil
+	}
+
+	controllerutil.RemoveFinalizer(cluster, meta.Finalizer.Migration.Key())
+	err = r.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,857 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute/computeapi"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/micrologger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanekubeadmv1alpha3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	expcapiv1alpha3 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/meta"
+	"github.com/giantswarm/capi-migration/pkg/migration"
+	"github.com/giantswarm/capi-migration/pkg/vault"
+)
+
+// These scenarios drive legacy clusters through the whole migration with
+// ClusterReconciler against a real API server. Upstream controllers are not
+// running, so the test plays their part by creating the control plane
+// Machine and nodes of the workload cluster. Cloud APIs and Vault are faked.
+
+var _ = Describe("Cluster migration", func() {
+	var (
+		ctx         context.Context
+		caCert      []byte
+		caKey       []byte
+		vaultServer *httptest.Server
+		vaultClient *vault.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		caCert, caKey = newTestCA()
+		vaultServer = newFakeVault(caCert, caKey)
+
+		logger, err := micrologger.New(micrologger.Config{})
+		Expect(err).NotTo(HaveOccurred())
+		vaultClient, err = vault.New(vault.Config{
+			Logger:  logger,
+			Address: vaultServer.URL,
+			Token:   "test",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		// Checked when the AWS provider is enabled, clients are faked anyway.
+		Expect(os.Setenv("AWS_ACCESS_KEY_ID", "test")).To(Succeed())
+		Expect(os.Setenv("AWS_SECRET_ACCESS_KEY", "test")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		vaultServer.Close()
+
+		// All clusters share the workload cluster API server.
+		Expect(wcClient.DeleteAllOf(ctx, &corev1.Node{})).To(Succeed())
+	})
+
+	Context("of an AWS cluster", func() {
+		const clusterID = "a1b2c"
+
+		It("replaces legacy masters and node pools", func() {
+			asg := &fakeASG{groups: map[string]*autoscaling.Group{
+				"a1b2c-tccpn-asg": newTestASG("a1b2c-tccpn-asg", clusterID, "tccpn", "", 1),
+				"a1b2c-tcnp-asg":  newTestASG("a1b2c-tcnp-asg", clusterID, "tcnp", "d3e4f", 2),
+			}}
+			cloudFormation := &fakeCloudFormation{stacks: map[string]string{
+				"cluster-a1b2c-tccpn":      cloudformation.StackStatusCreateComplete,
+				"cluster-a1b2c-tcnp-d3e4f": cloudformation.StackStatusCreateComplete,
+			}}
+
+			var roleARN string
+			r := newTestClusterReconciler(migration.ProviderConfig{
+				VaultClient: vaultClient.API(),
+				NewAWSClients: func(config migration.AWSConfig) (*migration.AWSClients, error) {
+					roleARN = config.RoleARN
+					return &migration.AWSClients{
+						ASG:            asg,
+						CloudFormation: cloudFormation,
+						EC2:            &fakeEC2{},
+					}, nil
+				},
+			}, migration.ProviderAWS)
+
+			createObjects(ctx, k8sClient, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "org-acme"}})
+			createObjects(ctx, k8sClient, awsClusterObjects(clusterID, caCert, caKey)...)
+			createLegacyNodes(ctx, "ip-10-1-5-1", map[string]string{
+				"role":                           "master",
+				"node-role.kubernetes.io/master": "",
+			}, "ip-10-1-6-1", map[string]string{
+				"role":                             "worker",
+				"giantswarm.io/machine-deployment": "d3e4f",
+			})
+
+			key := client.ObjectKey{Namespace: "org-acme", Name: clusterID}
+
+			By("preparing and triggering the migration")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseTriggered)
+
+			Expect(roleARN).To(Equal("arn:aws:iam::123456789012:role/GiantSwarmAWSOperator"))
+
+			cluster := &capiv1alpha3.Cluster{}
+			Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			Expect(cluster.Labels).To(HaveKey("cluster.x-k8s.io/watch-filter"))
+			Expect(cluster.Labels).NotTo(HaveKey(label.AWSOperatorVersion))
+			Expect(cluster.Spec.ControlPlaneRef).NotTo(BeNil())
+			Expect(cluster.Spec.ControlPlaneRef.Kind).To(Equal("KubeadmControlPlane"))
+			Expect(controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key())).To(BeTrue())
+
+			awsCluster := &giantswarmawsalpha3.AWSCluster{}
+			Expect(k8sClient.Get(ctx, key, awsCluster)).To(Succeed())
+			Expect(awsCluster.Finalizers).To(BeEmpty())
+
+			kcp := &controlplanekubeadmv1alpha3.KubeadmControlPlane{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-acme", Name: "a1b2c-control-plane"}, kcp)).To(Succeed())
+			Expect(kcp.Labels).To(HaveKey("cluster.x-k8s.io/watch-filter"))
+
+			for _, obj := range []runtime.Object{
+				&capaexp.AWSMachinePool{},
+				&expcapiv1alpha3.MachinePool{},
+			} {
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-acme", Name: "a1b2c-worker-d3e4f"}, obj)).To(Succeed())
+			}
+
+			for _, name := range []string{"a1b2c-k8s-encryption-config", "a1b2c-custom-files"} {
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-acme", Name: name}, &corev1.Secret{})).To(Succeed())
+			}
+			for _, name := range []string{"a1b2c-ca", "a1b2c-etcd", "a1b2c-etcd-legacy-client", "a1b2c-proxy", "a1b2c-sa"} {
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-acme", Name: name}, secret)).To(Succeed())
+				Expect(secret.Labels).To(HaveKeyWithValue(capiv1alpha3.ClusterLabelName, clusterID))
+				Expect(secret.Type).To(Equal(capiv1alpha3.ClusterSecretType))
+				Expect(secret.Data).To(HaveKey("tls.crt"))
+				Expect(secret.Data).To(HaveKey("tls.key"))
+				Expect(secret.OwnerReferences).To(HaveLen(1))
+				Expect(secret.OwnerReferences[0].Kind).To(Equal("Cluster"))
+			}
+
+			for _, name := range []string{"a1b2c-etcd", "a1b2c-service-account"} {
+				legacy := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, legacy)).To(Succeed())
+				Expect(legacy.Labels).NotTo(HaveKey(capiv1alpha3.ClusterLabelName))
+				Expect(legacy.Data).NotTo(HaveKey("tls.crt"))
+			}
+
+			By("waiting for the new control plane")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseWaitingForControlPlane)
+			Expect(asg.groups).To(HaveLen(2))
+
+			By("bringing up upstream machines")
+			createReadyControlPlaneMachine(ctx, clusterID, kcp, "ip-10-1-5-2")
+			createReadyNodes(ctx, "ip-10-1-5-2", map[string]string{
+				"node-role.kubernetes.io/master": "",
+			})
+			createReadyNodes(ctx, "ip-10-1-6-2", nil)
+			createReadyNodes(ctx, "ip-10-1-6-3", nil)
+
+			By("cleaning up legacy resources")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseCompleted)
+
+			Expect(asg.groups).To(BeEmpty())
+			Expect(cloudFormation.stacks).To(BeEmpty())
+
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key())).To(BeFalse())
+		})
+	})
+
+	Context("of an Azure cluster", func() {
+		const clusterID = "z9y8x"
+
+		It("replaces legacy masters and node pools", func() {
+			vmss := &fakeVMSS{capacities: map[string]int64{
+				"z9y8x-master-z9y8x": 1,
+				"nodepool-np001":     2,
+			}}
+
+			var credentials migration.AzureCredentials
+			r := newTestClusterReconciler(migration.ProviderConfig{
+				VaultClient: vaultClient.API(),
+				NewAzureVMSSClient: func(c migration.AzureCredentials) (computeapi.VirtualMachineScaleSetsClientAPI, error) {
+					credentials = c
+					return vmss, nil
+				},
+			}, migration.ProviderAzure)
+
+			createObjects(ctx, k8sClient, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "org-giantswarm"}})
+			createObjects(ctx, k8sClient, azureClusterObjects(clusterID, caCert, caKey)...)
+			createLegacyNodes(ctx, "z9y8x-master-z9y8x-000000", map[string]string{
+				"role":                           "master",
+				"node-role.kubernetes.io/master": "",
+			}, "nodepool-np001-000000", map[string]string{
+				"role": "worker",
+			})
+
+			key := client.ObjectKey{Namespace: "org-giantswarm", Name: clusterID}
+
+			By("preparing and triggering the migration")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseTriggered)
+
+			Expect(credentials).To(Equal(migration.AzureCredentials{
+				SubscriptionID: "00000000-0000-0000-0000-000000000001",
+				TenantID:       "00000000-0000-0000-0000-000000000002",
+				ClientID:       "00000000-0000-0000-0000-000000000003",
+				ClientSecret:   "secret",
+			}))
+
+			cluster := &capiv1alpha3.Cluster{}
+			Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			Expect(cluster.Labels).To(HaveKey("cluster.x-k8s.io/watch-filter"))
+			Expect(cluster.Spec.ControlPlaneRef).NotTo(BeNil())
+			Expect(cluster.Spec.ControlPlaneRef.Kind).To(Equal("KubeadmControlPlane"))
+
+			kcp := &controlplanekubeadmv1alpha3.KubeadmControlPlane{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-giantswarm", Name: "z9y8x-control-plane"}, kcp)).To(Succeed())
+			Expect(kcp.Labels).To(HaveKey("cluster.x-k8s.io/watch-filter"))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-giantswarm", Name: "z9y8x-md-0"}, &capiv1alpha3.MachineDeployment{})).To(Succeed())
+
+			for _, name := range []string{"z9y8x-ca", "z9y8x-etcd", "z9y8x-etcd-legacy-client", "z9y8x-proxy", "z9y8x-sa"} {
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "org-giantswarm", Name: name}, secret)).To(Succeed())
+				Expect(secret.Labels).To(HaveKeyWithValue(capiv1alpha3.ClusterLabelName, clusterID))
+				Expect(secret.Data).To(HaveKey("tls.crt"))
+				Expect(secret.Data).To(HaveKey("tls.key"))
+				Expect(secret.OwnerReferences).To(HaveLen(1))
+			}
+
+			pod := &corev1.Pod{}
+			Expect(wcClient.Get(ctx, client.ObjectKey{Namespace: "kube-system", Name: "disable-master-node-components-z9y8x-master-z9y8x-000000"}, pod)).To(Succeed())
+
+			By("waiting for the new control plane")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseWaitingForControlPlane)
+			Expect(vmss.capacities).To(HaveLen(2))
+
+			By("bringing up upstream machines")
+			createReadyControlPlaneMachine(ctx, clusterID, kcp, "z9y8x-control-plane-abcde")
+			createReadyNodes(ctx, "z9y8x-control-plane-abcde", map[string]string{
+				"node-role.kubernetes.io/master": "",
+			})
+			createReadyNodes(ctx, "z9y8x-md-0-abcde", nil)
+			createReadyNodes(ctx, "z9y8x-md-0-fghij", nil)
+
+			By("cleaning up legacy resources")
+			reconcileUntilPhase(r, key, migrationv1alpha1.ClusterMigrationPhaseCompleted)
+
+			Expect(vmss.capacities).To(BeEmpty())
+
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(cluster, meta.Finalizer.Migration.Key())).To(BeFalse())
+		})
+	})
+})
+
+func newTestClusterReconciler(cfg migration.ProviderConfig, providers ...string) *ClusterReconciler {
+	logger, err := micrologger.New(micrologger.Config{})
+	Expect(err).NotTo(HaveOccurred())
+
+	recorder := &record.FakeRecorder{}
+
+	cfg.CtrlClient = k8sClient
+	cfg.EventRecorder = recorder
+	cfg.Logger = logger
+	cfg.Scheme = scheme.Scheme
+	cfg.TenantCluster = &testTenantCluster{config: wcCfg}
+
+	selector, err := migration.NewProviderSelector(cfg, providers)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &ClusterReconciler{
+		Client:          k8sClient,
+		Log:             logger,
+		MigratorFactory: selector,
+		Recorder:        recorder,
+		Scheme:          scheme.Scheme,
+	}
+}
+
+// reconcileUntilPhase reconciles the cluster until its ClusterMigration
+// reaches the given phase. Failed reconciliations are retried like they
+// would be by the controller.
+func reconcileUntilPhase(r *ClusterReconciler, key client.ObjectKey, phase migrationv1alpha1.ClusterMigrationPhase) {
+	Eventually(func() (migrationv1alpha1.ClusterMigrationPhase, error) {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+		if err != nil {
+			return "", err
+		}
+
+		cm := &migrationv1alpha1.ClusterMigration{}
+		err = k8sClient.Get(context.Background(), key, cm)
+		if err != nil {
+			return "", err
+		}
+
+		return cm.Status.Phase, nil
+	}, 30*time.Second, 100*time.Millisecond).Should(Equal(phase))
+}
+
+func createObjects(ctx context.Context, c client.Client, objs ...runtime.Object) {
+	for _, obj := range objs {
+		Expect(c.Create(ctx, obj)).To(Succeed())
+	}
+}
+
+// createLegacyNodes creates ready nodes of legacy machines in the workload
+// cluster. Arguments are pairs of node name and labels.
+func createLegacyNodes(ctx context.Context, nameAndLabels ...interface{}) {
+	for i := 0; i < len(nameAndLabels); i += 2 {
+		createReadyNodes(ctx, nameAndLabels[i].(string), nameAndLabels[i+1].(map[string]string))
+	}
+}
+
+func createReadyNodes(ctx context.Context, name string, labels map[string]string) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+	Expect(wcClient.Create(ctx, node)).To(Succeed())
+
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+	Expect(wcClient.Status().Update(ctx, node)).To(Succeed())
+}
+
+// createReadyControlPlaneMachine creates a ready Machine owned by kcp, like
+// upstream controllers would do once the new master joins.
+func createReadyControlPlaneMachine(ctx context.Context, clusterName string, kcp *controlplanekubeadmv1alpha3.KubeadmControlPlane, nodeName string) {
+	dataSecretName := clusterName + "-bootstrap"
+
+	machine := &capiv1alpha3.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeName,
+			Namespace: kcp.Namespace,
+			Labels: map[string]string{
+				capiv1alpha3.ClusterLabelName:             clusterName,
+				capiv1alpha3.MachineControlPlaneLabelName: "",
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: controlplanekubeadmv1alpha3.GroupVersion.String(),
+				Kind:       "KubeadmControlPlane",
+				Name:       kcp.Name,
+				UID:        kcp.UID,
+			}},
+		},
+		Spec: capiv1alpha3.MachineSpec{
+			ClusterName:       clusterName,
+			Bootstrap:         capiv1alpha3.Bootstrap{DataSecretName: &dataSecretName},
+			InfrastructureRef: corev1.ObjectReference{Kind: "Machine", Name: nodeName},
+		},
+	}
+	Expect(k8sClient.Create(ctx, machine)).To(Succeed())
+
+	machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: nodeName}
+	conditions.MarkTrue(machine, capiv1alpha3.ReadyCondition)
+	Expect(k8sClient.Status().Update(ctx, machine)).To(Succeed())
+}
+
+func newTestRelease(name string, infrastructureProvider string) *release.Release {
+	now := metav1.Now()
+
+	return &release.Release{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: release.ReleaseSpec{
+			Apps: []release.ReleaseSpecApp{},
+			Components: []release.ReleaseSpecComponent{
+				{Name: "kubernetes", Version: "1.19.9"},
+				{Name: "etcd", Version: "3.4.14"},
+				{Name: "cluster-operator", Version: "3.6.0"},
+				{Name: "cluster-api-core", Version: "0.3.13"},
+				{Name: "cluster-api-bootstrap-provider-kubeadm", Version: "0.3.13"},
+				{Name: "cluster-api-control-plane", Version: "0.3.13"},
+				{Name: infrastructureProvider, Version: "0.6.4"},
+			},
+			Date:  &now,
+			State: release.StateActive,
+		},
+	}
+}
+
+func awsClusterObjects(clusterID string, caCert, caKey []byte) []runtime.Object {
+	clusterLabels := map[string]string{
+		capiv1alpha3.ClusterLabelName: clusterID,
+		label.AWSOperatorVersion:      "10.1.0",
+		label.ReleaseVersion:          "14.1.0",
+	}
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "org-acme", Labels: clusterLabels}
+	}
+
+	cluster := &capiv1alpha3.Cluster{
+		ObjectMeta: objectMeta(clusterID),
+		Spec: capiv1alpha3.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: giantswarmawsalpha3.SchemeGroupVersion.String(),
+				Kind:       "AWSCluster",
+				Name:       clusterID,
+				Namespace:  "org-acme",
+			},
+		},
+	}
+	cluster.Finalizers = []string{"operatorkit.giantswarm.io/cluster-operator-cluster-controller"}
+	cluster.Annotations = map[string]string{meta.Annotation.Migrate.Key(): meta.Annotation.Migrate.Val()}
+
+	awsCluster := &giantswarmawsalpha3.AWSCluster{
+		ObjectMeta: objectMeta(clusterID),
+		Spec: giantswarmawsalpha3.AWSClusterSpec{
+			Cluster: giantswarmawsalpha3.AWSClusterSpecCluster{
+				Description: "e2e",
+				DNS:         giantswarmawsalpha3.AWSClusterSpecClusterDNS{Domain: "eu-west-1.aws.example.com"},
+			},
+			Provider: giantswarmawsalpha3.AWSClusterSpecProvider{
+				CredentialSecret: giantswarmawsalpha3.AWSClusterSpecProviderCredentialSecret{
+					Name:      "credential-" + clusterID,
+					Namespace: "giantswarm",
+				},
+				Region: "eu-west-1",
+			},
+		},
+	}
+	awsCluster.Finalizers = []string{"operatorkit.giantswarm.io/aws-operator-cluster-controller"}
+
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "giantswarm"}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credential-" + clusterID, Namespace: "giantswarm"},
+			Data:       map[string][]byte{"aws.awsoperator.arn": []byte("arn:aws:iam::123456789012:role/GiantSwarmAWSOperator")},
+		},
+		newTestLegacySecret(clusterID+"-encryption", "encryption", "c2VjcmV0LWVuY3J5cHRpb24ta2V5"),
+		newTestLegacyCertSecret(clusterID, "api", caCert, caKey),
+		newTestLegacyCertSecret(clusterID, "etcd", caCert, caKey),
+		newTestLegacyCertSecret(clusterID, "service-account", caCert, caKey),
+		cluster,
+		awsCluster,
+		&giantswarmawsalpha3.AWSControlPlane{
+			ObjectMeta: objectMeta("a0b1c"),
+			Spec:       giantswarmawsalpha3.AWSControlPlaneSpec{InstanceType: "m5.xlarge"},
+		},
+		&giantswarmawsalpha3.G8sControlPlane{
+			ObjectMeta: objectMeta("a0b1c"),
+			Spec: giantswarmawsalpha3.G8sControlPlaneSpec{
+				InfrastructureRef: corev1.ObjectReference{Kind: "AWSControlPlane", Name: "a0b1c", Namespace: "org-acme"},
+			},
+		},
+		&giantswarmawsalpha3.AWSMachineDeployment{
+			ObjectMeta: objectMeta("d3e4f"),
+			Spec: giantswarmawsalpha3.AWSMachineDeploymentSpec{
+				NodePool: giantswarmawsalpha3.AWSMachineDeploymentSpecNodePool{
+					Description: "e2e",
+					Scaling:     giantswarmawsalpha3.AWSMachineDeploymentSpecNodePoolScaling{Min: 2, Max: 5},
+				},
+				Provider: giantswarmawsalpha3.AWSMachineDeploymentSpecProvider{
+					AvailabilityZones: []string{"eu-west-1a"},
+					Worker:            giantswarmawsalpha3.AWSMachineDeploymentSpecProviderWorker{InstanceType: "m5.2xlarge"},
+				},
+			},
+		},
+		newTestRelease("v14.1.0", "cluster-api-provider-aws"),
+	}
+}
+
+func azureClusterObjects(clusterID string, caCert, caKey []byte) []runtime.Object {
+	clusterLabels := map[string]string{
+		capiv1alpha3.ClusterLabelName: clusterID,
+		label.AzureOperatorVersion:    "5.5.0",
+		label.ReleaseVersion:          "14.2.0",
+	}
+
+	cluster := &capiv1alpha3.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       clusterID,
+			Namespace:  "org-giantswarm",
+			Labels:     clusterLabels,
+			Finalizers: []string{"operatorkit.giantswarm.io/cluster-operator-cluster-controller"},
+		},
+		Spec: capiv1alpha3.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: capz.GroupVersion.String(),
+				Kind:       "AzureCluster",
+				Name:       clusterID,
+				Namespace:  "org-giantswarm",
+			},
+		},
+	}
+	cluster.Annotations = map[string]string{meta.Annotation.Migrate.Key(): meta.Annotation.Migrate.Val()}
+
+	dataSecretName := clusterID + "-np001-bootstrap"
+
+	return []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-identity-secret", Namespace: "org-giantswarm"},
+			Data:       map[string][]byte{"clientSecret": []byte("secret")},
+		},
+		&capz.AzureClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "org-giantswarm", Namespace: "org-giantswarm"},
+			Spec: capz.AzureClusterIdentitySpec{
+				Type:         capz.ServicePrincipal,
+				TenantID:     "00000000-0000-0000-0000-000000000002",
+				ClientID:     "00000000-0000-0000-0000-000000000003",
+				ClientSecret: corev1.SecretReference{Name: "cluster-identity-secret", Namespace: "org-giantswarm"},
+			},
+		},
+		newTestLegacySecret(clusterID+"-encryption", "encryption", "c2VjcmV0LWVuY3J5cHRpb24ta2V5"),
+		newTestLegacyCertSecret(clusterID, "api", caCert, caKey),
+		newTestLegacyCertSecret(clusterID, "etcd", caCert, caKey),
+		newTestLegacyCertSecret(clusterID, "service-account", caCert, caKey),
+		cluster,
+		&capz.AzureCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       clusterID,
+				Namespace:  "org-giantswarm",
+				Labels:     clusterLabels,
+				Finalizers: []string{"operatorkit.giantswarm.io/azure-operator-cluster-controller"},
+			},
+			Spec: capz.AzureClusterSpec{
+				Location:       "westeurope",
+				SubscriptionID: "00000000-0000-0000-0000-000000000001",
+				IdentityRef: &corev1.ObjectReference{
+					Kind:      "AzureClusterIdentity",
+					Name:      "org-giantswarm",
+					Namespace: "org-giantswarm",
+				},
+				ControlPlaneEndpoint: capiv1alpha3.APIEndpoint{
+					Host: "api.z9y8x.k8s.westeurope.azure.example.com",
+					Port: 443,
+				},
+				NetworkSpec: capz.NetworkSpec{
+					Vnet: capz.VnetSpec{
+						Name:       clusterID + "-VirtualNetwork",
+						CIDRBlocks: []string{"10.10.0.0/16"},
+					},
+				},
+			},
+		},
+		&provider.AzureConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterID, Namespace: "default", Labels: clusterLabels},
+			Spec: provider.AzureConfigSpec{
+				Azure: provider.AzureConfigSpecAzure{
+					AvailabilityZones: []int{},
+					Masters:           []provider.AzureConfigSpecAzureNode{},
+					Workers:           []provider.AzureConfigSpecAzureNode{},
+				},
+				Cluster: provider.Cluster{
+					Masters: []provider.ClusterNode{},
+					Kubernetes: provider.ClusterKubernetes{
+						SSH: provider.ClusterKubernetesSSH{UserList: []provider.ClusterKubernetesSSHUser{}},
+					},
+				},
+			},
+		},
+		&expcapiv1alpha3.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "np001",
+				Namespace: "org-giantswarm",
+				Labels: map[string]string{
+					capiv1alpha3.ClusterLabelName: clusterID,
+					label.Cluster:                 clusterID,
+				},
+			},
+			Spec: expcapiv1alpha3.MachinePoolSpec{
+				ClusterName: clusterID,
+				Template: capiv1alpha3.MachineTemplateSpec{
+					Spec: capiv1alpha3.MachineSpec{
+						ClusterName:       clusterID,
+						Bootstrap:         capiv1alpha3.Bootstrap{DataSecretName: &dataSecretName},
+						InfrastructureRef: corev1.ObjectReference{Kind: "AzureMachinePool", Name: "np001"},
+					},
+				},
+			},
+		},
+		newTestRelease("v14.2.0", "cluster-api-provider-azure"),
+	}
+}
+
+func newTestLegacySecret(name, key, value string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{key: []byte(value)},
+	}
+}
+
+// newTestLegacyCertSecret returns a cert-operator secret with a leaf
+// certificate issued by the CA.
+func newTestLegacyCertSecret(clusterID, cert string, caCert, caKey []byte) *corev1.Secret {
+	caBlock, _ := pem.Decode(caCert)
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+	keyBlock, _ := pem.Decode(caKey)
+	signer, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	Expect(err).NotTo(HaveOccurred())
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cert + "." + clusterID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, signer)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterID + "-" + cert,
+			Namespace: "default",
+			Labels: map[string]string{
+				"giantswarm.io/certificate": cert,
+				"giantswarm.io/cluster":     clusterID,
+			},
+		},
+		Data: map[string][]byte{
+			"ca":  caCert,
+			"crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			"key": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}
+}
+
+func newTestASG(name, clusterID, stack, machineDeployment string, capacity int64) *autoscaling.Group {
+	return &autoscaling.Group{
+		AutoScalingGroupName: aws.String(name),
+		DesiredCapacity:      aws.Int64(capacity),
+		MaxSize:              aws.Int64(capacity),
+		Tags: []*autoscaling.TagDescription{
+			{Key: aws.String("giantswarm.io/cluster"), Value: aws.String(clusterID)},
+			{Key: aws.String("giantswarm.io/stack"), Value: aws.String(stack)},
+			{Key: aws.String("giantswarm.io/machine-deployment"), Value: aws.String(machineDeployment)},
+		},
+	}
+}
+
+// newTestCA returns PEM encoded self-signed CA certificate and its key.
+func newTestCA() ([]byte, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "e2e"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPEM, keyPEM
+}
+
+// newFakeVault serves the health check and PKI endpoints read by migrators
+// with the same CA for every cluster.
+func newFakeVault(caCert, caKey []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			body = map[string]interface{}{"data": map[string]interface{}{"ttl": 0, "renewable": false}}
+		case r.URL.Path == "/v1/sys/health":
+			body = map[string]interface{}{"initialized": true, "sealed": false}
+		case strings.HasPrefix(r.URL.Path, "/v1/pki-") && strings.HasSuffix(r.URL.Path, "/gimmeallyourlovin"):
+			body = map[string]interface{}{"data": map[string]string{"certificate": string(caCert), "private_key": string(caKey)}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+// testTenantCluster returns the same REST config for every workload cluster.
+type testTenantCluster struct {
+	config *rest.Config
+}
+
+func (t *testTenantCluster) NewRestConfig(ctx context.Context, clusterID, apiDomain string) (*rest.Config, error) {
+	return rest.CopyConfig(t.config), nil
+}
+
+// fakeEC2 finds exactly one security group and subnet for any filter.
+type fakeEC2 struct {
+	ec2iface.EC2API
+}
+
+func (f *fakeEC2) DescribeSecurityGroups(i *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	return &ec2.DescribeSecurityGroupsOutput{
+		SecurityGroups: []*ec2.SecurityGroup{{GroupId: aws.String("sg-1")}},
+	}, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(i *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{
+		Subnets: []*ec2.Subnet{{SubnetId: aws.String("subnet-1"), AvailabilityZone: aws.String("eu-west-1a")}},
+	}, nil
+}
+
+// fakeASG holds ASGs by name. Deleted ASGs are gone immediately.
+type fakeASG struct {
+	autoscalingiface.AutoScalingAPI
+
+	groups map[string]*autoscaling.Group
+}
+
+func (f *fakeASG) DescribeTagsPages(i *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool) error {
+	o := &autoscaling.DescribeTagsOutput{}
+	for _, g := range f.groups {
+		for _, t := range g.Tags {
+			o.Tags = append(o.Tags, &autoscaling.TagDescription{
+				Key:        t.Key,
+				ResourceId: g.AutoScalingGroupName,
+				Value:      t.Value,
+			})
+		}
+	}
+	fn(o, true)
+
+	return nil
+}
+
+func (f *fakeASG) DescribeAutoScalingGroupsPages(i *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	o := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	seen := map[string]bool{}
+	for _, name := range aws.StringValueSlice(i.AutoScalingGroupNames) {
+		g, ok := f.groups[name]
+		if ok && !seen[name] {
+			o.AutoScalingGroups = append(o.AutoScalingGroups, g)
+			seen[name] = true
+		}
+	}
+	fn(o, true)
+
+	return nil
+}
+
+func (f *fakeASG) UpdateAutoScalingGroup(i *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	g, ok := f.groups[aws.StringValue(i.AutoScalingGroupName)]
+	if !ok {
+		return nil, awserr.New(autoscaling.ErrCodeResourceContentionFault, "not found", nil)
+	}
+	g.DesiredCapacity = i.DesiredCapacity
+	g.MaxSize = i.MaxSize
+	g.MinSize = i.MinSize
+
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (f *fakeASG) DeleteAutoScalingGroup(i *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	delete(f.groups, aws.StringValue(i.AutoScalingGroupName))
+
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+// fakeCloudFormation holds stack statuses by stack name. Deleted stacks are
+// gone by the next call.
+type fakeCloudFormation struct {
+	cloudformationiface.CloudFormationAPI
+
+	stacks map[string]string
+}
+
+func (f *fakeCloudFormation) DescribeStacks(i *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	name := aws.StringValue(i.StackName)
+	status, ok := f.stacks[name]
+	if !ok {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("Stack with id %s does not exist", name), nil)
+	}
+
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{{StackName: aws.String(name), StackStatus: aws.String(status)}},
+	}, nil
+}
+
+func (f *fakeCloudFormation) DeleteStack(i *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+	delete(f.stacks, aws.StringValue(i.StackName))
+
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+// fakeVMSS holds VMSS capacities by VMSS name. Deleted VMSSes are gone
+// immediately.
+type fakeVMSS struct {
+	computeapi.VirtualMachineScaleSetsClientAPI
+
+	capacities map[string]int64
+}
+
+func (f *fakeVMSS) Get(ctx context.Context, resourceGroupName string, name string) (compute.VirtualMachineScaleSet, error) {
+	capacity, ok := f.capacities[name]
+	if !ok {
+		return compute.VirtualMachineScaleSet{}, autorest.DetailedError{StatusCode: http.StatusNotFound}
+	}
+
+	return compute.VirtualMachineScaleSet{
+		Name: &name,
+		Sku:  &compute.Sku{Capacity: &capacity},
+	}, nil
+}
+
+func (f *fakeVMSS) Delete(ctx context.Context, resourceGroupName string, name string) (compute.VirtualMachineScaleSetsDeleteFuture, error) {
+	delete(f.capacities, name)
+
+	return compute.VirtualMachineScaleSetsDeleteFuture{}, nil
+}
@@ -17,11 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -31,6 +35,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapkubeadmv1alpha3 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	controlplanekubeadmv1alpha3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	expcapiv1alpha3 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/migration"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -41,6 +51,12 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// wcCfg points at a second API server playing the workload cluster of
+// migrated clusters.
+var wcCfg *rest.Config
+var wcClient client.Client
+var wcTestEnv *envtest.Environment
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -54,7 +70,8 @@ var _ = BeforeSuite(func(done Done) {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDDirectoryPaths:     append([]string{filepath.Join("..", "config", "crd", "bases")}, upstreamCRDPaths()...),
+		ErrorIfCRDPathMissing: true,
 	}
 
 	var err error
@@ -62,8 +79,23 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(cfg).ToNot(BeNil())
 
-	err = capiv1alpha3.AddToScheme(scheme.Scheme)
-	Expect(err).NotTo(HaveOccurred())
+	wcTestEnv = &envtest.Environment{}
+	wcCfg, err = wcTestEnv.Start()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(wcCfg).ToNot(BeNil())
+
+	for _, add := range []func(*runtime.Scheme) error{
+		capiv1alpha3.AddToScheme,
+		expcapiv1alpha3.AddToScheme,
+		bootstrapkubeadmv1alpha3.AddToScheme,
+		controlplanekubeadmv1alpha3.AddToScheme,
+		release.AddToScheme,
+		migrationv1alpha1.AddToScheme,
+		migration.AddProvidersToScheme,
+	} {
+		err = add(scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+	}
 
 	// +kubebuilder:scaffold:scheme
 
@@ -71,6 +103,10 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	wcClient, err = client.New(wcCfg, client.Options{Scheme: scheme.Scheme})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(wcClient).ToNot(BeNil())
+
 	close(done)
 }, 60)
 
@@ -78,4 +114,45 @@ var _ = AfterSuite(func() {
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
+	err = wcTestEnv.Stop()
+	Expect(err).ToNot(HaveOccurred())
 })
+
+// upstreamCRDPaths returns CRDs of CAPI, its providers and Giant Swarm from
+// the module cache, so that they always match versions in go.mod.
+func upstreamCRDPaths() []string {
+	capi := moduleDir("sigs.k8s.io/cluster-api")
+	capa := moduleDir("sigs.k8s.io/cluster-api-provider-aws")
+	capz := moduleDir("sigs.k8s.io/cluster-api-provider-azure")
+	giantswarm := filepath.Join(moduleDir("github.com/giantswarm/apiextensions/v3"), "config", "crd", "v1")
+
+	paths := []string{
+		filepath.Join(capi, "config", "crd", "bases"),
+		filepath.Join(capi, "bootstrap", "kubeadm", "config", "crd", "bases"),
+		filepath.Join(capi, "controlplane", "kubeadm", "config", "crd", "bases"),
+		filepath.Join(capa, "config", "crd", "bases"),
+		filepath.Join(capz, "config", "crd", "bases"),
+	}
+
+	// apiextensions also ships older copies of upstream CRDs, so only
+	// Giant Swarm groups are picked from there.
+	for _, name := range []string{
+		"infrastructure.giantswarm.io_awsclusters.yaml",
+		"infrastructure.giantswarm.io_awscontrolplanes.yaml",
+		"infrastructure.giantswarm.io_awsmachinedeployments.yaml",
+		"infrastructure.giantswarm.io_g8scontrolplanes.yaml",
+		"provider.giantswarm.io_azureconfigs.yaml",
+		"release.giantswarm.io_releases.yaml",
+	} {
+		paths = append(paths, filepath.Join(giantswarm, name))
+	}
+
+	return paths
+}
+
+func moduleDir(module string) string {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module).Output()
+	Expect(err).NotTo(HaveOccurred(), "failed to find %s in module cache", module)
+
+	return strings.TrimSpace(string(out))
+}
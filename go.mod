@@ -18,7 +18,10 @@ require (
 	github.com/hashicorp/vault/api v1.0.4
 	github.com/onsi/ginkgo v1.15.2
 	github.com/onsi/gomega v1.11.0
+	github.com/prometheus/client_golang v1.8.0
+	github.com/spf13/cobra v1.0.0
 	github.com/spf13/pflag v1.0.5
+	gomodules.xyz/jsonpatch/v2 v2.0.1
 	k8s.io/api v0.18.9
 	k8s.io/apimachinery v0.18.9
 	k8s.io/client-go v0.18.9
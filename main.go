@@ -23,37 +23,31 @@ import (
 	"strings"
 	"time"
 
-	providerv1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
 	releasev1alpha1 "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
 	"github.com/giantswarm/certs/v3/pkg/certs"
 	"github.com/giantswarm/k8sclient/v4/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
-	vaultapi "github.com/hashicorp/vault/api"
 	flag "github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
-	capzv1alpha3 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
-	expcapzv1alpha3 "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	capiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	bootstrapkubeadmv1alpha3 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
 	controlplanekubeadmv1alpha3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 	expcapiv1alpha3 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	// +kubebuilder:scaffold:imports
 
+	migrationv1alpha1 "github.com/giantswarm/capi-migration/api/v1alpha1"
 	"github.com/giantswarm/capi-migration/controllers"
 	"github.com/giantswarm/capi-migration/pkg/migration"
 	"github.com/giantswarm/capi-migration/pkg/project"
-)
-
-const (
-	providerAWS   = "aws"
-	providerAzure = "azure"
+	"github.com/giantswarm/capi-migration/pkg/vault"
 )
 
 var (
@@ -64,46 +58,77 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = capiv1alpha3.AddToScheme(scheme)
-	_ = providerv1alpha1.AddToScheme(scheme)
-	_ = capzv1alpha3.AddToScheme(scheme)
 	_ = expcapiv1alpha3.AddToScheme(scheme)
-	_ = expcapzv1alpha3.AddToScheme(scheme)
 	_ = releasev1alpha1.AddToScheme(scheme)
 	_ = bootstrapkubeadmv1alpha3.AddToScheme(scheme)
 	_ = controlplanekubeadmv1alpha3.AddToScheme(scheme)
+	_ = migrationv1alpha1.AddToScheme(scheme)
+	_ = migration.AddProvidersToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 var flags = struct {
-	AWSAccessKeyID     string
-	AWSAccessKeySecret string
+	DryRun             bool
+	HealthProbeAddress string
 	LeaderElect        bool
 	MetricsBindAddress string
-	Provider           string
+	Providers          []string
+	SSHAWSKeyPair      string
+	SSHAzurePublicKey  string
+	SSHConfigMap       string
+	SSHDisabled        bool
+	SSHUsers           []string
+	TemplatesConfigMap string
 	VaultAddr          string
+	VaultAuthPath      string
+	VaultRole          string
 	VaultToken         string
+
+	// Parsed from SSH flags.
+	SSH             migration.SSHConfig
+	SSHConfigMapKey client.ObjectKey
+
+	// Parsed from templates flag.
+	TemplatesConfigMapKey client.ObjectKey
 }{}
 
 func initFlags() (errors []error) {
 	// Flag/configuration names.
 	const (
-		flagAWSAccessKeyID     = "aws-access-id"
-		flagAWSAccessKeySecret = "aws-access-secret" //nolint:gosec
+		flagDryRun             = "dry-run"
+		flagHealthProbeAddress = "health-probe-bind-address"
 		flagLeaderElect        = "leader-elect"
 		flagMetricsBindAddres  = "metrics-bind-address"
 		flagProvider           = "provider"
+		flagSSHAWSKeyPair      = "ssh-aws-key-pair"
+		flagSSHAzurePublicKey  = "ssh-azure-public-key"
+		flagSSHConfigMap       = "ssh-config-map"
+		flagSSHDisabled        = "ssh-disabled"
+		flagSSHUser            = "ssh-user"
+		flagTemplatesConfigMap = "templates-config-map"
 		flagVaultAddr          = "vault-addr"
+		flagVaultAuthPath      = "vault-auth-path"
+		flagVaultRole          = "vault-role"
 		flagVaultToken         = "vault-token"
 	)
 
 	// Flag binding.
-	flag.StringVar(&flags.AWSAccessKeyID, flagAWSAccessKeyID, "", "AWS access key for MC.")
-	flag.StringVar(&flags.AWSAccessKeySecret, flagAWSAccessKeySecret, "", "AWS secret key for MC.")
+	flag.BoolVar(&flags.DryRun, flagDryRun, false, "Only render migration plan into a ConfigMap instead of migrating clusters.")
+	flag.StringVar(&flags.HealthProbeAddress, flagHealthProbeAddress, ":8081", "The address the health probe endpoint binds to.")
 	flag.BoolVar(&flags.LeaderElect, flagLeaderElect, false, "Enable leader election for controller manager.")
 	flag.StringVar(&flags.MetricsBindAddress, flagMetricsBindAddres, ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&flags.Provider, flagProvider, "", "Provider name for the migration.")
+	flag.StringSliceVar(&flags.Providers, flagProvider, nil, fmt.Sprintf("Names of providers enabled for the migration. Defaults to all registered providers %v.", migration.ProviderNames()))
+	flag.StringVar(&flags.SSHAWSKeyPair, flagSSHAWSKeyPair, "", "EC2 key pair name set on AWS machines.")
+	flag.StringVar(&flags.SSHAzurePublicKey, flagSSHAzurePublicKey, "", "Base64 encoded SSH public key set on Azure machines.")
+	flag.StringVar(&flags.SSHConfigMap, flagSSHConfigMap, "", "ConfigMap in \"namespace/name\" form with SSH access policy overriding SSH flags.")
+	flag.BoolVar(&flags.SSHDisabled, flagSSHDisabled, false, "Disable SSH access to machines of migrated clusters.")
+	flag.StringArrayVar(&flags.SSHUsers, flagSSHUser, nil, "SSH user in \"name=authorized-key\" form. Can be given multiple times.")
+	flag.StringVar(&flags.TemplatesConfigMap, flagTemplatesConfigMap, "", "ConfigMap in \"namespace/name\" form with CR templates overriding embedded ones.")
 	flag.StringVar(&flags.VaultAddr, flagVaultAddr, "", "The address of the vault to connect to. Defaults to VAULT_ADDR.")
-	flag.StringVar(&flags.VaultToken, flagVaultToken, "", "The token to use to authenticate to vault. Defaults to VAULT_TOKEN.")
+	flag.StringVar(&flags.VaultAuthPath, flagVaultAuthPath, vault.DefaultKubernetesAuthPath, "Mount path of the vault Kubernetes auth method.")
+	flag.StringVar(&flags.VaultRole, flagVaultRole, "", "Role to log in to vault with the service account token. Takes precedence over the token.")
+	flag.StringVar(&flags.VaultToken, flagVaultToken, "", "The token to use to authenticate to vault when no role is set. Defaults to VAULT_TOKEN.")
+	migration.InitProviderFlags(flag.CommandLine)
 
 	// Parse flags and configuration.
 	flag.Parse()
@@ -120,17 +145,35 @@ func initFlags() (errors []error) {
 
 	// Validation.
 
-	if flags.Provider != providerAWS && flags.Provider != providerAzure {
-		errors = append(errors, fmt.Errorf("--%s must be either \"%s\" or \"%s\"", flagProvider, providerAWS, providerAzure))
+	{
+		users, err := migration.ParseSSHUsers(flags.SSHUsers)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("--%s: %s", flagSSHUser, microerror.Pretty(err, false)))
+		}
+		flags.SSH = migration.SSHConfig{
+			Disabled:          flags.SSHDisabled,
+			Users:             users,
+			AWSKeyPairName:    flags.SSHAWSKeyPair,
+			AzureSSHPublicKey: flags.SSHAzurePublicKey,
+		}
+
+		flags.SSHConfigMapKey, err = migration.ParseSSHConfigMapKey(flags.SSHConfigMap)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("--%s: %s", flagSSHConfigMap, microerror.Pretty(err, false)))
+		}
 	}
-	if flags.Provider == providerAWS && (flags.AWSAccessKeyID == "" || flags.AWSAccessKeySecret == "") {
-		errors = append(errors, fmt.Errorf("when \"aws\" provider is set, --%s and --%s must not be empty", flagAWSAccessKeyID, flagAWSAccessKeySecret))
+	{
+		var err error
+		flags.TemplatesConfigMapKey, err = migration.ParseTemplatesConfigMapKey(flags.TemplatesConfigMap)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("--%s: %s", flagTemplatesConfigMap, microerror.Pretty(err, false)))
+		}
 	}
 	if flags.VaultAddr == "" {
 		errors = append(errors, fmt.Errorf("--%s flag or VAULT_ADDR environment variable must be set", flagVaultAddr))
 	}
-	if flags.VaultToken == "" {
-		errors = append(errors, fmt.Errorf("--%s flag or VAULT_TOKEN environment variable must be set", flagVaultToken))
+	if flags.VaultRole == "" && flags.VaultToken == "" {
+		errors = append(errors, fmt.Errorf("--%s flag, --%s flag or VAULT_TOKEN environment variable must be set", flagVaultRole, flagVaultToken))
 	}
 
 	return
@@ -185,28 +228,39 @@ func mainE(ctx context.Context) error {
 	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: flags.MetricsBindAddress,
-		Port:               9443,
-		LeaderElection:     flags.LeaderElect,
-		LeaderElectionID:   "2db8ae24.giantswarm.io",
+		Scheme:                 scheme,
+		HealthProbeBindAddress: flags.HealthProbeAddress,
+		MetricsBindAddress:     flags.MetricsBindAddress,
+		Port:                   9443,
+		LeaderElection:         flags.LeaderElect,
+		LeaderElectionID:       "2db8ae24.giantswarm.io",
 	})
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	var vaultClient *vaultapi.Client
+	var vaultClient *vault.Client
 	{
-		c := vaultapi.DefaultConfig()
-		c.Address = flags.VaultAddr
-		vaultClient, err = vaultapi.NewClient(c)
+		vaultClient, err = vault.New(vault.Config{
+			Logger: log,
+
+			Address:            flags.VaultAddr,
+			KubernetesAuthPath: flags.VaultAuthPath,
+			KubernetesRole:     flags.VaultRole,
+			Token:              flags.VaultToken,
+		})
 		if err != nil {
-			return nil
+			return microerror.Mask(err)
 		}
-		vaultClient.SetToken(flags.VaultToken)
 
-		// Check vault connectivity.
-		_, err := vaultClient.Auth().Token().LookupSelf()
+		// Keep the token valid and report vault connectivity. Vault outages
+		// only make the controller unready, restarting it wouldn't help and
+		// would interrupt running migrations.
+		err = mgr.Add(vaultClient)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		err = mgr.AddReadyzCheck("vault", vaultClient.Healthz)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -246,43 +300,34 @@ func mainE(ctx context.Context) error {
 		}
 	}
 
-	var migratorFactory migration.MigratorFactory
+	recorder := mgr.GetEventRecorderFor(project.Name())
+
+	var migratorFactory *migration.ProviderSelector
 	{
-		switch flags.Provider {
-		case providerAWS:
-			migratorFactory, err = migration.NewAWSMigratorFactory(migration.AWSMigrationConfig{
-				AWSCredentials: migration.AWSConfig{
-					AccessKeyID:     flags.AWSAccessKeyID,
-					AccessKeySecret: flags.AWSAccessKeySecret,
-				},
-				CtrlClient:    mgr.GetClient(),
-				Logger:        log,
-				TenantCluster: tenantCluster,
-			})
-
-			if err != nil {
-				return microerror.Mask(err)
-			}
-		case providerAzure:
-			migratorFactory, err = migration.NewAzureMigratorFactory(migration.AzureMigrationConfig{
-				CtrlClient:    mgr.GetClient(),
-				Logger:        log,
-				TenantCluster: tenantCluster,
-			})
-			if err != nil {
-				return microerror.Mask(err)
-			}
-		default:
-			return microerror.Mask(fmt.Errorf("unknown provider %#q", flags.Provider))
-		}
+		migratorFactory, err = migration.NewProviderSelector(migration.ProviderConfig{
+			CtrlClient:    mgr.GetClient(),
+			EventRecorder: recorder,
+			Logger:        log,
+			Scheme:        mgr.GetScheme(),
+			TenantCluster: tenantCluster,
+			VaultClient:   vaultClient.API(),
+
+			DryRun:       flags.DryRun,
+			SSH:          flags.SSH,
+			SSHConfigMap: flags.SSHConfigMapKey,
 
+			TemplatesConfigMap: flags.TemplatesConfigMapKey,
+		}, flags.Providers)
+		if err != nil {
+			return microerror.Mask(err)
+		}
 	}
 
 	if err = (&controllers.ClusterReconciler{
 		Client:          mgr.GetClient(),
 		Log:             log,
 		MigratorFactory: migratorFactory,
-		VaultClient:     vaultClient,
+		Recorder:        recorder,
 		Scheme:          mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		return microerror.Mask(err)
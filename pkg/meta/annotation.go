@@ -0,0 +1,69 @@
+package meta
+
+import (
+	"time"
+
+	"github.com/giantswarm/microerror"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/capi-migration/pkg/project"
+)
+
+var (
+	dryRunAnnotation           = project.Name() + ".giantswarm.io/dry-run"
+	dryRunPlanHashAnnotation   = project.Name() + ".giantswarm.io/dry-run-plan-hash"
+	migrateAnnotation          = project.Name() + ".giantswarm.io/migrate"
+	migrateNotBeforeAnnotation = project.Name() + ".giantswarm.io/migrate-not-before"
+)
+
+type DryRun struct{}
+
+func (DryRun) Key() string { return dryRunAnnotation }
+
+func (DryRun) Val() string { return "true" }
+
+// IsSet returns true when dry-run is requested for the given object.
+func (DryRun) IsSet(meta metav1.Object) bool {
+	return meta.GetAnnotations()[DryRun{}.Key()] == DryRun{}.Val()
+}
+
+type DryRunPlanHash struct{}
+
+func (DryRunPlanHash) Key() string { return dryRunPlanHashAnnotation }
+
+// Get returns the hash of the emitted dry-run plan. Empty string is returned
+// when no plan has been emitted.
+func (DryRunPlanHash) Get(meta metav1.Object) string {
+	return meta.GetAnnotations()[DryRunPlanHash{}.Key()]
+}
+
+type Migrate struct{}
+
+func (Migrate) Key() string { return migrateAnnotation }
+
+func (Migrate) Val() string { return "true" }
+
+// IsSet returns true when migration is requested for the given object.
+func (Migrate) IsSet(meta metav1.Object) bool {
+	return meta.GetAnnotations()[Migrate{}.Key()] == Migrate{}.Val()
+}
+
+type MigrateNotBefore struct{}
+
+func (MigrateNotBefore) Key() string { return migrateNotBeforeAnnotation }
+
+// Get returns the RFC 3339 timestamp stored in the annotation. Zero time is
+// returned when the annotation is not set.
+func (MigrateNotBefore) Get(meta metav1.Object) (time.Time, error) {
+	v, ok := meta.GetAnnotations()[MigrateNotBefore{}.Key()]
+	if !ok || v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, microerror.Maskf(invalidAnnotationError, "annotation %q value %q is not a RFC 3339 timestamp", MigrateNotBefore{}.Key(), v)
+	}
+
+	return t, nil
+}
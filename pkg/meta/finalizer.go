@@ -0,0 +1,13 @@
+package meta
+
+import (
+	"github.com/giantswarm/capi-migration/pkg/project"
+)
+
+var (
+	migrationFinalizer = project.Name() + ".giantswarm.io/migration"
+)
+
+type Migration struct{}
+
+func (Migration) Key() string { return migrationFinalizer }
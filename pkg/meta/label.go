@@ -8,9 +8,20 @@ import (
 )
 
 var (
+	clusterLabel = project.Name() + ".giantswarm.io/cluster"
 	versionLabel = project.Name() + ".giantswarm.io/version"
 )
 
+type Cluster struct{}
+
+func (Cluster) Key() string { return clusterLabel }
+
+// Selector returns labels matching all objects created during migration of
+// the given cluster.
+func (Cluster) Selector(clusterID string) map[string]string {
+	return map[string]string{Cluster{}.Key(): clusterID}
+}
+
 type Version struct{}
 
 func (Version) Key() string { return versionLabel }
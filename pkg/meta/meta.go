@@ -2,13 +2,40 @@ package meta
 
 var (
 	Annotation AnnotationType
+	Finalizer  FinalizerType
 	Label      LabelType
 )
 
 type AnnotationType struct {
+	// DryRun is "capi-migration.giantswarm.io/dry-run" annotation. When set
+	// to "true" on a Cluster CR the migration is only planned.
+	DryRun
+	// DryRunPlanHash is "capi-migration.giantswarm.io/dry-run-plan-hash"
+	// annotation. It is set on ClusterMigration CRs to the hash of the
+	// emitted dry-run plan, so that the plan is emitted only once.
+	DryRunPlanHash
+	// Migrate is "capi-migration.giantswarm.io/migrate" annotation. Only
+	// Cluster CRs having it set to "true" are migrated.
+	Migrate
+	// MigrateNotBefore is "capi-migration.giantswarm.io/migrate-not-before"
+	// annotation. It holds an optional RFC 3339 timestamp before which
+	// migration of the Cluster CR is not started.
+	MigrateNotBefore
+}
+
+type FinalizerType struct {
+	// Migration is "capi-migration.giantswarm.io/migration" finalizer. It
+	// is set on Cluster CRs while their migration is in progress, so that
+	// objects created during migration can be removed when the cluster is
+	// deleted.
+	Migration
 }
 
 type LabelType struct {
+	// Cluster is "capi-migration.giantswarm.io/cluster" label. It is set to
+	// the cluster ID on all objects created during migration of the
+	// cluster.
+	Cluster
 	// Version is standard "capi-migration.giantswarm.io/version" label.
 	Version
 }
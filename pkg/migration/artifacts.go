@@ -0,0 +1,147 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+// artifactClient labels all objects it creates with meta.Label.Cluster, so
+// that they can be found and removed when the cluster is deleted during
+// migration.
+type artifactClient struct {
+	ctrl.Client
+
+	clusterID string
+}
+
+func newArtifactClient(c ctrl.Client, clusterID string) ctrl.Client {
+	return &artifactClient{
+		Client:    c,
+		clusterID: clusterID,
+	}
+}
+
+func (c *artifactClient) Create(ctx context.Context, obj runtime.Object, opts ...ctrl.CreateOption) error {
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[meta.Label.Cluster.Key()] = c.clusterID
+	accessor.SetLabels(labels)
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// artifactLists returns lists of all kinds migrators create in the
+// management cluster. Upstream CRs go first, so that upstream controllers
+// don't act on half deleted objects.
+func artifactLists() []runtime.Object {
+	return []runtime.Object{
+		&capi.MachineDeploymentList{},
+		&capiexp.MachinePoolList{},
+		&capaexp.AWSMachinePoolList{},
+		&bootstrap.KubeadmConfigList{},
+		&bootstrap.KubeadmConfigTemplateList{},
+		&kubeadm.KubeadmControlPlaneList{},
+		&capa.AWSMachineTemplateList{},
+		&capz.AzureMachineTemplateList{},
+		newByoList(kindByoMachineTemplate),
+		newByoList(kindByoCluster),
+		&corev1.SecretList{},
+		&corev1.ConfigMapList{},
+	}
+}
+
+// workloadArtifactLists returns lists of all kinds migrators create in the
+// workload cluster, like pods stopping and starting legacy master
+// components.
+func workloadArtifactLists() []runtime.Object {
+	return []runtime.Object{
+		&corev1.PodList{},
+	}
+}
+
+// DeleteArtifacts deletes all objects created in the management cluster
+// during migration of the given cluster. Objects are found by
+// meta.Label.Cluster. Kinds which are not installed in the management
+// cluster are skipped. Backups of migration attempts are kept, so the state
+// from before the migration can still be inspected after the cluster is
+// gone. Objects created in the workload cluster are deleted by
+// Migrator.DeleteWorkloadArtifacts.
+func DeleteArtifacts(ctx context.Context, c ctrl.Client, cluster *capi.Cluster) error {
+	err := deleteArtifacts(ctx, c, artifactLists(), cluster.Name)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// deleteArtifacts deletes objects of given kinds labelled with
+// meta.Label.Cluster of the given cluster, except backups of migration
+// attempts.
+func deleteArtifacts(ctx context.Context, c ctrl.Client, lists []runtime.Object, clusterID string) error {
+	for _, list := range lists {
+		err := c.List(ctx, list, ctrl.MatchingLabels(meta.Label.Cluster.Selector(clusterID)))
+		if apimeta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+			continue
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		for _, item := range items {
+			accessor, err := apimeta.Accessor(item)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			if _, ok := accessor.GetLabels()[backupAttemptLabel]; ok {
+				continue
+			}
+
+			err = deleteIfExists(ctx, c, item)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dropLegacyFinalizers removes finalizers of legacy operators from given
+// object, keeping only meta.Finalizer.Migration.
+func dropLegacyFinalizers(obj metav1.Object) {
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f == meta.Finalizer.Migration.Key() {
+			finalizers = append(finalizers, f)
+		}
+	}
+
+	obj.SetFinalizers(finalizers)
+}
@@ -0,0 +1,143 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+func Test_DeleteArtifacts(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = capi.AddToScheme(scheme)
+	_ = kubeadm.AddToScheme(scheme)
+
+	legacySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc12-encryption",
+			Namespace: "default",
+		},
+	}
+
+	cluster := newTestCluster(true)
+	c := fake.NewFakeClientWithScheme(scheme, cluster, legacySecret)
+	artifacts := newArtifactClient(c, cluster.Name)
+
+	created := []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "abc12-k8s-encryption-config",
+				Namespace: "default",
+			},
+		},
+		&kubeadm.KubeadmControlPlane{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "abc12-control-plane",
+				Namespace: "default",
+			},
+		},
+	}
+
+	for _, obj := range created {
+		err := artifacts.Create(ctx, obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if obj.(metav1.Object).GetLabels()[meta.Label.Cluster.Key()] != cluster.Name {
+			t.Fatalf("expected label %q to be set on %T", meta.Label.Cluster.Key(), obj)
+		}
+	}
+
+	backup := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc12-migration-backup-1",
+			Namespace: "default",
+			Labels:    map[string]string{backupAttemptLabel: "1"},
+		},
+	}
+	err := artifacts.Create(ctx, backup)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = DeleteArtifacts(ctx, c, cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	for _, obj := range created {
+		accessor := obj.(metav1.Object)
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, obj)
+		if !apierrors.IsNotFound(err) {
+			t.Fatalf("expected %T %s to be deleted, got %#v", obj, accessor.GetName(), err)
+		}
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: legacySecret.Namespace, Name: legacySecret.Name}, &corev1.Secret{})
+	if err != nil {
+		t.Fatalf("expected legacy secret to be kept, got %#v", err)
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: backup.Namespace, Name: backup.Name}, &corev1.Secret{})
+	if err != nil {
+		t.Fatalf("expected backup secret to be kept, got %#v", err)
+	}
+}
+
+func Test_migrationDriver_DeleteWorkloadArtifacts(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	userPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-pod",
+			Namespace: "kube-system",
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, userPod)
+	artifacts := newArtifactClient(c, "abc12")
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "disable-master-node-components-master-abc12-000000",
+			Namespace: "kube-system",
+		},
+	}
+	err := artifacts.Create(ctx, pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	d := newMigrationDriver(ProviderAzure, &migratorBase{clusterID: "abc12", wcCtrlClient: artifacts}, nil)
+	err = d.DeleteWorkloadArtifacts(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected pod %s to be deleted, got %#v", pod.Name, err)
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: userPod.Namespace, Name: userPod.Name}, &corev1.Pod{})
+	if err != nil {
+		t.Fatalf("expected pod %s to be kept, got %#v", userPod.Name, err)
+	}
+}
@@ -2,34 +2,62 @@ package migration
 
 import (
 	"context"
-	"fmt"
 
 	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
 	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
 	"github.com/giantswarm/apiextensions/v3/pkg/label"
-	"github.com/giantswarm/k8sclient/v4/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
 	vaultclient "github.com/hashicorp/vault/api"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/rest"
-	"sigs.k8s.io/cluster-api/api/v1alpha3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
 	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+	"github.com/giantswarm/capi-migration/pkg/migration/templates"
 )
 
 type AWSMigrationConfig struct {
 	// Migration configuration + dependencies such as k8s client.
 	AWSCredentials AWSConfig
 	CtrlClient     ctrl.Client
+	EventRecorder  record.EventRecorder
 	Logger         micrologger.Logger
+	Scheme         *runtime.Scheme
 	TenantCluster  tenantcluster.Interface
+	// VaultClient reads CAs of workload clusters. It is shared by all
+	// migrators and its token is kept valid by the caller.
+	VaultClient *vaultclient.Client
+
+	// DryRun makes migrators only render the migration plan for all
+	// clusters. It can be enabled for a single cluster with
+	// meta.Annotation.DryRun.
+	DryRun bool
+	// SSH is the SSH access policy for machines of migrated clusters. When
+	// SSHConfigMap is set, the policy stored there is merged over SSH for
+	// every new migrator.
+	SSH          SSHConfig
+	SSHConfigMap ctrl.ObjectKey
+	// TemplatesConfigMap overrides embedded CR templates with templates
+	// stored under their file names. It is read for every new migrator.
+	TemplatesConfigMap ctrl.ObjectKey
+
+	// NewAWSClients creates AWS API clients of workload cluster accounts.
+	// Defaults to NewAWSClients.
+	NewAWSClients AWSClientsFactory
 }
 
 type awsMigratorFactory struct {
-	config AWSMigrationConfig
+	config         AWSMigrationConfig
+	migratorConfig migratorConfig
 }
 
 type awsCRs struct {
@@ -42,128 +70,83 @@ type awsCRs struct {
 	g8sControlPlane     *giantswarmawsalpha3.G8sControlPlane
 	kubeadmControlPlane *kubeadm.KubeadmControlPlane
 
+	masterAWSMachineTemplate *capa.AWSMachineTemplate
+
 	awsMachineDeployments []giantswarmawsalpha3.AWSMachineDeployment
+
+	workersKubeadmConfigs  []*bootstrap.KubeadmConfig
+	workersAWSMachinePools []*capaexp.AWSMachinePool
+	workersMachinePools    []*capiexp.MachinePool
 }
 
 type awsMigrator struct {
-	awsClients     *awsClients
+	migratorBase
+
+	awsClients     *AWSClients
 	awsCredentials AWSConfig
-	clusterID      string
+	newAWSClients  AWSClientsFactory
 
 	crs awsCRs
-
-	// Migration configuration, dependencies + intermediate cache for involved
-	// CRs.
-	logger       micrologger.Logger
-	mcCtrlClient ctrl.Client
-	wcCtrlClient ctrl.Client
-	vaultClient  *vaultclient.Client
 }
 
 func NewAWSMigratorFactory(cfg AWSMigrationConfig) (MigratorFactory, error) {
-	return &awsMigratorFactory{
-		config: cfg,
-	}, nil
-}
-
-func (f *awsMigratorFactory) NewMigrator(cluster *v1alpha3.Cluster) (Migrator, error) {
-	url := fmt.Sprintf("%s:%d", cluster.Spec.ControlPlaneEndpoint.Host, cluster.Spec.ControlPlaneEndpoint.Port)
-	restConfig, err := f.config.TenantCluster.NewRestConfig(context.Background(), cluster.Name, url)
-	if err != nil {
-		return nil, microerror.Mask(err)
+	mc := migratorConfig{
+		CtrlClient:    cfg.CtrlClient,
+		EventRecorder: cfg.EventRecorder,
+		Logger:        cfg.Logger,
+		Scheme:        cfg.Scheme,
+		TenantCluster: cfg.TenantCluster,
+		VaultClient:   cfg.VaultClient,
+
+		DryRun:             cfg.DryRun,
+		SSH:                cfg.SSH,
+		SSHConfigMap:       cfg.SSHConfigMap,
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
 	}
 
-	k8sClient, err := k8sclient.NewClients(k8sclient.ClientsConfig{
-		Logger:     f.config.Logger,
-		RestConfig: rest.CopyConfig(restConfig),
-	})
-	if err != nil {
-		return nil, microerror.Mask(err)
-	}
-	// TODO set proper address and token
-	config := vaultclient.DefaultConfig()
-	vaultClient, err := vaultclient.NewClient(config)
+	err := mc.validate(cfg)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return &awsMigrator{
-		awsCredentials: f.config.AWSCredentials,
-		clusterID:      cluster.Name,
+	if cfg.NewAWSClients == nil {
+		cfg.NewAWSClients = NewAWSClients
+	}
 
-		// rest of the config from f.config...
-		logger:       f.config.Logger,
-		mcCtrlClient: f.config.CtrlClient,
-		wcCtrlClient: k8sClient.CtrlClient(),
-		vaultClient:  vaultClient,
+	return &awsMigratorFactory{
+		config:         cfg,
+		migratorConfig: mc,
 	}, nil
 }
 
-func (m *awsMigrator) IsMigrated(ctx context.Context) (bool, error) {
-	return false, nil
-}
-
-func (m *awsMigrator) IsMigrating(ctx context.Context) (bool, error) {
-	return false, nil
-}
-
-func (m *awsMigrator) Prepare(ctx context.Context) error {
-	var err error
-
-	err = m.migrateCertsSecrets(ctx)
+func (f *awsMigratorFactory) NewMigrator(cluster *capi.Cluster) (Migrator, error) {
+	base, err := newMigratorBase(f.migratorConfig, cluster)
 	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	err = m.readCRs(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	err = m.createAWSApiClients(ctx)
-	if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
 	}
 
-	err = m.prepareMissingCRs(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
+	m := &awsMigrator{
+		migratorBase: base,
 
-	err = m.updateCRs(ctx)
-	if err != nil {
-		return microerror.Mask(err)
+		awsCredentials: f.config.AWSCredentials,
+		newAWSClients:  f.config.NewAWSClients,
 	}
 
-	// need to figure out how to make run for both providers
-	//err = m.stopOldMasterComponents(ctx)
-	//if err != nil {
-	//	return microerror.Mask(err)
-	//}
-
-	return nil
+	return newMigrationDriver(ProviderAWS, &m.migratorBase, m), nil
 }
 
-func (m *awsMigrator) TriggerMigration(ctx context.Context) error {
-	err := m.triggerMigration(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	return nil
+func (m *awsMigrator) cluster() *capi.Cluster {
+	return m.crs.cluster
 }
 
-func (m *awsMigrator) Cleanup(ctx context.Context) error {
-	migrated, err := m.IsMigrated(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	if !migrated {
-		return fmt.Errorf("cluster has not migrated yet")
+func (m *awsMigrator) prepareSteps() []prepareStep {
+	return []prepareStep{
+		{name: "createAWSApiClients", run: m.createAWSApiClients},
+		{name: "prepareMissingCRs", run: m.prepareMissingCRs},
+		{name: "updateCRs", run: m.updateCRs},
+		// need to figure out how to make run for both providers
+		//{name: "stopOldMasterComponents", run: m.stopOldMasterComponents},
 	}
-
-	return nil
 }
 
 // readCRs reads existing CRs involved in migration. For AWS this contains
@@ -221,12 +204,15 @@ func (m *awsMigrator) readCRs(ctx context.Context) error {
 func (m *awsMigrator) prepareMissingCRs(ctx context.Context) error {
 	var err error
 
-	err = m.createEncryptionConfigSecret(ctx)
+	err = createEncryptionConfigSecret(ctx, m.mcCtrlClient, m.clusterID, m.clusterNamespace, m.crs.encryptionSecret)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
-	err = m.createCustomFilesSecret(ctx)
+	err = createCustomFilesSecret(ctx, m.mcCtrlClient, m.clusterID, m.clusterNamespace, templates.CustomFilesParams{
+		APIEndpoint:  key.AWSAPIEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
+		ETCDEndpoint: key.AWSEtcdEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
+	})
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -282,5 +268,60 @@ func (m *awsMigrator) updateCRs(ctx context.Context) error {
 // triggerMigration executes the last missing updates on CRs so that
 // reconciliation transistions to upstream controllers.
 func (m *awsMigrator) triggerMigration(ctx context.Context) error {
+	releaseComponents := getReleaseComponents(m.crs.release)
+
+	{
+		m.crs.cluster.Labels[label.ClusterOperatorVersion] = releaseComponents["cluster-operator"]
+		m.crs.cluster.Labels[watchFilterLabel] = releaseComponents["cluster-api-core"]
+		err := m.mcCtrlClient.Update(ctx, m.crs.cluster)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	{
+		m.crs.awsCluster.Labels[watchFilterLabel] = releaseComponents[awsInfrastructureComponent]
+		m.crs.awsCluster.Labels[label.ReleaseVersion] = m.crs.release.Name
+		err := m.mcCtrlClient.Update(ctx, m.crs.awsCluster)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	{
+		err := handOverToUpstream(ctx, m.mcCtrlClient, m.crs.kubeadmControlPlane, releaseComponents["cluster-api-control-plane"], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	{
+		err := handOverToUpstream(ctx, m.mcCtrlClient, m.crs.masterAWSMachineTemplate, releaseComponents[awsInfrastructureComponent], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, c := range m.crs.workersKubeadmConfigs {
+		err := handOverToUpstream(ctx, m.mcCtrlClient, c, releaseComponents["cluster-api-bootstrap-provider-kubeadm"], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, mp := range m.crs.workersAWSMachinePools {
+		err := handOverToUpstream(ctx, m.mcCtrlClient, mp, releaseComponents[awsInfrastructureComponent], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	for _, mp := range m.crs.workersMachinePools {
+		err := handOverToUpstream(ctx, m.mcCtrlClient, mp, releaseComponents["cluster-api-core"], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
 	return nil
 }
@@ -8,8 +8,13 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
 	"github.com/giantswarm/microerror"
 	corev1 "k8s.io/api/core/v1"
@@ -24,12 +29,18 @@ type AWSConfig struct {
 	RoleARN string
 }
 
-type awsClients struct {
-	asgClient     *autoscaling.AutoScaling
-	ec2Client     *ec2.EC2
-	route53Client *route53.Route53
+// AWSClients are AWS API clients of a single workload cluster account.
+type AWSClients struct {
+	ASG            autoscalingiface.AutoScalingAPI
+	CloudFormation cloudformationiface.CloudFormationAPI
+	EC2            ec2iface.EC2API
+	Route53        route53iface.Route53API
 }
 
+// AWSClientsFactory creates AWS API clients assuming config.RoleARN in
+// config.Region. NewAWSClients is the default, tests replace it with fakes.
+type AWSClientsFactory func(config AWSConfig) (*AWSClients, error)
+
 // createAWSApiClients create all necessary aws api clients fro later use
 func (m *awsMigrator) createAWSApiClients(ctx context.Context) error {
 	arn, err := m.getClusterCredentialARN(ctx, m.crs.awsCluster)
@@ -39,7 +50,7 @@ func (m *awsMigrator) createAWSApiClients(ctx context.Context) error {
 	m.awsCredentials.RoleARN = arn
 	m.awsCredentials.Region = m.crs.awsCluster.Spec.Provider.Region
 
-	awsClients, err := getAWSClients(m.awsCredentials)
+	awsClients, err := m.newAWSClients(m.awsCredentials)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -61,7 +72,9 @@ func (m *awsMigrator) getClusterCredentialARN(ctx context.Context, cr *giantswar
 	return string(secret.Data["aws.awsoperator.arn"]), nil
 }
 
-func getAWSClients(config AWSConfig) (*awsClients, error) {
+// NewAWSClients creates AWS API clients authenticated with the management
+// cluster credentials, which assume config.RoleARN.
+func NewAWSClients(config AWSConfig) (*AWSClients, error) {
 	var err error
 	var s *session.Session
 	{
@@ -80,10 +93,11 @@ func getAWSClients(config AWSConfig) (*awsClients, error) {
 		Credentials: stscreds.NewCredentials(s, config.RoleARN),
 	}
 
-	o := &awsClients{
-		ec2Client:     ec2.New(s, credentialsConfig),
-		route53Client: route53.New(s, credentialsConfig),
-		asgClient:     autoscaling.New(s, credentialsConfig),
+	o := &AWSClients{
+		ASG:            autoscaling.New(s, credentialsConfig),
+		CloudFormation: cloudformation.New(s, credentialsConfig),
+		EC2:            ec2.New(s, credentialsConfig),
+		Route53:        route53.New(s, credentialsConfig),
 	}
 
 	return o, nil
@@ -0,0 +1,381 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+const (
+	awsTagCluster           = "giantswarm.io/cluster"
+	awsTagMachineDeployment = "giantswarm.io/machine-deployment"
+	awsTagStack             = "giantswarm.io/stack"
+
+	awsStackControlPlaneNodes = "tccpn"
+	awsStackNodePool          = "tcnp"
+)
+
+func (m *awsMigrator) cleanup(ctx context.Context) error {
+	err := m.readAWSCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readAWSMachineDeployments(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createAWSApiClients(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.ensureLegacyMastersAreDeleted(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.ensureLegacyNodePoolsAreDeleted(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.ensureLegacyStacksAreDeleted(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *awsMigrator) ensureLegacyMastersAreDeleted(ctx context.Context) error {
+	// Ensure legacy master ASGs exist or exit.
+	asgs, err := m.getLegacyASGs(ctx, awsStackControlPlaneNodes)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observeLegacyNodeGroups(m.clusterID, ProviderAWS, metricsRoleMaster, len(asgs))
+
+	if len(asgs) == 0 {
+		m.logger.Debugf(ctx, "no legacy master ASG found")
+		return nil
+	}
+
+	// Check if the new master exists and is ready or wait.
+	err = ensureNewMasterIsReady(ctx, m.wcCtrlClient, m.events, fmt.Sprintf("%d legacy master ASGs", len(asgs)))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var asgsLeft int
+	for _, asg := range asgs {
+		exists, err := m.ensureASGIsDeleted(ctx, asg)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if exists {
+			asgsLeft++
+		}
+	}
+
+	if asgsLeft > 0 {
+		return microerror.Maskf(legacyASGDeletionInProgressError, "%d legacy master ASGs are still being deleted", asgsLeft)
+	}
+
+	return nil
+}
+
+func (m *awsMigrator) ensureLegacyNodePoolsAreDeleted(ctx context.Context) error {
+	// Ensure there are GS node pool ASGs still running or exit.
+	asgs, err := m.getLegacyASGs(ctx, awsStackNodePool)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observeLegacyNodeGroups(m.clusterID, ProviderAWS, metricsRoleNodePool, len(asgs))
+
+	if len(asgs) == 0 {
+		m.logger.Debugf(ctx, "no legacy node pool ASG found")
+		return nil
+	}
+
+	var oldWorkersCount int
+	for _, asg := range asgs {
+		oldWorkersCount += int(aws.Int64Value(asg.DesiredCapacity))
+	}
+
+	// Check there are at least `oldWorkersCount` CAPI workers in a `Ready` state.
+	err = ensureCAPIWorkersAreReady(ctx, m.logger, m.wcCtrlClient, m.events, oldWorkersCount, isAWSLegacyWorker)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "found %d legacy node pool ASGs to be deleted", len(asgs))
+
+	var asgsLeft int
+	for _, asg := range asgs {
+		exists, err := m.ensureASGIsDeleted(ctx, asg)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if exists {
+			asgsLeft++
+		}
+	}
+
+	if asgsLeft > 0 {
+		return microerror.Maskf(legacyASGDeletionInProgressError, "%d legacy node pool ASGs are still being deleted", asgsLeft)
+	}
+
+	return nil
+}
+
+// ensureLegacyStacksAreDeleted deletes aws-operator CloudFormation stacks
+// which contained the already deleted ASGs. Node pool stacks go first,
+// control plane nodes stack goes only after all node pool stacks are gone.
+// The tccp, tccpi and tccpf stacks are left in place, because they own VPC,
+// IAM and DNS resources which are still in use by the migrated cluster.
+func (m *awsMigrator) ensureLegacyStacksAreDeleted(ctx context.Context) error {
+	var nodePoolStacksLeft int
+	for _, d := range m.crs.awsMachineDeployments {
+		exists, err := m.ensureStackIsDeleted(ctx, key.AWSLegacyNodePoolStackName(m.clusterID, d.Name))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if exists {
+			nodePoolStacksLeft++
+		}
+	}
+
+	if nodePoolStacksLeft > 0 {
+		return microerror.Maskf(legacyStackDeletionInProgressError, "%d legacy node pool stacks are still being deleted", nodePoolStacksLeft)
+	}
+
+	exists, err := m.ensureStackIsDeleted(ctx, key.AWSLegacyControlPlaneNodesStackName(m.clusterID))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if exists {
+		return microerror.Maskf(legacyStackDeletionInProgressError, "legacy control plane nodes stack is still being deleted")
+	}
+
+	return nil
+}
+
+// ensureStackIsDeleted requests deletion of the given stack. It returns true
+// when the stack still exists.
+func (m *awsMigrator) ensureStackIsDeleted(ctx context.Context, stackName string) (bool, error) {
+	o, err := m.awsClients.CloudFormation.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if IsAWSStackNotFound(err) {
+		m.logger.Debugf(ctx, "stack %q not found", stackName)
+		return false, nil
+	} else if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	if len(o.Stacks) == 0 || aws.StringValue(o.Stacks[0].StackStatus) == cloudformation.StackStatusDeleteComplete {
+		m.logger.Debugf(ctx, "stack %q already deleted", stackName)
+		return false, nil
+	}
+
+	if aws.StringValue(o.Stacks[0].StackStatus) == cloudformation.StackStatusDeleteInProgress {
+		m.logger.Debugf(ctx, "stack %q is being deleted", stackName)
+		return true, nil
+	}
+
+	m.logger.Debugf(ctx, "deleting stack %q", stackName)
+
+	_, err = m.awsClients.CloudFormation.DeleteStack(&cloudformation.DeleteStackInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "requested deletion of stack %q", stackName)
+	m.events.normalf(EventReasonLegacyResourceDeleted, "CloudFormation stack %s deletion requested", stackName)
+
+	return true, nil
+}
+
+// getLegacyASGs finds ASGs created by aws-operator for the cluster in the
+// given stack type.
+func (m *awsMigrator) getLegacyASGs(ctx context.Context, stack string) ([]*autoscaling.Group, error) {
+	var names []*string
+	{
+		i := &autoscaling.DescribeTagsInput{
+			Filters: []*autoscaling.Filter{
+				{
+					Name:   aws.String("key"),
+					Values: aws.StringSlice([]string{awsTagCluster}),
+				},
+				{
+					Name:   aws.String("value"),
+					Values: aws.StringSlice([]string{m.clusterID}),
+				},
+			},
+		}
+
+		err := m.awsClients.ASG.DescribeTagsPages(i, func(o *autoscaling.DescribeTagsOutput, lastPage bool) bool {
+			for _, t := range o.Tags {
+				names = append(names, t.ResourceId)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var asgs []*autoscaling.Group
+	{
+		i := &autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: names,
+		}
+
+		err := m.awsClients.ASG.DescribeAutoScalingGroupsPages(i, func(o *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			for _, asg := range o.AutoScalingGroups {
+				if asgTagValue(asg, awsTagStack) != stack {
+					continue
+				}
+				if asg.Status != nil {
+					// ASG is already being deleted.
+					continue
+				}
+				asgs = append(asgs, asg)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	return asgs, nil
+}
+
+// ensureASGIsDeleted removes the legacy ASG without disrupting workloads.
+// Nodes of the ASG instances are drained first, then the ASG is scaled down
+// to zero and it is deleted only after all its instances are terminated.
+// It returns true when the ASG still exists.
+func (m *awsMigrator) ensureASGIsDeleted(ctx context.Context, asg *autoscaling.Group) (bool, error) {
+	name := aws.StringValue(asg.AutoScalingGroupName)
+
+	if aws.Int64Value(asg.MaxSize) > 0 {
+		podsLeft, err := m.drainASGNodes(ctx, asg)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		if podsLeft > 0 {
+			m.logger.Debugf(ctx, "waiting for %d pods to be evicted from nodes of ASG %q", podsLeft, name)
+			return true, nil
+		}
+
+		m.logger.Debugf(ctx, "scaling down ASG %q (machine deployment %q)", name, asgTagValue(asg, awsTagMachineDeployment))
+
+		_, err = m.awsClients.ASG.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+			AutoScalingGroupName: asg.AutoScalingGroupName,
+			DesiredCapacity:      aws.Int64(0),
+			MaxSize:              aws.Int64(0),
+			MinSize:              aws.Int64(0),
+		})
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		m.logger.Debugf(ctx, "scaled down ASG %q", name)
+		m.events.normalf(EventReasonLegacyResourceDeleted, "ASG %s scaled down", name)
+	}
+
+	if len(asg.Instances) > 0 {
+		m.logger.Debugf(ctx, "waiting for %d instances of ASG %q to be terminated", len(asg.Instances), name)
+		return true, nil
+	}
+
+	m.logger.Debugf(ctx, "deleting ASG %q", name)
+
+	_, err := m.awsClients.ASG.DeleteAutoScalingGroup(&autoscaling.DeleteAutoScalingGroupInput{
+		AutoScalingGroupName: asg.AutoScalingGroupName,
+	})
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "deleted ASG %q", name)
+	m.events.normalf(EventReasonLegacyResourceDeleted, "ASG %s deleted", name)
+
+	return false, nil
+}
+
+// drainASGNodes drains workload cluster nodes running on instances of the
+// given ASG. Nodes are matched by the instance ID in their provider ID. It
+// returns the number of pods still to be evicted.
+func (m *awsMigrator) drainASGNodes(ctx context.Context, asg *autoscaling.Group) (int, error) {
+	if len(asg.Instances) == 0 {
+		return 0, nil
+	}
+
+	nodes, err := m.wcK8sClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	var podsLeft int
+	for _, instance := range asg.Instances {
+		suffix := "/" + aws.StringValue(instance.InstanceId)
+
+		for i := range nodes.Items {
+			if !strings.HasSuffix(nodes.Items[i].Spec.ProviderID, suffix) {
+				continue
+			}
+
+			m.logger.Debugf(ctx, "draining node %q of ASG %q", nodes.Items[i].Name, aws.StringValue(asg.AutoScalingGroupName))
+
+			n, err := drainNode(ctx, m.wcK8sClient, &nodes.Items[i])
+			if err != nil {
+				return 0, microerror.Mask(err)
+			}
+
+			podsLeft += n
+		}
+	}
+
+	return podsLeft, nil
+}
+
+func asgTagValue(asg *autoscaling.Group, key string) string {
+	for _, t := range asg.Tags {
+		if aws.StringValue(t.Key) == key {
+			return aws.StringValue(t.Value)
+		}
+	}
+
+	return ""
+}
+
+func isAWSLegacyWorker(node corev1.Node) bool {
+	_, ok := node.Labels["giantswarm.io/machine-deployment"]
+	return ok
+}
@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/giantswarm/micrologger/microloggertest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeASG records calls changing ASGs.
+type fakeASG struct {
+	autoscalingiface.AutoScalingAPI
+
+	updated []string
+	deleted []*autoscaling.DeleteAutoScalingGroupInput
+}
+
+func (f *fakeASG) UpdateAutoScalingGroup(i *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	f.updated = append(f.updated, aws.StringValue(i.AutoScalingGroupName))
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (f *fakeASG) DeleteAutoScalingGroup(i *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	f.deleted = append(f.deleted, i)
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+func Test_awsMigrator_ensureASGIsDeleted(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ip-10-1-6-1",
+		},
+		Spec: corev1.NodeSpec{
+			ProviderID: "aws:///eu-west-1a/i-0a1b2c",
+		},
+	}
+	appPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node.Name,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+	daemonSetPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "node-exporter",
+			Namespace: "kube-system",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Name: "node-exporter"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node.Name,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		asg             *autoscaling.Group
+		objects         []runtime.Object
+		expectedExists  bool
+		expectedUpdated int
+		expectedDeleted int
+		expectCordoned  bool
+	}{
+		{
+			name: "case 0: ASG is not scaled down before pods are evicted",
+			asg: &autoscaling.Group{
+				AutoScalingGroupName: aws.String("a1b2c-tcnp-asg"),
+				MaxSize:              aws.Int64(1),
+				Instances:            []*autoscaling.Instance{{InstanceId: aws.String("i-0a1b2c")}},
+			},
+			objects:         []runtime.Object{node.DeepCopy(), appPod, daemonSetPod},
+			expectedExists:  true,
+			expectedUpdated: 0,
+			expectedDeleted: 0,
+			expectCordoned:  true,
+		},
+		{
+			name: "case 1: drained ASG is scaled down and not deleted while instances run",
+			asg: &autoscaling.Group{
+				AutoScalingGroupName: aws.String("a1b2c-tcnp-asg"),
+				MaxSize:              aws.Int64(1),
+				Instances:            []*autoscaling.Instance{{InstanceId: aws.String("i-0a1b2c")}},
+			},
+			objects:         []runtime.Object{node.DeepCopy(), daemonSetPod},
+			expectedExists:  true,
+			expectedUpdated: 1,
+			expectedDeleted: 0,
+			expectCordoned:  true,
+		},
+		{
+			name: "case 2: scaled down ASG without instances is deleted",
+			asg: &autoscaling.Group{
+				AutoScalingGroupName: aws.String("a1b2c-tcnp-asg"),
+				MaxSize:              aws.Int64(0),
+			},
+			objects:         []runtime.Object{node.DeepCopy()},
+			expectedExists:  false,
+			expectedUpdated: 0,
+			expectedDeleted: 1,
+			expectCordoned:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			asg := &fakeASG{}
+			k8sClient := fake.NewSimpleClientset(tc.objects...)
+
+			m := &awsMigrator{
+				migratorBase: migratorBase{
+					clusterID:   "a1b2c",
+					logger:      microloggertest.New(),
+					wcK8sClient: k8sClient,
+					events:      newMigrationEvents(record.NewFakeRecorder(10), newTestCluster(false), false),
+				},
+				awsClients: &AWSClients{ASG: asg},
+			}
+
+			exists, err := m.ensureASGIsDeleted(ctx, tc.asg)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if exists != tc.expectedExists {
+				t.Fatalf("exists = %t, want %t", exists, tc.expectedExists)
+			}
+			if len(asg.updated) != tc.expectedUpdated {
+				t.Fatalf("ASG updated %d times, want %d", len(asg.updated), tc.expectedUpdated)
+			}
+			if len(asg.deleted) != tc.expectedDeleted {
+				t.Fatalf("ASG deleted %d times, want %d", len(asg.deleted), tc.expectedDeleted)
+			}
+			for _, i := range asg.deleted {
+				if aws.BoolValue(i.ForceDelete) {
+					t.Fatalf("ASG %q deleted with ForceDelete", aws.StringValue(i.AutoScalingGroupName))
+				}
+			}
+
+			n, err := k8sClient.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+			if n.Spec.Unschedulable != tc.expectCordoned {
+				t.Fatalf("node unschedulable = %t, want %t", n.Spec.Unschedulable, tc.expectCordoned)
+			}
+		})
+	}
+}
@@ -14,298 +14,105 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
 	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
 	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
-	bootstraptypes "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
 	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
-	"github.com/giantswarm/capi-migration/pkg/migration/templates"
 )
 
 const (
-	joinEtcdClusterScriptKey = "join-etcd-cluster"
-	encryptionKeyKey         = "encryption"
-	kubeProxyConfigKey       = "kubeproxy-config"
+	// awsInfrastructureComponent is the release component of the
+	// infrastructure provider reconciling AWSCluster, AWSMachineTemplate and
+	// AWSMachinePool.
+	awsInfrastructureComponent = "cluster-api-provider-aws"
 )
 
-func (m *awsMigrator) createEncryptionConfigSecret(ctx context.Context) error {
-	encryptionConfigTmpl := `
-kind: EncryptionConfiguration
-apiVersion: apiserver.config.k8s.io/v1
-resources:
-  - resources:
-    - secrets
-    providers:
-    - aescbc:
-        keys:
-        - name: key1
-          secret: %s
-    - identity: {}`
-
-	renderedConfig := fmt.Sprintf(encryptionConfigTmpl, m.crs.encryptionSecret.Data["encryption"])
-
-	s := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-k8s-encryption-config", m.clusterID),
-			Namespace: "default",
-		},
-		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			"encryption": renderedConfig,
-		},
-	}
-
-	err := m.mcCtrlClient.Create(ctx, s)
-	if apierrors.IsAlreadyExists(err) {
-		// It's fine. No worries.
-	} else if err != nil {
-		return microerror.Mask(err)
-	}
+// awsKubeadmControlPlaneParams are parameters of
+// kubeadm_controlplane_aws.yaml.tmpl.
+type awsKubeadmControlPlaneParams struct {
+	Name                string
+	Namespace           string
+	MachineTemplateName string
+	APIEndpoint         string
+	K8sVersion          string
+	Files               []bootstrap.File
+	Users               []bootstrap.User
+}
 
-	return nil
+// awsMachineTemplateParams are parameters of
+// controlplane_aws_machine_template.yaml.tmpl. Empty SSHKeyName means no key
+// pair.
+type awsMachineTemplateParams struct {
+	Name            string
+	Namespace       string
+	InstanceType    string
+	SSHKeyName      string
+	SecurityGroupID string
 }
 
-func (m *awsMigrator) createCustomFilesSecret(ctx context.Context) error {
-	namespace := "default"
-	params := templates.CustomFilesParams{
-		APIEndpoint:  key.AWSAPIEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
-		ETCDEndpoint: key.AWSEtcdEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
-	}
+// awsKubeadmConfigParams are parameters of workers_kubeadm_config_aws.yaml.tmpl.
+type awsKubeadmConfigParams struct {
+	Name                  string
+	Namespace             string
+	CustomFilesSecretName string
+	Users                 []bootstrap.User
+}
 
-	joinEtcdClusterContent, err := templates.RenderTemplate(templates.AWSJoinCluster, params)
-	if err != nil {
-		return microerror.Mask(err)
-	}
+// awsMachinePoolParams are parameters of workers_aws_machine_pool.yaml.tmpl.
+// Empty SSHKeyName means no key pair.
+type awsMachinePoolParams struct {
+	Name               string
+	Namespace          string
+	LaunchTemplateName string
+	InstanceType       string
+	SSHKeyName         string
+	SecurityGroupID    string
+	MinSize            int
+	MaxSize            int
+	Subnets            []awsSubnet
+}
 
-	s := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      key.AWSCustomFilesSecretName(m.clusterID),
-			Namespace: namespace,
-		},
-		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			joinEtcdClusterScriptKey: joinEtcdClusterContent,
-			kubeProxyConfigKey:       templates.KubeProxyConfig,
-		},
-	}
-	err = m.mcCtrlClient.Create(ctx, s)
-	if apierrors.IsAlreadyExists(err) {
-		// It's fine. No worries.
-	} else if err != nil {
-		return microerror.Mask(err)
-	}
+type awsSubnet struct {
+	ID               string
+	AvailabilityZone string
+}
 
-	return nil
+// awsWorkersMachinePoolParams are parameters of
+// workers_machine_pool_aws.yaml.tmpl.
+type awsWorkersMachinePoolParams struct {
+	ClusterID  string
+	Name       string
+	Namespace  string
+	K8sVersion string
+	Replicas   int
 }
 
 func (m *awsMigrator) createKubeadmControlPlane(ctx context.Context) error {
-	replicas := int32(1)
 	releaseComponents := getReleaseComponents(m.crs.release)
 
-	kcp := &kubeadm.KubeadmControlPlane{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      key.AWSKubeadmControlPlaneName(m.clusterID),
-			Namespace: m.crs.g8sControlPlane.Namespace,
-		},
-		Spec: kubeadm.KubeadmControlPlaneSpec{
-			InfrastructureTemplate: corev1.ObjectReference{
-				APIVersion: capa.GroupVersion.String(),
-				Name:       key.AWSMachineTemplateNameForCP(m.clusterID),
-				Kind:       "AWSMachineTemplate",
-			},
-			KubeadmConfigSpec: bootstrap.KubeadmConfigSpec{
-				ClusterConfiguration: &bootstraptypes.ClusterConfiguration{
-					APIServer: bootstraptypes.APIServer{
-						ControlPlaneComponent: bootstraptypes.ControlPlaneComponent{
-							ExtraArgs: map[string]string{
-								"cloud-provider":             "aws",
-								"etcd-prefix":                "giantswarm.io",
-								"encryption-provider-config": "/etc/kubernetes/encryption/k8s-encryption-config.yaml",
-							},
-							ExtraVolumes: []bootstraptypes.HostPathMount{
-								{
-									Name:      "encryption",
-									HostPath:  "/etc/kubernetes/encryption/",
-									MountPath: "/etc/kubernetes/encryption/",
-								},
-							},
-						},
-						CertSANs: []string{
-							key.AWSAPIEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
-						},
-					},
-					ControllerManager: bootstraptypes.ControlPlaneComponent{
-						ExtraArgs: map[string]string{
-							"cloud-provider": "aws",
-						},
-					},
-					Etcd: bootstraptypes.Etcd{
-						Local: &bootstraptypes.LocalEtcd{
-							DataDir: "/var/lib/etcd/data",
-							ExtraArgs: map[string]string{
-								"initial-cluster-state":                          "existing",
-								"initial-cluster":                                "$ETCD_INITIAL_CLUSTER",
-								"experimental-peer-skip-client-san-verification": "true",
-							},
-						},
-					},
-				},
-				InitConfiguration: &bootstraptypes.InitConfiguration{
-					NodeRegistration: bootstraptypes.NodeRegistrationOptions{
-						KubeletExtraArgs: map[string]string{
-							"cloud-provider": "aws",
-						},
-						Name: "{{ ds.meta_data.local_hostname }}",
-					},
-					LocalAPIEndpoint: bootstraptypes.APIEndpoint{
-						BindPort: 443,
-					},
-				},
-				JoinConfiguration: &bootstraptypes.JoinConfiguration{
-					NodeRegistration: bootstraptypes.NodeRegistrationOptions{
-						KubeletExtraArgs: map[string]string{
-							"cloud-provider": "aws",
-						},
-						Name: "{{ ds.meta_data.local_hostname }}",
-					},
-				},
-				Files: []bootstrap.File{
-					{
-						Path:  "/migration/join-existing-cluster.sh",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.AWSCustomFilesSecretName(m.clusterID),
-								Key:  joinEtcdClusterScriptKey,
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/encryption/k8s-encryption-config.yaml",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.EncryptionConfigSecretName(m.clusterID),
-								Key:  encryptionKeyKey,
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/config/proxy-config.yml",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.AWSCustomFilesSecretName(m.clusterID),
-								Key:  kubeProxyConfigKey,
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/ca.crt",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.CACertsSecretName(m.clusterID),
-								Key:  "tls.crt",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/ca.key",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.CACertsSecretName(m.clusterID),
-								Key:  "tls.key",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/etcd/ca.key",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.CACertsSecretName(m.clusterID),
-								Key:  "tls.key",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/etcd/ca.crt",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.CACertsSecretName(m.clusterID),
-								Key:  "tls.crt",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/sa.pub",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.SACertsSecretName(m.clusterID),
-								Key:  "tls.crt",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/sa.key",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.SACertsSecretName(m.clusterID),
-								Key:  "tls.key",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/etcd/old.key",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.EtcdCertsSecretName(m.clusterID),
-								Key:  "key",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/pki/etcd/old.crt",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.EtcdCertsSecretName(m.clusterID),
-								Key:  "crt",
-							},
-						},
-					},
-				},
-				PreKubeadmCommands: []string{
-					"hostnamectl set-hostname $(curl http://169.254.169.254/latest/meta-data/local-hostname) # set proper hostname - necessary for kubeProxy to detect node name",
-					"iptables -A PREROUTING -t nat  -p tcp --dport 6443 -j REDIRECT --to-port 443 # route traffic from 6443 to 443",
-					"/bin/sh /migration/join-existing-cluster.sh",
-				},
-				Users: []bootstrap.User{
-					{
-						Name: "calvix",
-						SSHAuthorizedKeys: []string{
-							"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC9IyAZvlEL7lrxDghpqWjs/z/q4E0OtEbmKW9oD0zhYfyHIaX33YYoj3iC7oEd6OEvY4+L4awjRZ2FrXerN/tTg9t1zrW7f7Tah/SnS9XYY9zyo4uzuq1Pa6spOkjpcjtXbQwdQSATD0eeLraBWWVBDIg1COAMsAhveP04UaXAKGSQst6df007dIS5pmcATASNNBc9zzBmJgFwPDLwVviYqoqcYTASka4fSQhQ+fSj9zO1pgrCvvsmA/QeHz2Cn5uFzjh8ftqkM10sjiYibknsBuvVKZ2KpeTY6XoTOT0d9YWoJpfqAEE00+RmYLqDTQGWm5pRuZSc9vbnnH2MiEKf calvix@xxxx",
-						},
-					},
-				},
-			},
-			Replicas: &replicas,
-			Version:  releaseComponents["K8sVersion"],
-		},
+	params := awsKubeadmControlPlaneParams{
+		Name:                key.AWSKubeadmControlPlaneName(m.clusterID),
+		Namespace:           m.crs.g8sControlPlane.Namespace,
+		MachineTemplateName: key.AWSMachineTemplateNameForCP(m.clusterID),
+		APIEndpoint:         key.AWSAPIEndpointFromDomain(m.crs.awsCluster.Spec.Cluster.DNS.Domain, m.clusterID),
+		K8sVersion:          kubernetesVersion(releaseComponents["kubernetes"]),
+		Files:               controlPlaneMigrationFiles(m.clusterID),
+		Users:               m.ssh.bootstrapUsers(),
 	}
 
-	err := m.mcCtrlClient.Create(ctx, kcp)
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err := m.templates.render("kubeadm_controlplane_aws.yaml.tmpl", params, kcp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, kcp)
 	if apierrors.IsAlreadyExists(err) {
 		// It's ok. It's already there.
 	} else if err != nil {
@@ -319,7 +126,7 @@ func (m *awsMigrator) createKubeadmControlPlane(ctx context.Context) error {
 }
 
 func (m *awsMigrator) createMasterAWSMachineTemplate(ctx context.Context) error {
-	var masterSecurityGroupID *string
+	var masterSecurityGroupID string
 	{
 		i := &ec2.DescribeSecurityGroupsInput{
 			Filters: []*ec2.Filter{
@@ -329,108 +136,66 @@ func (m *awsMigrator) createMasterAWSMachineTemplate(ctx context.Context) error
 				},
 			},
 		}
-		o, err := m.awsClients.ec2Client.DescribeSecurityGroups(i)
+		o, err := m.awsClients.EC2.DescribeSecurityGroups(i)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 		if len(o.SecurityGroups) != 1 {
 			return microerror.Maskf(nil, "expected 1 master security group but found %d", len(o.SecurityGroups))
 		}
-		masterSecurityGroupID = o.SecurityGroups[0].GroupId
+		masterSecurityGroupID = aws.StringValue(o.SecurityGroups[0].GroupId)
 	}
 
-	machineTemplate := &capa.AWSMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      key.AWSMachineTemplateNameForCP(m.clusterID),
-			Namespace: m.crs.awsControlPlane.Namespace,
-		},
-		Spec: capa.AWSMachineTemplateSpec{
-			Template: capa.AWSMachineTemplateResource{
-				Spec: capa.AWSMachineSpec{
-					IAMInstanceProfile: "control-plane.cluster-api-provider-aws.sigs.k8s.io",
-					InstanceType:       m.crs.awsControlPlane.Spec.InstanceType,
-					SSHKeyName:         aws.String("vaclav"),
-					AdditionalSecurityGroups: []capa.AWSResourceReference{
-						{
-							ID: masterSecurityGroupID,
-						},
-					},
-				},
-			},
-		},
+	params := awsMachineTemplateParams{
+		Name:            key.AWSMachineTemplateNameForCP(m.clusterID),
+		Namespace:       m.crs.awsControlPlane.Namespace,
+		InstanceType:    m.crs.awsControlPlane.Spec.InstanceType,
+		SSHKeyName:      aws.StringValue(m.ssh.awsKeyPairName()),
+		SecurityGroupID: masterSecurityGroupID,
 	}
 
-	err := m.mcCtrlClient.Create(ctx, machineTemplate)
+	machineTemplate := &capa.AWSMachineTemplate{}
+	err := m.templates.render("controlplane_aws_machine_template.yaml.tmpl", params, machineTemplate)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, machineTemplate)
 	if apierrors.IsAlreadyExists(err) {
 		// It's ok. It's already there.
 	} else if err != nil {
 		return microerror.Mask(err)
 	}
 
+	m.crs.masterAWSMachineTemplate = machineTemplate
+
 	return nil
 }
 
 func (m *awsMigrator) createWorkersKubeadmConfigTemplate(ctx context.Context) error {
 	// iterate over all nodepools (AWSMachineDeployments)
 	for _, d := range m.crs.awsMachineDeployments {
+		params := awsKubeadmConfigParams{
+			Name:                  key.AWSMachinePoolName(m.clusterID, d.Name),
+			Namespace:             d.Namespace,
+			CustomFilesSecretName: key.CustomFilesSecretName(m.clusterID),
+			Users:                 m.ssh.bootstrapUsers(),
+		}
 
-		c := &bootstrap.KubeadmConfig{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      key.AWSMachinePoolName(m.clusterID, d.Name),
-				Namespace: d.Namespace,
-			},
-			Spec: bootstrap.KubeadmConfigSpec{
-				PreKubeadmCommands: []string{
-					"hostnamectl set-hostname $(curl http://169.254.169.254/latest/meta-data/local-hostname)",
-				},
-				InitConfiguration: &bootstraptypes.InitConfiguration{
-					NodeRegistration: bootstraptypes.NodeRegistrationOptions{
-						KubeletExtraArgs: map[string]string{
-							"cloud-provider": "aws",
-						},
-						Name: "{{ ds.meta_data.local_hostname }}",
-					},
-				},
-				JoinConfiguration: &bootstraptypes.JoinConfiguration{
-					NodeRegistration: bootstraptypes.NodeRegistrationOptions{
-						KubeletExtraArgs: map[string]string{
-							"cloud-provider": "aws",
-							"node-labels":    "node.kubernetes.io/worker,role=worker",
-						},
-						Name: "{{ ds.meta_data.local_hostname }}",
-					},
-				},
-				Files: []bootstrap.File{
-					{
-						Path:  "/etc/kubernetes/config/kube-proxy.yaml",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.AWSCustomFilesSecretName(m.clusterID),
-								Key:  "kubeProxyKubeconfigKey",
-							},
-						},
-					},
-					{
-						Path:  "/etc/kubernetes/config/proxy-config.yml",
-						Owner: "root:root",
-						ContentFrom: &bootstrap.FileSource{
-							Secret: bootstrap.SecretFileSource{
-								Name: key.AWSCustomFilesSecretName(m.clusterID),
-								Key:  kubeProxyConfigKey,
-							},
-						},
-					},
-				},
-			},
+		c := &bootstrap.KubeadmConfig{}
+		err := m.templates.render("workers_kubeadm_config_aws.yaml.tmpl", params, c)
+		if err != nil {
+			return microerror.Mask(err)
 		}
 
-		err := m.mcCtrlClient.Create(ctx, c)
+		err = m.mcCtrlClient.Create(ctx, c)
 		if apierrors.IsAlreadyExists(err) {
 			// It's ok. It's already there.
 		} else if err != nil {
 			return microerror.Mask(err)
 		}
+
+		m.crs.workersKubeadmConfigs = append(m.crs.workersKubeadmConfigs, c)
 	}
 
 	return nil
@@ -453,7 +218,7 @@ func (m *awsMigrator) createWorkersAWSMachinePools(ctx context.Context) error {
 			},
 		}
 
-		o, err := m.awsClients.ec2Client.DescribeSecurityGroups(i)
+		o, err := m.awsClients.EC2.DescribeSecurityGroups(i)
 		if err != nil {
 			return microerror.Mask(err)
 		}
@@ -470,37 +235,34 @@ func (m *awsMigrator) createWorkersAWSMachinePools(ctx context.Context) error {
 			},
 		}
 
-		o2, err := m.awsClients.ec2Client.DescribeSubnets(i2)
+		o2, err := m.awsClients.EC2.DescribeSubnets(i2)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
 		// Create the CR
-		awsmp := &capaexp.AWSMachinePool{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      key.AWSMachinePoolName(m.clusterID, d.Name),
-				Namespace: d.Namespace,
-			},
-			Spec: capaexp.AWSMachinePoolSpec{
-				MinSize: int32(d.Spec.NodePool.Scaling.Min),
-				MaxSize: int32(d.Spec.NodePool.Scaling.Max),
-				AWSLaunchTemplate: capaexp.AWSLaunchTemplate{
-					Name:               d.Name,
-					InstanceType:       d.Spec.Provider.Worker.InstanceType,
-					SSHKeyName:         aws.String("vaclav"),
-					IamInstanceProfile: "nodes.cluster-api-provider-aws.sigs.k8s.io",
-					AdditionalSecurityGroups: []capa.AWSResourceReference{
-						{
-							ID: o.SecurityGroups[0].GroupId,
-						},
-					},
-				},
-			},
+		params := awsMachinePoolParams{
+			Name:               key.AWSMachinePoolName(m.clusterID, d.Name),
+			Namespace:          d.Namespace,
+			LaunchTemplateName: d.Name,
+			InstanceType:       d.Spec.Provider.Worker.InstanceType,
+			SSHKeyName:         aws.StringValue(m.ssh.awsKeyPairName()),
+			SecurityGroupID:    aws.StringValue(o.SecurityGroups[0].GroupId),
+			MinSize:            d.Spec.NodePool.Scaling.Min,
+			MaxSize:            d.Spec.NodePool.Scaling.Max,
 		}
 
 		for _, subnet := range o2.Subnets {
-			awsmp.Spec.Subnets = append(awsmp.Spec.Subnets, capa.AWSResourceReference{ID: subnet.SubnetId})
-			awsmp.Spec.AvailabilityZones = append(awsmp.Spec.AvailabilityZones, *subnet.AvailabilityZone)
+			params.Subnets = append(params.Subnets, awsSubnet{
+				ID:               aws.StringValue(subnet.SubnetId),
+				AvailabilityZone: aws.StringValue(subnet.AvailabilityZone),
+			})
+		}
+
+		awsmp := &capaexp.AWSMachinePool{}
+		err = m.templates.render("workers_aws_machine_pool.yaml.tmpl", params, awsmp)
+		if err != nil {
+			return microerror.Mask(err)
 		}
 
 		err = m.mcCtrlClient.Create(ctx, awsmp)
@@ -509,52 +271,39 @@ func (m *awsMigrator) createWorkersAWSMachinePools(ctx context.Context) error {
 		} else if err != nil {
 			return microerror.Mask(err)
 		}
+
+		m.crs.workersAWSMachinePools = append(m.crs.workersAWSMachinePools, awsmp)
 	}
 
 	return nil
 }
 
 func (m *awsMigrator) createWorkersMachinePools(ctx context.Context) error {
-	k8sVersion := getReleaseComponents(m.crs.release)["K8sVersion"]
+	releaseComponents := getReleaseComponents(m.crs.release)
 
 	for _, d := range m.crs.awsMachineDeployments {
-		mp := &capiexp.MachinePool{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      key.AWSMachinePoolName(m.clusterID, d.Name),
-				Namespace: d.Namespace,
-			},
-			Spec: capiexp.MachinePoolSpec{
-				ClusterName: m.clusterID,
-				Replicas:    aws.Int32(int32(d.Spec.NodePool.Scaling.Min)),
-				Template: capi.MachineTemplateSpec{
-					Spec: capi.MachineSpec{
-						ClusterName: m.clusterID,
-						Version:     &k8sVersion,
-						InfrastructureRef: corev1.ObjectReference{
-							Name:       key.AWSMachinePoolName(m.clusterID, d.Name),
-							Namespace:  d.Namespace,
-							Kind:       "AWSMachinePool",
-							APIVersion: capiexp.GroupVersion.String(),
-						},
-						Bootstrap: capi.Bootstrap{
-							ConfigRef: &corev1.ObjectReference{
-								Name:       key.AWSMachinePoolName(m.clusterID, d.Name),
-								Namespace:  d.Namespace,
-								Kind:       "KubeadmConfig",
-								APIVersion: bootstrap.GroupVersion.String(),
-							},
-						},
-					},
-				},
-			},
+		params := awsWorkersMachinePoolParams{
+			ClusterID:  m.clusterID,
+			Name:       key.AWSMachinePoolName(m.clusterID, d.Name),
+			Namespace:  d.Namespace,
+			K8sVersion: kubernetesVersion(releaseComponents["kubernetes"]),
+			Replicas:   d.Spec.NodePool.Scaling.Min,
+		}
+
+		mp := &capiexp.MachinePool{}
+		err := m.templates.render("workers_machine_pool_aws.yaml.tmpl", params, mp)
+		if err != nil {
+			return microerror.Mask(err)
 		}
 
-		err := m.mcCtrlClient.Create(ctx, mp)
+		err = m.mcCtrlClient.Create(ctx, mp)
 		if apierrors.IsAlreadyExists(err) {
 			// It's ok. It's already there.
 		} else if err != nil {
 			return microerror.Mask(err)
 		}
+
+		m.crs.workersMachinePools = append(m.crs.workersMachinePools, mp)
 	}
 
 	return nil
@@ -562,7 +311,7 @@ func (m *awsMigrator) createWorkersMachinePools(ctx context.Context) error {
 
 func (m *awsMigrator) readEncryptionSecret(ctx context.Context) error {
 	obj := &corev1.Secret{}
-	key := ctrl.ObjectKey{Namespace: "default", Name: key.EncryptionConfigSecretName(m.clusterID)}
+	key := ctrl.ObjectKey{Namespace: "default", Name: key.EncryptionKeySecretName(m.clusterID)}
 	err := m.mcCtrlClient.Get(ctx, key, obj)
 	if err != nil {
 		return microerror.Mask(err)
@@ -675,7 +424,9 @@ func (m *awsMigrator) readAWSMachineDeployments(ctx context.Context) error {
 }
 
 func (m *awsMigrator) readRelease(ctx context.Context, ver string) error {
+	// Ensure the release name starts with a "v"
 	ver = strings.TrimPrefix(ver, "v")
+	ver = fmt.Sprintf("v%s", ver)
 	r := &release.Release{}
 	err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Name: ver}, r)
 	if err != nil {
@@ -687,33 +438,93 @@ func (m *awsMigrator) readRelease(ctx context.Context, ver string) error {
 	return nil
 }
 
+// readCreatedCRs reads CRs created in prepareMissingCRs. Prepare caches them,
+// so this is only needed when the migration is triggered by a new migrator,
+// e.g. from the CLI.
+func (m *awsMigrator) readCreatedCRs(ctx context.Context) error {
+	m.crs.kubeadmControlPlane = &kubeadm.KubeadmControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.AWSKubeadmControlPlaneName(m.clusterID),
+			Namespace: m.crs.g8sControlPlane.Namespace,
+		},
+	}
+	m.crs.masterAWSMachineTemplate = &capa.AWSMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.AWSMachineTemplateNameForCP(m.clusterID),
+			Namespace: m.crs.awsControlPlane.Namespace,
+		},
+	}
+
+	objs := []runtime.Object{
+		m.crs.kubeadmControlPlane,
+		m.crs.masterAWSMachineTemplate,
+	}
+
+	m.crs.workersKubeadmConfigs = nil
+	m.crs.workersAWSMachinePools = nil
+	m.crs.workersMachinePools = nil
+	for _, d := range m.crs.awsMachineDeployments {
+		objectMeta := metav1.ObjectMeta{
+			Name:      key.AWSMachinePoolName(m.clusterID, d.Name),
+			Namespace: d.Namespace,
+		}
+
+		c := &bootstrap.KubeadmConfig{ObjectMeta: objectMeta}
+		amp := &capaexp.AWSMachinePool{ObjectMeta: objectMeta}
+		mp := &capiexp.MachinePool{ObjectMeta: objectMeta}
+
+		m.crs.workersKubeadmConfigs = append(m.crs.workersKubeadmConfigs, c)
+		m.crs.workersAWSMachinePools = append(m.crs.workersAWSMachinePools, amp)
+		m.crs.workersMachinePools = append(m.crs.workersMachinePools, mp)
+		objs = append(objs, c, amp, mp)
+	}
+
+	err := readObjects(ctx, m.mcCtrlClient, objs...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
 func (m *awsMigrator) updateCluster(ctx context.Context) error {
 	cluster := m.crs.cluster
+	original := cluster.DeepCopy()
 
 	// Drop operator version label.
 	delete(cluster.Labels, label.AWSOperatorVersion)
 
-	// Drop finalizers.
-	cluster.Finalizers = nil
+	// Drop finalizers of legacy operators.
+	dropLegacyFinalizers(cluster)
 
 	// TODO
 
+	// Type meta of m.crs.kubeadmControlPlane is cleared when it is decoded
+	// by the API client, so it can't be used here.
 	cluster.Spec.ControlPlaneRef = &corev1.ObjectReference{
-		APIVersion: m.crs.kubeadmControlPlane.APIVersion,
-		Kind:       m.crs.kubeadmControlPlane.Kind,
+		APIVersion: kubeadm.GroupVersion.String(),
+		Kind:       "KubeadmControlPlane",
 		Name:       m.crs.kubeadmControlPlane.Name,
 	}
 
+	mutated := cluster.DeepCopy()
+
 	err := m.mcCtrlClient.Update(ctx, cluster)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	err = m.status.recordMutation(ctx, "Cluster", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
 func (m *awsMigrator) updateAWSCluster(ctx context.Context) error {
 	cluster := m.crs.awsCluster
+	original := cluster.DeepCopy()
 
 	// Drop operator version label.
 	delete(cluster.Labels, label.AWSOperatorVersion)
@@ -723,10 +534,43 @@ func (m *awsMigrator) updateAWSCluster(ctx context.Context) error {
 
 	// TODO
 
+	mutated := cluster.DeepCopy()
+
 	err := m.mcCtrlClient.Update(ctx, cluster)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	err = m.status.recordMutation(ctx, "AWSCluster", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// backupCRs stores CRs and certificate secrets mutated during migration in
+// the backup of the current migration attempt.
+func (m *awsMigrator) backupCRs(ctx context.Context) error {
+	objs := map[string]runtime.Object{
+		backupCluster:               m.crs.cluster,
+		backupInfrastructureCluster: m.crs.awsCluster,
+	}
+
+	for _, name := range []string{key.SACertsSecretName(m.clusterID), key.EtcdCertsSecretName(m.clusterID)} {
+		secret := &corev1.Secret{}
+		err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: name}, secret)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		objs[backupSecret(name)] = secret
+	}
+
+	err := m.backup.save(ctx, objs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
@@ -0,0 +1,245 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeEC2 returns security groups by their Name tag and the same subnets
+// for every node pool.
+type fakeEC2 struct {
+	ec2iface.EC2API
+
+	securityGroups map[string]string
+	subnets        []*ec2.Subnet
+}
+
+func (f *fakeEC2) DescribeSecurityGroups(i *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	o := &ec2.DescribeSecurityGroupsOutput{}
+	for _, filter := range i.Filters {
+		if aws.StringValue(filter.Name) != "tag:Name" {
+			continue
+		}
+		for _, v := range filter.Values {
+			id, ok := f.securityGroups[aws.StringValue(v)]
+			if ok {
+				o.SecurityGroups = append(o.SecurityGroups, &ec2.SecurityGroup{GroupId: aws.String(id)})
+			}
+		}
+	}
+
+	return o, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(i *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{Subnets: f.subnets}, nil
+}
+
+func Test_awsMigrator_Golden(t *testing.T) {
+	ctx := context.Background()
+	scheme := newGoldenScheme(t)
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	clusterLabels := map[string]string{
+		capi.ClusterLabelName:    "abc12",
+		label.AWSOperatorVersion: "10.1.0",
+		label.ReleaseVersion:     "14.1.0",
+	}
+
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "abc12",
+			Namespace:  "default",
+			Labels:     clusterLabels,
+			Finalizers: []string{"operatorkit.giantswarm.io/cluster-operator-cluster-controller"},
+		},
+		Spec: capi.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: giantswarmawsalpha3.SchemeGroupVersion.String(),
+				Kind:       "AWSCluster",
+				Name:       "abc12",
+				Namespace:  "default",
+			},
+		},
+	}
+
+	objs := []runtime.Object{
+		cluster,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12-encryption", Namespace: "default"},
+			Data:       map[string][]byte{"encryption": []byte("c2VjcmV0LWVuY3J5cHRpb24ta2V5")},
+		},
+		&giantswarmawsalpha3.AWSCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "abc12",
+				Namespace:  "default",
+				Labels:     clusterLabels,
+				Finalizers: []string{"operatorkit.giantswarm.io/aws-operator-cluster-controller"},
+			},
+			Spec: giantswarmawsalpha3.AWSClusterSpec{
+				Cluster: giantswarmawsalpha3.AWSClusterSpecCluster{
+					DNS: giantswarmawsalpha3.AWSClusterSpecClusterDNS{Domain: "eu-west-1.aws.example.com"},
+				},
+				Provider: giantswarmawsalpha3.AWSClusterSpecProvider{Region: "eu-west-1"},
+			},
+		},
+		&giantswarmawsalpha3.AWSControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "a0b1c", Namespace: "default", Labels: clusterLabels},
+			Spec:       giantswarmawsalpha3.AWSControlPlaneSpec{InstanceType: "m5.xlarge"},
+		},
+		&giantswarmawsalpha3.G8sControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "a0b1c", Namespace: "default", Labels: clusterLabels},
+		},
+		&giantswarmawsalpha3.AWSMachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "d3e4f", Namespace: "default", Labels: clusterLabels},
+			Spec: giantswarmawsalpha3.AWSMachineDeploymentSpec{
+				NodePool: giantswarmawsalpha3.AWSMachineDeploymentSpecNodePool{
+					Scaling: giantswarmawsalpha3.AWSMachineDeploymentSpecNodePoolScaling{Min: 2, Max: 5},
+				},
+				Provider: giantswarmawsalpha3.AWSMachineDeploymentSpecProvider{
+					Worker: giantswarmawsalpha3.AWSMachineDeploymentSpecProviderWorker{InstanceType: "m5.2xlarge"},
+				},
+			},
+		},
+		&release.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: "v14.1.0"},
+			Spec: release.ReleaseSpec{
+				Components: []release.ReleaseSpecComponent{
+					{Name: "kubernetes", Version: "1.19.9"},
+					{Name: "etcd", Version: "3.4.14"},
+					{Name: "cluster-api-bootstrap-provider-kubeadm", Version: "0.3.13"},
+					{Name: "cluster-api-control-plane", Version: "0.3.13"},
+					{Name: "cluster-api-core", Version: "0.3.13"},
+					{Name: "cluster-api-provider-aws", Version: "0.6.5"},
+					{Name: "cluster-operator", Version: "3.6.0"},
+				},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	_, err = EnsureClusterMigration(ctx, c, scheme, cluster, ProviderAWS)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	m := &awsMigrator{
+		migratorBase: migratorBase{
+			clusterID:        "abc12",
+			clusterNamespace: "default",
+
+			logger:       logger,
+			mcCtrlClient: c,
+			status:       newMigrationStatus(c, cluster, false),
+			ssh: SSHConfig{
+				Users:          []SSHUser{{Name: "jane", AuthorizedKeys: []string{"ssh-ed25519 AAAA jane@example.com"}}},
+				AWSKeyPairName: "migration",
+			},
+		},
+		awsClients: &AWSClients{
+			EC2: &fakeEC2{
+				securityGroups: map[string]string{
+					"abc12-master": "sg-master",
+					"abc12-worker": "sg-worker",
+				},
+				subnets: []*ec2.Subnet{
+					{SubnetId: aws.String("subnet-a"), AvailabilityZone: aws.String("eu-west-1a")},
+					{SubnetId: aws.String("subnet-b"), AvailabilityZone: aws.String("eu-west-1b")},
+				},
+			},
+		},
+	}
+
+	err = m.readCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.prepareMissingCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.updateCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "default"}
+	}
+
+	assertGolden(t, c, scheme, "aws",
+		&corev1.Secret{ObjectMeta: meta("abc12-k8s-encryption-config")},
+		&corev1.Secret{ObjectMeta: meta("abc12-custom-files")},
+		&kubeadm.KubeadmControlPlane{ObjectMeta: meta("abc12-control-plane")},
+		&capa.AWSMachineTemplate{ObjectMeta: meta("abc12-control-plane")},
+		&bootstrap.KubeadmConfig{ObjectMeta: meta("abc12-worker-d3e4f")},
+		&capaexp.AWSMachinePool{ObjectMeta: meta("abc12-worker-d3e4f")},
+		&capiexp.MachinePool{ObjectMeta: meta("abc12-worker-d3e4f")},
+		&capi.Cluster{ObjectMeta: meta("abc12")},
+		&giantswarmawsalpha3.AWSCluster{ObjectMeta: meta("abc12")},
+	)
+
+	// A new migrator, like the one of the CLI, triggers the migration
+	// without running Prepare first.
+	m = &awsMigrator{
+		migratorBase: migratorBase{
+			clusterID:        "abc12",
+			clusterNamespace: "default",
+
+			logger:       logger,
+			mcCtrlClient: c,
+			status:       newMigrationStatus(c, cluster, false),
+			events:       newMigrationEvents(record.NewFakeRecorder(10), cluster, false),
+		},
+	}
+
+	err = newMigrationDriver(ProviderAWS, &m.migratorBase, m).TriggerMigration(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-control-plane"}, kcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if kcp.Labels[watchFilterLabel] != "0.3.13" {
+		t.Fatalf("expected KubeadmControlPlane to be handed over, got labels %#v", kcp.Labels)
+	}
+
+	mp := &capiexp.MachinePool{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-worker-d3e4f"}, mp)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if mp.Labels[label.ReleaseVersion] != "v14.1.0" {
+		t.Fatalf("expected MachinePool to be handed over, got labels %#v", mp.Labels)
+	}
+}
@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"os"
+
+	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
+	"github.com/giantswarm/microerror"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=awsmachinetemplates;awsmachinepools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.giantswarm.io,resources=awsclusters,verbs=get;list;watch;update;patch
+
+const (
+	flagAWSAccessKeyID     = "aws-access-id"
+	flagAWSAccessKeySecret = "aws-access-secret" //nolint:gosec
+)
+
+var awsFlags AWSConfig
+
+func init() {
+	RegisterProvider(Provider{
+		Name:                ProviderAWS,
+		InfrastructureKinds: []string{"AWSCluster"},
+
+		AddToScheme:        addAWSToScheme,
+		InitFlags:          initAWSFlags,
+		ValidateFlags:      validateAWSFlags,
+		NewMigratorFactory: newAWSProviderMigratorFactory,
+	})
+}
+
+func addAWSToScheme(s *runtime.Scheme) error {
+	for _, add := range []func(*runtime.Scheme) error{
+		giantswarmawsalpha3.AddToScheme,
+		capa.AddToScheme,
+		capaexp.AddToScheme,
+	} {
+		err := add(s)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func initAWSFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&awsFlags.AccessKeyID, flagAWSAccessKeyID, "", "AWS access key for MC. Defaults to AWS_ACCESS_KEY_ID.")
+	fs.StringVar(&awsFlags.AccessKeySecret, flagAWSAccessKeySecret, "", "AWS secret key for MC. Defaults to AWS_SECRET_ACCESS_KEY.")
+}
+
+func validateAWSFlags() error {
+	if awsFlags.AccessKeyID == "" {
+		awsFlags.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if awsFlags.AccessKeySecret == "" {
+		awsFlags.AccessKeySecret = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	if awsFlags.AccessKeyID == "" || awsFlags.AccessKeySecret == "" {
+		return microerror.Maskf(invalidConfigError, "when %q provider is enabled, --%s and --%s must not be empty", ProviderAWS, flagAWSAccessKeyID, flagAWSAccessKeySecret)
+	}
+
+	return nil
+}
+
+func newAWSProviderMigratorFactory(cfg ProviderConfig) (MigratorFactory, error) {
+	f, err := NewAWSMigratorFactory(AWSMigrationConfig{
+		AWSCredentials: awsFlags,
+		CtrlClient:     cfg.CtrlClient,
+		EventRecorder:  cfg.EventRecorder,
+		Logger:         cfg.Logger,
+		Scheme:         cfg.Scheme,
+		TenantCluster:  cfg.TenantCluster,
+		VaultClient:    cfg.VaultClient,
+
+		DryRun:       cfg.DryRun,
+		SSH:          cfg.SSH,
+		SSHConfigMap: cfg.SSHConfigMap,
+
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
+
+		NewAWSClients: cfg.NewAWSClients,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f, nil
+}
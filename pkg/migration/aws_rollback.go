@@ -0,0 +1,151 @@
+package migration
+
+import (
+	"context"
+
+	giantswarmawsalpha3 "github.com/giantswarm/apiextensions/v3/pkg/apis/infrastructure/v1alpha2"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	capaexp "sigs.k8s.io/cluster-api-provider-aws/exp/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+func (m *awsMigrator) rollback(ctx context.Context) error {
+	err := m.readCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readAWSCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readAWSMachineDeployments(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreAWSCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.deleteCreatedCRs(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *awsMigrator) restoreCluster(ctx context.Context) error {
+	original := &capi.Cluster{}
+	err := m.backup.load(ctx, backupCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster := m.crs.cluster
+	restoreMetadata(cluster, original)
+	cluster.Spec.ControlPlaneRef = original.Spec.ControlPlaneRef
+
+	err = m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored Cluster %s/%s from backup", cluster.Namespace, cluster.Name)
+
+	return nil
+}
+
+func (m *awsMigrator) restoreAWSCluster(ctx context.Context) error {
+	original := &giantswarmawsalpha3.AWSCluster{}
+	err := m.backup.load(ctx, backupInfrastructureCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster := m.crs.awsCluster
+	restoreMetadata(cluster, original)
+
+	err = m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored AWSCluster %s/%s from backup", cluster.Namespace, cluster.Name)
+
+	return nil
+}
+
+// deleteCreatedCRs deletes CRs and secrets created in prepareMissingCRs.
+// Workers go first, so that upstream controllers don't recreate them while
+// the control plane is being deleted.
+func (m *awsMigrator) deleteCreatedCRs(ctx context.Context) error {
+	var objs []runtime.Object
+	for _, d := range m.crs.awsMachineDeployments {
+		objectMeta := metav1.ObjectMeta{
+			Name:      key.AWSMachinePoolName(m.clusterID, d.Name),
+			Namespace: d.Namespace,
+		}
+
+		objs = append(objs,
+			&capiexp.MachinePool{ObjectMeta: objectMeta},
+			&capaexp.AWSMachinePool{ObjectMeta: objectMeta},
+			&bootstrap.KubeadmConfig{ObjectMeta: objectMeta},
+		)
+	}
+
+	objs = append(objs,
+		&kubeadm.KubeadmControlPlane{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.AWSKubeadmControlPlaneName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+		&capa.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.AWSMachineTemplateNameForCP(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.EncryptionConfigSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.CustomFilesSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+	)
+
+	for _, obj := range objs {
+		err := deleteIfExists(ctx, m.mcCtrlClient, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	m.logger.Debugf(ctx, "deleted CRs and secrets created for upstream controllers")
+
+	return nil
+}
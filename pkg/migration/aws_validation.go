@@ -0,0 +1,109 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+)
+
+// validate runs the shared pre-flight checks of validateMigration and checks
+// AWS infrastructure of the cluster.
+func (m *awsMigrator) validate(ctx context.Context) *validationFindings {
+	f := &validationFindings{}
+
+	err := m.readCluster(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read Cluster: %s", microerror.Cause(err))
+		return f
+	}
+
+	err = m.readRelease(ctx, m.crs.cluster.GetLabels()[label.ReleaseVersion])
+	if err != nil {
+		f.blocking(checkRelease, "failed to read Release: %s", microerror.Cause(err))
+	}
+
+	validateMigration(ctx, f, m.mcCtrlClient, m.wcCtrlClient, m.certs, m.crs.cluster, m.crs.release, awsInfrastructureComponent)
+
+	err = m.readAWSCluster(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read AWSCluster: %s", microerror.Cause(err))
+		return f
+	}
+
+	err = m.readAWSMachineDeployments(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read AWSMachineDeployments: %s", microerror.Cause(err))
+		return f
+	}
+
+	err = m.createAWSApiClients(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to create AWS API clients: %s", microerror.Cause(err))
+		return f
+	}
+
+	m.validateSecurityGroups(ctx, f)
+
+	return f
+}
+
+// validateSecurityGroups checks that security groups and subnets referenced
+// by created AWSMachineTemplate and AWSMachinePools exist.
+func (m *awsMigrator) validateSecurityGroups(ctx context.Context, f *validationFindings) {
+	{
+		i := &ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag:Name"),
+					Values: aws.StringSlice([]string{fmt.Sprintf("%s-master", m.clusterID)}),
+				},
+			},
+		}
+		o, err := m.awsClients.EC2.DescribeSecurityGroups(i)
+		if err != nil {
+			f.blocking(checkInfrastructure, "failed to describe master security groups: %s", err)
+		} else if len(o.SecurityGroups) != 1 {
+			f.blocking(checkInfrastructure, "expected 1 master security group but found %d", len(o.SecurityGroups))
+		}
+	}
+
+	for _, d := range m.crs.awsMachineDeployments {
+		i := &ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag:Name"),
+					Values: aws.StringSlice([]string{fmt.Sprintf("%s-worker", m.clusterID)}),
+				},
+				{
+					Name:   aws.String("tag:giantswarm.io/machine-deployment"),
+					Values: aws.StringSlice([]string{d.Name}),
+				},
+			},
+		}
+		o, err := m.awsClients.EC2.DescribeSecurityGroups(i)
+		if err != nil {
+			f.blocking(checkInfrastructure, "failed to describe worker security groups of %q: %s", d.Name, err)
+		} else if len(o.SecurityGroups) != 1 {
+			f.blocking(checkInfrastructure, "expected 1 worker security group for %q but found %d", d.Name, len(o.SecurityGroups))
+		}
+
+		i2 := &ec2.DescribeSubnetsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag:giantswarm.io/machine-deployment"),
+					Values: aws.StringSlice([]string{d.Name}),
+				},
+			},
+		}
+		o2, err := m.awsClients.EC2.DescribeSubnets(i2)
+		if err != nil {
+			f.blocking(checkInfrastructure, "failed to describe subnets of %q: %s", d.Name, err)
+		} else if len(o2.Subnets) == 0 {
+			f.blocking(checkInfrastructure, "no subnets found for %q", d.Name)
+		}
+	}
+}
@@ -2,20 +2,19 @@ package migration
 
 import (
 	"context"
-	"fmt"
 
 	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
 	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
 	"github.com/giantswarm/apiextensions/v3/pkg/label"
-	"github.com/giantswarm/k8sclient/v4/pkg/k8sclient"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
+	vaultclient "github.com/hashicorp/vault/api"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/rest"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
-	"sigs.k8s.io/cluster-api/api/v1alpha3"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
 	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
 	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
@@ -26,12 +25,35 @@ import (
 type AzureMigrationConfig struct {
 	// Migration configuration + dependencies such as k8s client.
 	CtrlClient    ctrl.Client
+	EventRecorder record.EventRecorder
 	Logger        micrologger.Logger
+	Scheme        *runtime.Scheme
 	TenantCluster tenantcluster.Interface
+	// VaultClient reads CAs of workload clusters. It is shared by all
+	// migrators and its token is kept valid by the caller.
+	VaultClient *vaultclient.Client
+
+	// DryRun makes migrators only render the migration plan for all
+	// clusters. It can be enabled for a single cluster with
+	// meta.Annotation.DryRun.
+	DryRun bool
+	// SSH is the SSH access policy for machines of migrated clusters. When
+	// SSHConfigMap is set, the policy stored there is merged over SSH for
+	// every new migrator.
+	SSH          SSHConfig
+	SSHConfigMap ctrl.ObjectKey
+	// TemplatesConfigMap overrides embedded CR templates with templates
+	// stored under their file names. It is read for every new migrator.
+	TemplatesConfigMap ctrl.ObjectKey
+
+	// NewVMSSClient creates VMSS API clients of workload cluster
+	// subscriptions. Defaults to NewAzureVMSSClient.
+	NewVMSSClient AzureVMSSClientFactory
 }
 
 type azureMigratorFactory struct {
-	config AzureMigrationConfig
+	config         AzureMigrationConfig
+	migratorConfig migratorConfig
 }
 
 type azureCRs struct {
@@ -53,101 +75,68 @@ type azureCRs struct {
 }
 
 type azureMigrator struct {
-	clusterID string
+	migratorBase
 
 	crs azureCRs
 
-	// Migration configuration, dependencies + intermediate cache for involved
-	// CRs.
-	logger       micrologger.Logger
-	mcCtrlClient ctrl.Client
-	wcCtrlClient ctrl.Client
+	newVMSSClient AzureVMSSClientFactory
 }
 
 func NewAzureMigratorFactory(cfg AzureMigrationConfig) (MigratorFactory, error) {
-	return &azureMigratorFactory{
-		config: cfg,
-	}, nil
-}
+	mc := migratorConfig{
+		CtrlClient:    cfg.CtrlClient,
+		EventRecorder: cfg.EventRecorder,
+		Logger:        cfg.Logger,
+		Scheme:        cfg.Scheme,
+		TenantCluster: cfg.TenantCluster,
+		VaultClient:   cfg.VaultClient,
 
-func (f *azureMigratorFactory) NewMigrator(cluster *v1alpha3.Cluster) (Migrator, error) {
-	url := fmt.Sprintf("%s:%d", cluster.Spec.ControlPlaneEndpoint.Host, cluster.Spec.ControlPlaneEndpoint.Port)
-	restConfig, err := f.config.TenantCluster.NewRestConfig(context.Background(), cluster.Name, url)
-	if err != nil {
-		return nil, microerror.Mask(err)
+		DryRun:             cfg.DryRun,
+		SSH:                cfg.SSH,
+		SSHConfigMap:       cfg.SSHConfigMap,
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
 	}
 
-	k8sClient, err := k8sclient.NewClients(k8sclient.ClientsConfig{
-		Logger:     f.config.Logger,
-		RestConfig: rest.CopyConfig(restConfig),
-	})
+	err := mc.validate(cfg)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	return &azureMigrator{
-		clusterID: cluster.Name,
-		// rest of the config from f.config...
-		logger:       f.config.Logger,
-		mcCtrlClient: f.config.CtrlClient,
-		wcCtrlClient: k8sClient.CtrlClient(),
-	}, nil
-}
-
-func (m *azureMigrator) IsMigrated(ctx context.Context) (bool, error) {
-	return false, nil
-}
+	if cfg.NewVMSSClient == nil {
+		cfg.NewVMSSClient = NewAzureVMSSClient
+	}
 
-func (m *azureMigrator) IsMigrating(ctx context.Context) (bool, error) {
-	return false, nil
+	return &azureMigratorFactory{
+		config:         cfg,
+		migratorConfig: mc,
+	}, nil
 }
 
-func (m *azureMigrator) Prepare(ctx context.Context) error {
-	var err error
-
-	err = m.readCRs(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	err = m.prepareMissingCRs(ctx)
+func (f *azureMigratorFactory) NewMigrator(cluster *capi.Cluster) (Migrator, error) {
+	base, err := newMigratorBase(f.migratorConfig, cluster)
 	if err != nil {
-		return microerror.Mask(err)
+		return nil, microerror.Mask(err)
 	}
 
-	err = m.updateCRs(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
+	m := &azureMigrator{
+		migratorBase: base,
 
-	err = m.stopOldMasterComponents(ctx)
-	if err != nil {
-		return microerror.Mask(err)
+		newVMSSClient: f.config.NewVMSSClient,
 	}
 
-	return nil
+	return newMigrationDriver(ProviderAzure, &m.migratorBase, m), nil
 }
 
-func (m *azureMigrator) TriggerMigration(ctx context.Context) error {
-	err := m.triggerMigration(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	return nil
+func (m *azureMigrator) cluster() *capi.Cluster {
+	return m.crs.cluster
 }
 
-func (m *azureMigrator) Cleanup(ctx context.Context) error {
-	migrated, err := m.IsMigrated(ctx)
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	if !migrated {
-		return fmt.Errorf("cluster has not migrated yet")
+func (m *azureMigrator) prepareSteps() []prepareStep {
+	return []prepareStep{
+		{name: "prepareMissingCRs", run: m.prepareMissingCRs},
+		{name: "updateCRs", run: m.updateCRs},
+		{name: "stopOldMasterComponents", run: m.stopOldMasterComponents},
 	}
-
-	return m.cleanup(ctx)
 }
 
 // readCRs reads existing CRs involved in migration. For Azure this contains
@@ -206,7 +195,7 @@ func (m *azureMigrator) readCRs(ctx context.Context) error {
 func (m *azureMigrator) prepareMissingCRs(ctx context.Context) error {
 	var err error
 
-	err = m.createEncryptionConfigSecret(ctx)
+	err = createEncryptionConfigSecret(ctx, m.mcCtrlClient, m.clusterID, m.clusterNamespace, m.crs.encryptionSecret)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -269,7 +258,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 
 	{
 		m.crs.cluster.Labels[label.ClusterOperatorVersion] = releaseComponents["cluster-operator"]
-		m.crs.cluster.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-core"]
+		m.crs.cluster.Labels[watchFilterLabel] = releaseComponents["cluster-api-core"]
 		err := m.mcCtrlClient.Update(ctx, m.crs.cluster)
 		if err != nil {
 			return microerror.Mask(err)
@@ -278,7 +267,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 
 	{
 		m.crs.workersMachineDeployment.Labels[label.ClusterOperatorVersion] = releaseComponents["cluster-operator"]
-		m.crs.workersMachineDeployment.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-core"]
+		m.crs.workersMachineDeployment.Labels[watchFilterLabel] = releaseComponents["cluster-api-core"]
 		err := m.mcCtrlClient.Update(ctx, m.crs.workersMachineDeployment)
 		if err != nil {
 			return microerror.Mask(err)
@@ -286,7 +275,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 	}
 
 	{
-		m.crs.masterAzureMachineTemplate.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-provider-azure"]
+		m.crs.masterAzureMachineTemplate.Labels[watchFilterLabel] = releaseComponents[azureInfrastructureComponent]
 		err := m.mcCtrlClient.Update(ctx, m.crs.masterAzureMachineTemplate)
 		if err != nil {
 			return microerror.Mask(err)
@@ -294,7 +283,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 	}
 
 	{
-		m.crs.workersAzureMachineTemplate.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-provider-azure"]
+		m.crs.workersAzureMachineTemplate.Labels[watchFilterLabel] = releaseComponents[azureInfrastructureComponent]
 		err := m.mcCtrlClient.Update(ctx, m.crs.workersAzureMachineTemplate)
 		if err != nil {
 			return microerror.Mask(err)
@@ -302,7 +291,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 	}
 
 	{
-		m.crs.kubeadmControlPlane.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-control-plane"]
+		m.crs.kubeadmControlPlane.Labels[watchFilterLabel] = releaseComponents["cluster-api-control-plane"]
 		m.crs.kubeadmControlPlane.Labels[label.ReleaseVersion] = m.crs.release.Name
 		err := m.mcCtrlClient.Update(ctx, m.crs.kubeadmControlPlane)
 		if err != nil {
@@ -311,7 +300,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 	}
 
 	{
-		m.crs.workersKubeadmConfigTemplate.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-bootstrap-provider-kubeadm"]
+		m.crs.workersKubeadmConfigTemplate.Labels[watchFilterLabel] = releaseComponents["cluster-api-bootstrap-provider-kubeadm"]
 		m.crs.workersKubeadmConfigTemplate.Labels[label.ReleaseVersion] = m.crs.release.Name
 		err := m.mcCtrlClient.Update(ctx, m.crs.workersKubeadmConfigTemplate)
 		if err != nil {
@@ -319,7 +308,7 @@ func (m *azureMigrator) triggerMigration(ctx context.Context) error {
 		}
 	}
 	{
-		m.crs.azureCluster.Labels["cluster.x-k8s.io/watch-filter"] = releaseComponents["cluster-api-provider-azure"]
+		m.crs.azureCluster.Labels[watchFilterLabel] = releaseComponents[azureInfrastructureComponent]
 		m.crs.azureCluster.Labels[label.ReleaseVersion] = m.crs.release.Name
 		err := m.mcCtrlClient.Update(ctx, m.crs.azureCluster)
 		if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute/computeapi"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/giantswarm/microerror"
 	v1 "k8s.io/api/core/v1"
@@ -15,7 +16,20 @@ const (
 	clientSecretKey = "clientSecret"
 )
 
-func (m *azureMigrator) getVMSSClient(ctx context.Context) (*compute.VirtualMachineScaleSetsClient, error) {
+// AzureCredentials are service principal credentials of a workload cluster
+// subscription.
+type AzureCredentials struct {
+	SubscriptionID string
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+}
+
+// AzureVMSSClientFactory creates a VMSS API client for the given credentials.
+// NewAzureVMSSClient is the default, tests replace it with fakes.
+type AzureVMSSClientFactory func(credentials AzureCredentials) (computeapi.VirtualMachineScaleSetsClientAPI, error)
+
+func (m *azureMigrator) getVMSSClient(ctx context.Context) (computeapi.VirtualMachineScaleSetsClientAPI, error) {
 	azureCluster := m.crs.azureCluster
 
 	if azureCluster.Spec.SubscriptionID == "" {
@@ -38,17 +52,29 @@ func (m *azureMigrator) getVMSSClient(ctx context.Context) (*compute.VirtualMach
 		return nil, microerror.Mask(err)
 	}
 
-	subscriptionID := azureCluster.Spec.SubscriptionID
-	clientID := azureClusterIdentity.Spec.ClientID
-	tenantID := azureClusterIdentity.Spec.TenantID
 	clientSecret, err := valueFromSecret(secret, clientSecretKey)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
 
-	azureClient := compute.NewVirtualMachineScaleSetsClient(subscriptionID)
-	credentials := auth.NewClientCredentialsConfig(clientID, clientSecret, tenantID)
-	authorizer, err := credentials.Authorizer()
+	vmssClient, err := m.newVMSSClient(AzureCredentials{
+		SubscriptionID: azureCluster.Spec.SubscriptionID,
+		TenantID:       azureClusterIdentity.Spec.TenantID,
+		ClientID:       azureClusterIdentity.Spec.ClientID,
+		ClientSecret:   clientSecret,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return vmssClient, nil
+}
+
+// NewAzureVMSSClient creates a VMSS API client authorized with the given
+// service principal credentials.
+func NewAzureVMSSClient(credentials AzureCredentials) (computeapi.VirtualMachineScaleSetsClientAPI, error) {
+	azureClient := compute.NewVirtualMachineScaleSetsClient(credentials.SubscriptionID)
+	authorizer, err := auth.NewClientCredentialsConfig(credentials.ClientID, credentials.ClientSecret, credentials.TenantID).Authorizer()
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -2,9 +2,9 @@ package migration
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/giantswarm/microerror"
-	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -37,38 +37,16 @@ func (m *azureMigrator) ensureLegacyMastersAreDeleted(ctx context.Context) error
 	_, err = vmssClient.Get(ctx, m.clusterID, vmssName)
 	if IsAzureNotFound(err) {
 		m.logger.Debugf(ctx, "VMSS %s not found in resource group %s", vmssName, m.clusterID)
+		observeLegacyNodeGroups(m.clusterID, ProviderAzure, metricsRoleMaster, 0)
 		return nil
 	}
 
-	// Check if the new master exists and is ready or wait.
-	{
-		nodes := v1.NodeList{}
-		err = m.wcCtrlClient.List(ctx, &nodes, client.MatchingLabels{"node-role.kubernetes.io/master": ""})
-		if err != nil {
-			return microerror.Mask(err)
-		}
+	observeLegacyNodeGroups(m.clusterID, ProviderAzure, metricsRoleMaster, 1)
 
-		// Filter out nodes having label "role=master".
-		var newMasters []v1.Node
-		for _, n := range nodes.Items {
-			if n.Labels["role"] == "master" {
-				// Legacy master node.
-			} else {
-				newMasters = append(newMasters, n)
-			}
-		}
-
-		if len(newMasters) == 0 {
-			return microerror.Maskf(newMasterNotReadyError, "New master node was not found")
-		}
-
-		if len(newMasters) > 1 {
-			return microerror.Maskf(tooManyMastersError, "Exactly one master node was expected to exist, %d found", len(nodes.Items))
-		}
-
-		if nodes.Items[0].Status.Phase != v1.NodeRunning {
-			return microerror.Maskf(newMasterNotReadyError, "Master node %q is not ready (%q)", nodes.Items[0].Name, nodes.Items[0].Status.Phase)
-		}
+	// Check if the new master exists and is ready or wait.
+	err = ensureNewMasterIsReady(ctx, m.wcCtrlClient, m.events, fmt.Sprintf("VMSS %s", vmssName))
+	if err != nil {
+		return microerror.Mask(err)
 	}
 
 	m.logger.Debugf(ctx, "Deleting VMSS %q from resource group %q", vmssName, m.clusterID)
@@ -80,6 +58,7 @@ func (m *azureMigrator) ensureLegacyMastersAreDeleted(ctx context.Context) error
 	}
 
 	m.logger.Debugf(ctx, "Deleted VMSS %q from resource group %q", vmssName, m.clusterID)
+	m.events.normalf(EventReasonLegacyResourceDeleted, "VMSS %s deleted", vmssName)
 
 	return nil
 }
@@ -117,6 +96,8 @@ func (m *azureMigrator) ensureLegacyNodePoolsAreDeleted(ctx context.Context) err
 			oldWorkersCount += int(*vmss.Sku.Capacity)
 		}
 
+		observeLegacyNodeGroups(m.clusterID, ProviderAzure, metricsRoleNodePool, len(vmssesToBeDeleted))
+
 		if len(vmssesToBeDeleted) == 0 {
 			m.logger.Debugf(ctx, "No legacy VMSSes found")
 			return nil
@@ -124,33 +105,9 @@ func (m *azureMigrator) ensureLegacyNodePoolsAreDeleted(ctx context.Context) err
 	}
 
 	// Check there are at least `oldWorkersCount` CAPI workers in a `Ready` state.
-	{
-		workers := v1.NodeList{}
-		err = m.wcCtrlClient.List(ctx, &workers, client.MatchingLabels{})
-		if err != nil {
-			return microerror.Mask(err)
-		}
-
-		var readyCAPIworkers int
-		for _, node := range workers.Items {
-			if _, ok := node.Labels["node-role.kubernetes.io/master"]; ok {
-				// Master node, ignore.
-				continue
-			}
-			if node.Labels["role"] == "worker" {
-				// GS worker, ignore.
-				continue
-			}
-			if node.Status.Phase == v1.NodeRunning {
-				readyCAPIworkers += 1
-			}
-		}
-
-		if readyCAPIworkers < oldWorkersCount {
-			return microerror.Maskf(newWorkersNotReady, "Expected at least %d CAPI workers to be ready, %d found", oldWorkersCount, readyCAPIworkers)
-		}
-
-		m.logger.Debugf(ctx, "Found %d CAPI nodes ready (at least %d wanted)", readyCAPIworkers, oldWorkersCount)
+	err = ensureCAPIWorkersAreReady(ctx, m.logger, m.wcCtrlClient, m.events, oldWorkersCount, isLegacyWorker)
+	if err != nil {
+		return microerror.Mask(err)
 	}
 
 	m.logger.Debugf(ctx, "Found %d VMSSes to be deleted", len(vmssesToBeDeleted))
@@ -161,6 +118,7 @@ func (m *azureMigrator) ensureLegacyNodePoolsAreDeleted(ctx context.Context) err
 			return microerror.Mask(err)
 		}
 		m.logger.Debugf(ctx, "Deleted VMSS %s", vmssName)
+		m.events.normalf(EventReasonLegacyResourceDeleted, "VMSS %s deleted", vmssName)
 	}
 	m.logger.Debugf(ctx, "Deleted %d VMSSes", len(vmssesToBeDeleted))
 	return nil
@@ -1,12 +1,10 @@
 package migration
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
 	"strings"
-	"text/template"
 
 	"github.com/Azure/go-autorest/autorest/to"
 	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
@@ -16,6 +14,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -23,49 +22,18 @@ import (
 	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
 )
 
 const (
 	EncryptionSecret = "EncryptionSecret"
-)
-
-func (m *azureMigrator) createEncryptionConfigSecret(ctx context.Context) error {
-	encryptionConfigTmpl := `
-kind: EncryptionConfiguration
-apiVersion: apiserver.config.k8s.io/v1
-resources:
-  - resources:
-    - secrets
-    providers:
-    - aescbc:
-        keys:
-        - name: key1
-          secret: %s
-    - identity: {}`
-
-	renderedConfig := fmt.Sprintf(encryptionConfigTmpl, m.crs.encryptionSecret.Data["encryption"])
-
-	s := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-k8s-encryption-config", m.clusterID),
-			Namespace: "default",
-		},
-		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			"encryption": renderedConfig,
-		},
-	}
 
-	err := m.mcCtrlClient.Create(ctx, s)
-	if apierrors.IsAlreadyExists(err) {
-		// It's fine. No worries.
-	} else if err != nil {
-		return microerror.Mask(err)
-	}
-
-	return nil
-}
+	// azureInfrastructureComponent is the release component of the
+	// infrastructure provider reconciling AzureCluster and
+	// AzureMachineTemplate.
+	azureInfrastructureComponent = "cluster-api-provider-azure"
+)
 
 func (m *azureMigrator) createProxyConfigSecret(ctx context.Context) error {
 	proxyConfig := `
@@ -78,8 +46,8 @@ metricsBindAddress: 0.0.0.0:10249`
 
 	s := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-proxy-config", m.clusterID),
-			Namespace: "default",
+			Name:      key.ProxyConfigSecretName(m.clusterID),
+			Namespace: m.clusterNamespace,
 		},
 		Type: corev1.SecretTypeOpaque,
 		StringData: map[string]string{
@@ -97,11 +65,6 @@ metricsBindAddress: 0.0.0.0:10249`
 }
 
 func (m *azureMigrator) createKubeadmControlPlane(ctx context.Context) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/kubeadm_controlplane_azure.yaml.tmpl")
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
 	baseDomain, err := getInstallationBaseDomainFromAPIEndpoint(m.crs.azureCluster.Spec.ControlPlaneEndpoint.Host)
 	if err != nil {
 		return microerror.Mask(err)
@@ -114,23 +77,19 @@ func (m *azureMigrator) createKubeadmControlPlane(ctx context.Context) error {
 
 	releaseComponents := getReleaseComponents(m.crs.release)
 
-	cfg := map[string]string{
+	cfg := map[string]interface{}{
 		"ClusterID":              m.clusterID,
 		"ClusterCIDR":            vnet.String(),
 		"ClusterMasterIP":        getMasterIPForVNet(vnet).String(),
 		"EtcdVersion":            releaseComponents["etcd"],
 		"K8sVersion":             releaseComponents["kubernetes"],
 		"InstallationBaseDomain": baseDomain,
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = tmpl.Execute(buf, cfg)
-	if err != nil {
-		return microerror.Mask(err)
+		"Namespace":              m.clusterNamespace,
+		"SSHUsers":               m.ssh.azureUsers(),
 	}
 
 	kcp := &kubeadm.KubeadmControlPlane{}
-	err = yaml.Unmarshal(buf.Bytes(), kcp)
+	err = m.templates.render("kubeadm_controlplane_azure.yaml.tmpl", cfg, kcp)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -149,24 +108,15 @@ func (m *azureMigrator) createKubeadmControlPlane(ctx context.Context) error {
 }
 
 func (m *azureMigrator) createMasterAzureMachineTemplate(ctx context.Context) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/controlplane_azure_machine_template.yaml.tmpl")
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
 	cfg := map[string]string{
 		"ClusterID":     m.clusterID,
 		"AzureLocation": m.crs.azureCluster.Spec.Location,
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = tmpl.Execute(buf, cfg)
-	if err != nil {
-		return microerror.Mask(err)
+		"Namespace":     m.clusterNamespace,
+		"SSHPublicKey":  m.ssh.azureSSHPublicKey(),
 	}
 
 	amt := &capz.AzureMachineTemplate{}
-	err = yaml.Unmarshal(buf.Bytes(), amt)
+	err := m.templates.render("controlplane_azure_machine_template.yaml.tmpl", cfg, amt)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -184,23 +134,14 @@ func (m *azureMigrator) createMasterAzureMachineTemplate(ctx context.Context) er
 }
 
 func (m *azureMigrator) createWorkersKubeadmConfigTemplate(ctx context.Context) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/workers_kubeadm_config_template_azure.yaml.tmpl")
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
-	cfg := map[string]string{
+	cfg := map[string]interface{}{
 		"ClusterID": m.clusterID,
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = tmpl.Execute(buf, cfg)
-	if err != nil {
-		return microerror.Mask(err)
+		"Namespace": m.clusterNamespace,
+		"SSHUsers":  m.ssh.azureUsers(),
 	}
 
 	kct := &cabpkv1.KubeadmConfigTemplate{}
-	err = yaml.Unmarshal(buf.Bytes(), kct)
+	err := m.templates.render("workers_kubeadm_config_template_azure.yaml.tmpl", cfg, kct)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -218,23 +159,14 @@ func (m *azureMigrator) createWorkersKubeadmConfigTemplate(ctx context.Context)
 }
 
 func (m *azureMigrator) createWorkersAzureMachineTemplate(ctx context.Context) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/workers_azure_machine_template.yaml.tmpl")
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
 	cfg := map[string]string{
-		"ClusterID": m.clusterID,
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = tmpl.Execute(buf, cfg)
-	if err != nil {
-		return microerror.Mask(err)
+		"ClusterID":    m.clusterID,
+		"Namespace":    m.clusterNamespace,
+		"SSHPublicKey": m.ssh.azureSSHPublicKey(),
 	}
 
 	amt := &capz.AzureMachineTemplate{}
-	err = yaml.Unmarshal(buf.Bytes(), amt)
+	err := m.templates.render("workers_azure_machine_template.yaml.tmpl", cfg, amt)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -252,24 +184,15 @@ func (m *azureMigrator) createWorkersAzureMachineTemplate(ctx context.Context) e
 }
 
 func (m *azureMigrator) createWorkersMachineDeployment(ctx context.Context) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/workers_machine_deployment.yaml.tmpl")
-	if err != nil {
-		return microerror.Mask(err)
-	}
-
 	cfg := map[string]string{
-		"ClusterID":  m.clusterID,
-		"K8sVersion": "v1.19.9",
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = tmpl.Execute(buf, cfg)
-	if err != nil {
-		return microerror.Mask(err)
+		"ClusterID":          m.clusterID,
+		"InfrastructureKind": "AzureMachineTemplate",
+		"K8sVersion":         "v1.19.9",
+		"Namespace":          m.clusterNamespace,
 	}
 
 	md := &capi.MachineDeployment{}
-	err = yaml.Unmarshal(buf.Bytes(), md)
+	err := m.templates.render("workers_machine_deployment.yaml.tmpl", cfg, md)
 	if err != nil {
 		return microerror.Mask(err)
 	}
@@ -288,7 +211,7 @@ func (m *azureMigrator) createWorkersMachineDeployment(ctx context.Context) erro
 
 func (m *azureMigrator) readEncryptionSecret(ctx context.Context) error {
 	obj := &corev1.Secret{}
-	key := ctrl.ObjectKey{Namespace: "default", Name: fmt.Sprintf("%s-encryption", m.clusterID)}
+	key := ctrl.ObjectKey{Namespace: "default", Name: key.EncryptionKeySecretName(m.clusterID)}
 	err := m.mcCtrlClient.Get(ctx, key, obj)
 	if err != nil {
 		return microerror.Mask(err)
@@ -406,36 +329,80 @@ func (m *azureMigrator) readRelease(ctx context.Context, ver string) error {
 	return nil
 }
 
+// readCreatedCRs reads CRs created in prepareMissingCRs. Prepare caches them,
+// so this is only needed when the migration is triggered by a new migrator,
+// e.g. from the CLI.
+func (m *azureMigrator) readCreatedCRs(ctx context.Context) error {
+	workers := metav1.ObjectMeta{
+		Name:      key.AzureWorkersName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+	controlPlane := metav1.ObjectMeta{
+		Name:      key.AzureControlPlaneName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+
+	m.crs.kubeadmControlPlane = &kubeadm.KubeadmControlPlane{ObjectMeta: controlPlane}
+	m.crs.masterAzureMachineTemplate = &capz.AzureMachineTemplate{ObjectMeta: controlPlane}
+	m.crs.workersKubeadmConfigTemplate = &cabpkv1.KubeadmConfigTemplate{ObjectMeta: workers}
+	m.crs.workersAzureMachineTemplate = &capz.AzureMachineTemplate{ObjectMeta: workers}
+	m.crs.workersMachineDeployment = &capi.MachineDeployment{ObjectMeta: workers}
+
+	err := readObjects(ctx, m.mcCtrlClient,
+		m.crs.kubeadmControlPlane,
+		m.crs.masterAzureMachineTemplate,
+		m.crs.workersKubeadmConfigTemplate,
+		m.crs.workersAzureMachineTemplate,
+		m.crs.workersMachineDeployment,
+	)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
 func (m *azureMigrator) updateCluster(ctx context.Context) error {
 	cluster := m.crs.cluster
+	original := cluster.DeepCopy()
 
 	// Drop operator version label.
 	delete(cluster.Labels, label.AzureOperatorVersion)
 
-	// Drop finalizers.
-	cluster.Finalizers = nil
+	// Drop finalizers of legacy operators.
+	dropLegacyFinalizers(cluster)
 
 	// Adjust k8s apiserver bind port to match kubeadm.
 	if cluster.Spec.ClusterNetwork != nil && cluster.Spec.ClusterNetwork.APIServerPort != nil {
 		cluster.Spec.ClusterNetwork.APIServerPort = to.Int32Ptr(6443)
 	}
 
+	// Type meta of m.crs.kubeadmControlPlane is cleared when it is decoded
+	// by the API client, so it can't be used here.
 	cluster.Spec.ControlPlaneRef = &corev1.ObjectReference{
-		APIVersion: m.crs.kubeadmControlPlane.APIVersion,
-		Kind:       m.crs.kubeadmControlPlane.Kind,
+		APIVersion: kubeadm.GroupVersion.String(),
+		Kind:       "KubeadmControlPlane",
 		Name:       m.crs.kubeadmControlPlane.Name,
 	}
 
+	mutated := cluster.DeepCopy()
+
 	err := m.mcCtrlClient.Update(ctx, cluster)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	err = m.status.recordMutation(ctx, "Cluster", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
 func (m *azureMigrator) updateAzureCluster(ctx context.Context) error {
 	cluster := m.crs.azureCluster
+	original := cluster.DeepCopy()
 
 	// Drop operator version label.
 	delete(cluster.Labels, label.AzureOperatorVersion)
@@ -536,11 +503,18 @@ func (m *azureMigrator) updateAzureCluster(ctx context.Context) error {
 		cluster.Spec.NetworkSpec.Subnets = append(cluster.Spec.NetworkSpec.Subnets, s)
 	}
 
+	mutated := cluster.DeepCopy()
+
 	err = m.mcCtrlClient.Update(ctx, cluster)
 	if err != nil {
 		return microerror.Mask(err)
 	}
 
+	err = m.status.recordMutation(ctx, "AzureCluster", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
 	return nil
 }
 
@@ -587,3 +561,18 @@ func getMasterIPForVNet(vnet *net.IPNet) net.IP {
 
 	return net.IPv4(ip[0], ip[1], ip[2], ip[3]+4)
 }
+
+// backupCRs stores CRs mutated during migration in the backup of the
+// current migration attempt.
+func (m *azureMigrator) backupCRs(ctx context.Context) error {
+	err := m.backup.save(ctx, map[string]runtime.Object{
+		backupCluster:               m.crs.cluster,
+		backupInfrastructureCluster: m.crs.azureCluster,
+		backupAzureConfig:           m.crs.azureConfig,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
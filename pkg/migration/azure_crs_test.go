@@ -0,0 +1,148 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_azureMigrator_Golden(t *testing.T) {
+	ctx := context.Background()
+	scheme := newGoldenScheme(t)
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	clusterLabels := map[string]string{
+		capi.ClusterLabelName:      "abc12",
+		label.AzureOperatorVersion: "5.5.0",
+		label.ReleaseVersion:       "14.1.0",
+	}
+
+	apiServerPort := int32(443)
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "abc12",
+			Namespace:  "org-giantswarm",
+			Labels:     clusterLabels,
+			Finalizers: []string{"operatorkit.giantswarm.io/cluster-operator-cluster-controller"},
+		},
+		Spec: capi.ClusterSpec{
+			ClusterNetwork: &capi.ClusterNetwork{APIServerPort: &apiServerPort},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: capz.GroupVersion.String(),
+				Kind:       "AzureCluster",
+				Name:       "abc12",
+				Namespace:  "org-giantswarm",
+			},
+		},
+	}
+
+	objs := []runtime.Object{
+		cluster,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12-encryption", Namespace: "default"},
+			Data:       map[string][]byte{"encryption": []byte("c2VjcmV0LWVuY3J5cHRpb24ta2V5")},
+		},
+		&provider.AzureConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default", Labels: clusterLabels},
+		},
+		&capz.AzureCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "abc12",
+				Namespace:  "org-giantswarm",
+				Labels:     clusterLabels,
+				Finalizers: []string{"operatorkit.giantswarm.io/azure-operator-cluster-controller"},
+			},
+			Spec: capz.AzureClusterSpec{
+				Location: "westeurope",
+				ControlPlaneEndpoint: capi.APIEndpoint{
+					Host: "api.abc12.k8s.westeurope.azure.example.com",
+					Port: 443,
+				},
+				NetworkSpec: capz.NetworkSpec{
+					Vnet: capz.VnetSpec{
+						Name:       "abc12-VirtualNetwork",
+						CIDRBlocks: []string{"10.10.0.0/16"},
+					},
+				},
+			},
+		},
+		&release.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: "v14.1.0"},
+			Spec: release.ReleaseSpec{
+				Components: []release.ReleaseSpecComponent{
+					{Name: "kubernetes", Version: "1.19.9"},
+					{Name: "etcd", Version: "3.4.14"},
+				},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	_, err = EnsureClusterMigration(ctx, c, scheme, cluster, ProviderAzure)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	m := &azureMigrator{
+		migratorBase: migratorBase{
+			clusterID:        "abc12",
+			clusterNamespace: "org-giantswarm",
+
+			logger:       logger,
+			mcCtrlClient: c,
+			status:       newMigrationStatus(c, cluster, false),
+			ssh: SSHConfig{
+				Users:             []SSHUser{{Name: "jane", AuthorizedKeys: []string{"ssh-ed25519 AAAA jane@example.com"}}},
+				AzureSSHPublicKey: "c3NoLWVkMjU1MTkgQUFBQQ==",
+			},
+		},
+	}
+
+	err = m.readCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.prepareMissingCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.updateCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	meta := func(namespace, name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace}
+	}
+
+	assertGolden(t, c, scheme, "azure",
+		&corev1.Secret{ObjectMeta: meta("org-giantswarm", "abc12-k8s-encryption-config")},
+		&corev1.Secret{ObjectMeta: meta("org-giantswarm", "abc12-proxy-config")},
+		&kubeadm.KubeadmControlPlane{ObjectMeta: meta("org-giantswarm", "abc12-control-plane")},
+		&capz.AzureMachineTemplate{ObjectMeta: meta("org-giantswarm", "abc12-control-plane")},
+		&bootstrap.KubeadmConfigTemplate{ObjectMeta: meta("org-giantswarm", "abc12-md-0")},
+		&capz.AzureMachineTemplate{ObjectMeta: meta("org-giantswarm", "abc12-md-0")},
+		&capi.MachineDeployment{ObjectMeta: meta("org-giantswarm", "abc12-md-0")},
+		&capi.Cluster{ObjectMeta: meta("org-giantswarm", "abc12")},
+		&capz.AzureCluster{ObjectMeta: meta("org-giantswarm", "abc12")},
+	)
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/runtime"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	capzexp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=azuremachinetemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=azureclusters,verbs=get;list;watch;update;patch
+
+func init() {
+	RegisterProvider(Provider{
+		Name:                ProviderAzure,
+		InfrastructureKinds: []string{"AzureCluster"},
+
+		AddToScheme:        addAzureToScheme,
+		NewMigratorFactory: newAzureProviderMigratorFactory,
+	})
+}
+
+func addAzureToScheme(s *runtime.Scheme) error {
+	for _, add := range []func(*runtime.Scheme) error{
+		provider.AddToScheme,
+		capz.AddToScheme,
+		capzexp.AddToScheme,
+	} {
+		err := add(s)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func newAzureProviderMigratorFactory(cfg ProviderConfig) (MigratorFactory, error) {
+	f, err := NewAzureMigratorFactory(AzureMigrationConfig{
+		CtrlClient:    cfg.CtrlClient,
+		EventRecorder: cfg.EventRecorder,
+		Logger:        cfg.Logger,
+		Scheme:        cfg.Scheme,
+		TenantCluster: cfg.TenantCluster,
+		VaultClient:   cfg.VaultClient,
+
+		DryRun:       cfg.DryRun,
+		SSH:          cfg.SSH,
+		SSHConfigMap: cfg.SSHConfigMap,
+
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
+
+		NewVMSSClient: cfg.NewAzureVMSSClient,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f, nil
+}
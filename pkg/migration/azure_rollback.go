@@ -0,0 +1,137 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capz "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+func (m *azureMigrator) rollback(ctx context.Context) error {
+	err := m.readCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readAzureCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreAzureCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.deleteCreatedCRs(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.startOldMasterComponents(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *azureMigrator) restoreCluster(ctx context.Context) error {
+	original := &capi.Cluster{}
+	err := m.backup.load(ctx, backupCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster := m.crs.cluster
+	restoreMetadata(cluster, original)
+	cluster.Spec.ControlPlaneRef = original.Spec.ControlPlaneRef
+	cluster.Spec.ClusterNetwork = original.Spec.ClusterNetwork
+
+	err = m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored Cluster %s/%s from backup", cluster.Namespace, cluster.Name)
+
+	return nil
+}
+
+func (m *azureMigrator) restoreAzureCluster(ctx context.Context) error {
+	original := &capz.AzureCluster{}
+	err := m.backup.load(ctx, backupInfrastructureCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster := m.crs.azureCluster
+	restoreMetadata(cluster, original)
+
+	err = m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored AzureCluster %s/%s from backup", cluster.Namespace, cluster.Name)
+
+	return nil
+}
+
+// deleteCreatedCRs deletes CRs and secrets created in prepareMissingCRs.
+// Workers go first, so that upstream controllers don't recreate them while
+// the control plane is being deleted.
+func (m *azureMigrator) deleteCreatedCRs(ctx context.Context) error {
+	workers := metav1.ObjectMeta{
+		Name:      key.AzureWorkersName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+	controlPlane := metav1.ObjectMeta{
+		Name:      key.AzureControlPlaneName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+
+	objs := []runtime.Object{
+		&capi.MachineDeployment{ObjectMeta: workers},
+		&cabpkv1.KubeadmConfigTemplate{ObjectMeta: workers},
+		&capz.AzureMachineTemplate{ObjectMeta: workers},
+		&kubeadm.KubeadmControlPlane{ObjectMeta: controlPlane},
+		&capz.AzureMachineTemplate{ObjectMeta: controlPlane},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.EncryptionConfigSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.ProxyConfigSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+	}
+
+	for _, obj := range objs {
+		err := deleteIfExists(ctx, m.mcCtrlClient, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	m.logger.Debugf(ctx, "deleted CRs and secrets created for upstream controllers")
+
+	return nil
+}
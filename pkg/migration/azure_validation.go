@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+// validate runs the shared pre-flight checks of validateMigration and checks
+// Azure infrastructure of the cluster.
+func (m *azureMigrator) validate(ctx context.Context) *validationFindings {
+	f := &validationFindings{}
+
+	err := m.readAzureConfig(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read AzureConfig: %s", microerror.Cause(err))
+	}
+
+	err = m.readCluster(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read Cluster: %s", microerror.Cause(err))
+		return f
+	}
+
+	err = m.readRelease(ctx, m.crs.cluster.GetLabels()[label.ReleaseVersion])
+	if err != nil {
+		f.blocking(checkRelease, "failed to read Release: %s", microerror.Cause(err))
+	}
+
+	validateMigration(ctx, f, m.mcCtrlClient, m.wcCtrlClient, m.certs, m.crs.cluster, m.crs.release, azureInfrastructureComponent)
+
+	err = m.readAzureCluster(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read AzureCluster: %s", microerror.Cause(err))
+		return f
+	}
+
+	vmssClient, err := m.getVMSSClient(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to create Azure API client: %s", microerror.Cause(err))
+		return f
+	}
+
+	vmssName := key.AzureMasterVMSSName(m.clusterID)
+	_, err = vmssClient.Get(ctx, m.clusterID, vmssName)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to get master VMSS %s in resource group %s: %s", vmssName, m.clusterID, err)
+	}
+
+	return f
+}
@@ -0,0 +1,254 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+	"github.com/giantswarm/capi-migration/pkg/project"
+)
+
+const (
+	backupCluster               = "cluster.json"
+	backupInfrastructureCluster = "infrastructure-cluster.json"
+	backupAzureConfig           = "azureconfig.json"
+)
+
+var (
+	backupAttemptLabel = project.Name() + ".giantswarm.io/backup-attempt"
+)
+
+// backupSecret returns the name under which given secret is backed up.
+func backupSecret(name string) string {
+	return fmt.Sprintf("secret-%s.json", name)
+}
+
+// migrationBackup stores CRs as they were before the migration mutated them.
+// Every migration attempt gets its own backup Secret, so the state from
+// before a rolled back attempt is not lost when the cluster is migrated
+// again.
+type migrationBackup struct {
+	client     ctrl.Client
+	scheme     *runtime.Scheme
+	status     *migrationStatus
+	clusterKey ctrl.ObjectKey
+}
+
+func newMigrationBackup(c ctrl.Client, scheme *runtime.Scheme, status *migrationStatus, cluster *capi.Cluster) *migrationBackup {
+	return &migrationBackup{
+		client:     c,
+		scheme:     scheme,
+		status:     status,
+		clusterKey: ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name},
+	}
+}
+
+// save stores given objects under given names in the backup of the current
+// migration attempt. Objects which have been stored already are kept, so the
+// backup always holds the state from before the attempt started mutating
+// them.
+func (b *migrationBackup) save(ctx context.Context, objs map[string]runtime.Object) error {
+	attempt, err := b.status.attempt(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	secretKey := ctrl.ObjectKey{Namespace: b.clusterKey.Namespace, Name: key.MigrationBackupSecretName(b.clusterKey.Name, attempt)}
+
+	secret := &corev1.Secret{}
+	err = b.client.Get(ctx, secretKey, secret)
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+				Labels: map[string]string{
+					capi.ClusterLabelName: b.clusterKey.Name,
+					backupAttemptLabel:    strconv.Itoa(attempt),
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	var changed bool
+	for name, obj := range objs {
+		if _, ok := secret.Data[name]; ok {
+			continue
+		}
+
+		data, err := b.marshal(obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		secret.Data[name] = data
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if secret.ResourceVersion == "" {
+		err = b.client.Create(ctx, secret)
+	} else {
+		err = b.client.Update(ctx, secret)
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// load reads the object stored under given name in the backup of the current
+// migration attempt into obj.
+func (b *migrationBackup) load(ctx context.Context, name string, obj runtime.Object) error {
+	attempt, err := b.status.attempt(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	secret, err := getBackupSecret(ctx, b.client, b.clusterKey, attempt)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data, ok := secret.Data[name]
+	if !ok {
+		return microerror.Maskf(backupNotFoundError, "key %q not found in Secret %s/%s", name, secret.Namespace, secret.Name)
+	}
+
+	err = json.Unmarshal(data, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// marshal serializes given object together with its kind, so that it can be
+// restored without knowing its type.
+func (b *migrationBackup) marshal(obj runtime.Object) ([]byte, error) {
+	gvk, err := apiutil.GVKForObject(obj, b.scheme)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	obj = obj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	accessor.SetManagedFields(nil)
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return data, nil
+}
+
+// RestoreBackup writes all objects stored in the backup of given migration
+// attempt back to the management cluster. Objects are restored as a whole,
+// including their spec, labels, annotations and finalizers. Objects which
+// don't exist anymore are recreated.
+func RestoreBackup(ctx context.Context, c ctrl.Client, clusterKey ctrl.ObjectKey, attempt int) error {
+	secret, err := getBackupSecret(ctx, c, clusterKey, attempt)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, data := range secret.Data {
+		original := &unstructured.Unstructured{}
+		err = original.UnmarshalJSON(data)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		unstructured.RemoveNestedField(original.Object, "status")
+		original.SetManagedFields(nil)
+
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(original.GroupVersionKind())
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: original.GetNamespace(), Name: original.GetName()}, current)
+		if apierrors.IsNotFound(err) {
+			original.SetResourceVersion("")
+			original.SetUID("")
+			original.SetCreationTimestamp(metav1.Time{})
+
+			err = c.Create(ctx, original)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		} else if err != nil {
+			return microerror.Mask(err)
+		} else {
+			original.SetResourceVersion(current.GetResourceVersion())
+
+			err = c.Update(ctx, original)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func getBackupSecret(ctx context.Context, c ctrl.Client, clusterKey ctrl.ObjectKey, attempt int) (*corev1.Secret, error) {
+	secretKey := ctrl.ObjectKey{Namespace: clusterKey.Namespace, Name: key.MigrationBackupSecretName(clusterKey.Name, attempt)}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, secretKey, secret)
+	if apierrors.IsNotFound(err) {
+		return nil, microerror.Maskf(backupNotFoundError, "Secret %s not found", secretKey)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return secret, nil
+}
+
+// restoreMetadata sets labels and finalizers of current object to the ones
+// of the original object.
+func restoreMetadata(current metav1.Object, original metav1.Object) {
+	current.SetLabels(original.GetLabels())
+	current.SetFinalizers(original.GetFinalizers())
+}
+
+// deleteIfExists deletes given object and ignores the error when it is gone
+// already.
+func deleteIfExists(ctx context.Context, c ctrl.Client, obj runtime.Object) error {
+	err := c.Delete(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+func Test_migrationBackup(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = capi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	cluster := newTestCluster(false)
+	cluster.Finalizers = []string{"operatorkit.giantswarm.io/cluster-operator"}
+
+	cm := &v1alpha1.ClusterMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc12-service-account",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"cert": []byte("cert"),
+			"key":  []byte("key"),
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, cluster.DeepCopy(), cm, secret.DeepCopy())
+	status := newMigrationStatus(c, cluster, false)
+	backup := newMigrationBackup(c, scheme, status, cluster)
+
+	err := status.setPhase(ctx, v1alpha1.ClusterMigrationPhasePreparing)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = backup.load(ctx, backupCluster, &capi.Cluster{})
+	if !IsBackupNotFound(err) {
+		t.Fatalf("expected backupNotFoundError, got %#v", err)
+	}
+
+	err = backup.save(ctx, map[string]runtime.Object{
+		backupCluster:                         cluster,
+		backupSecret("abc12-service-account"): secret,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// Backup of the mutated cluster must not overwrite the original in the
+	// same attempt.
+	mutated := cluster.DeepCopy()
+	mutated.Finalizers = nil
+	mutated.Labels[watchFilterLabel] = "0.3.13"
+
+	err = backup.save(ctx, map[string]runtime.Object{backupCluster: mutated})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	original := &capi.Cluster{}
+	err = backup.load(ctx, backupCluster, original)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	restoreMetadata(mutated, original)
+
+	if _, ok := mutated.Labels[watchFilterLabel]; ok {
+		t.Fatalf("expected label %q to be removed", watchFilterLabel)
+	}
+	if len(mutated.Finalizers) != 1 || mutated.Finalizers[0] != cluster.Finalizers[0] {
+		t.Fatalf("expected finalizers %v, got %v", cluster.Finalizers, mutated.Finalizers)
+	}
+
+	// Mutate the secret in place like certificate migration does and
+	// restore it explicitly.
+	{
+		current := &corev1.Secret{}
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-service-account"}, current)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		delete(current.Data, "cert")
+		current.Data["tls.key"] = current.Data["key"]
+		err = c.Update(ctx, current)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		err = RestoreBackup(ctx, c, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		restored := &corev1.Secret{}
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-service-account"}, restored)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if string(restored.Data["cert"]) != "cert" {
+			t.Fatalf("expected restored cert data, got %q", restored.Data["cert"])
+		}
+		if _, ok := restored.Data["tls.key"]; ok {
+			t.Fatalf("expected tls.key to be removed")
+		}
+	}
+
+	// Next attempt gets a fresh backup.
+	err = status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseRolledBack)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	err = status.setPhase(ctx, v1alpha1.ClusterMigrationPhasePreparing)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	attempt, err := status.attempt(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if attempt != 2 {
+		t.Fatalf("expected attempt 2, got %d", attempt)
+	}
+
+	err = backup.load(ctx, backupCluster, &capi.Cluster{})
+	if !IsBackupNotFound(err) {
+		t.Fatalf("expected backupNotFoundError, got %#v", err)
+	}
+}
@@ -0,0 +1,415 @@
+package migration
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	vaultclient "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capisecret "sigs.k8s.io/cluster-api/util/secret"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+// Keys and labels of secrets created by cert-operator.
+const (
+	legacyCertKey          = "crt"
+	legacyKeyKey           = "key"
+	legacyCertificateLabel = "giantswarm.io/certificate"
+)
+
+// etcdLegacyClientPurpose names the secret holding the legacy etcd client
+// certificate next to CAPI certificate secrets.
+const etcdLegacyClientPurpose capisecret.Purpose = "etcd-legacy-client"
+
+// capiCertPurposes lists purposes of secrets created by migrate.
+var capiCertPurposes = []capisecret.Purpose{
+	capisecret.ClusterCA,
+	capisecret.EtcdCA,
+	capisecret.FrontProxyCA,
+	capisecret.ServiceAccount,
+	etcdLegacyClientPurpose,
+}
+
+// certsMigrator creates CAPI certificate secrets of legacy clusters. Legacy
+// certificates are laid out the same way for all providers, so every
+// migrator uses it.
+type certsMigrator struct {
+	ctrlClient  ctrl.Client
+	vaultClient *vaultclient.Client
+}
+
+func newCertsMigrator(ctrlClient ctrl.Client, vaultClient *vaultclient.Client) *certsMigrator {
+	return &certsMigrator{
+		ctrlClient:  ctrlClient,
+		vaultClient: vaultClient,
+	}
+}
+
+// validate checks that legacy certificate secrets and the CA in Vault can be
+// read and migrated by migrate.
+func (m *certsMigrator) validate(ctx context.Context, f *validationFindings, cluster *capi.Cluster) {
+	clusterID := cluster.Name
+	n := len(f.findings)
+
+	validateSecrets(ctx, f, m.ctrlClient, []ctrl.ObjectKey{
+		{Namespace: "default", Name: key.APICertsSecretName(clusterID)},
+		{Namespace: "default", Name: key.SACertsSecretName(clusterID)},
+		{Namespace: "default", Name: key.EtcdCertsSecretName(clusterID)},
+	})
+
+	validateVault(f, m.vaultClient)
+
+	// Missing secrets and unhealthy Vault are reported already.
+	if len(f.findings) > n {
+		return
+	}
+
+	// Prepare reads the same Vault endpoints and runs the same checks, so a
+	// missing policy or broken CA material fails the migration before
+	// anything is backed up or written.
+	_, err := m.readCerts(ctx, clusterID, time.Now())
+	if err != nil {
+		f.blocking(checkCertificates, "certificates can't be migrated: %s", microerror.Pretty(err, false))
+	}
+}
+
+// migrate creates CAPI certificate secrets of the cluster from the CA in
+// Vault and legacy secrets. They are created in the Cluster namespace, where
+// the KubeadmControlPlane of the cluster reads them. Keys of legacy secrets
+// are never changed, so legacy operators keep working until the cluster is
+// handed over.
+func (m *certsMigrator) migrate(ctx context.Context, cluster *capi.Cluster) error {
+	certs, err := m.readCerts(ctx, cluster.Name, time.Now())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, cert := range certs {
+		err = ensureCAPICertSecret(ctx, m.ctrlClient, cluster, cert.purpose, cert.cert, cert.key)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// capiCert is the content of a CAPI certificate secret.
+type capiCert struct {
+	purpose capisecret.Purpose
+	cert    []byte
+	key     []byte
+}
+
+// readCerts reads the CA from Vault and legacy secrets and returns the
+// content of CAPI certificate secrets. Masters bootstrapped from broken CA
+// material never join, so it is checked here, before anything is written.
+func (m *certsMigrator) readCerts(ctx context.Context, clusterID string, now time.Time) ([]capiCert, error) {
+	c := m.ctrlClient
+	vaultClient := m.vaultClient
+
+	ca, err := getCABundle(vaultClient, key.VaultPKIHackyEndpoint(clusterID))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	apiCert, _, err := getLegacyCertificate(ctx, c, key.APICertsSecretName(clusterID))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = validateCABundle(ca, apiCert, now)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	etcdCert, etcdKey, err := getLegacyCertificate(ctx, c, key.EtcdCertsSecretName(clusterID))
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	etcdCA, err := getEtcdCABundle(vaultClient, ca, clusterID, etcdCert, now)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	saPublicKey, saPrivateKey, err := getServiceAccountKeyPair(ctx, c, clusterID)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	// Legacy clusters have no front proxy CA, so it is the cluster CA. The
+	// legacy etcd client certificate is copied for the script joining new
+	// masters to the existing etcd cluster, because bootstrap files can only
+	// reference secrets in the Cluster namespace.
+	certs := []capiCert{
+		{purpose: capisecret.ClusterCA, cert: ca.certPEM, key: ca.keyPEM},
+		{purpose: capisecret.EtcdCA, cert: etcdCA.certPEM, key: etcdCA.keyPEM},
+		{purpose: capisecret.FrontProxyCA, cert: ca.certPEM, key: ca.keyPEM},
+		{purpose: capisecret.ServiceAccount, cert: saPublicKey, key: saPrivateKey},
+		{purpose: etcdLegacyClientPurpose, cert: etcdCert, key: etcdKey},
+	}
+
+	return certs, nil
+}
+
+// rollback deletes CAPI certificate secrets created by migrate. Secrets of
+// cert-operator with the same name are kept, only the keys added by migrate
+// are removed.
+func (m *certsMigrator) rollback(ctx context.Context, cluster *capi.Cluster) error {
+	for _, purpose := range capiCertPurposes {
+		secret := &corev1.Secret{}
+		k := ctrl.ObjectKey{Namespace: cluster.Namespace, Name: capisecret.Name(cluster.Name, purpose)}
+		err := m.ctrlClient.Get(ctx, k, secret)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if isLegacyCertSecret(secret) {
+			err = removeCAPICertKeys(ctx, m.ctrlClient, secret)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			continue
+		}
+
+		err = deleteIfExists(ctx, m.ctrlClient, secret)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// isLegacyCertSecret returns true for secrets of cert-operator. Their keys
+// are checked as well, so that they are never overwritten even when their
+// labels have been changed.
+func isLegacyCertSecret(secret *corev1.Secret) bool {
+	if secret.Labels[legacyCertificateLabel] != "" {
+		return true
+	}
+	_, ok := secret.Data[legacyCertKey]
+
+	return ok
+}
+
+// removeCAPICertKeys removes keys added by ensureCAPICertSecret from the
+// given cert-operator secret.
+func removeCAPICertKeys(ctx context.Context, c ctrl.Client, secret *corev1.Secret) error {
+	var changed bool
+	for _, k := range []string{capisecret.TLSCrtDataName, capisecret.TLSKeyDataName} {
+		if _, ok := secret.Data[k]; ok {
+			delete(secret.Data, k)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	err := c.Update(ctx, secret)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// validateCABundle checks that the CA is valid for long enough and that the
+// given legacy certificate was issued by it, so existing masters and new ones
+// trust each other during the migration.
+func validateCABundle(ca *caBundle, legacyCert []byte, now time.Time) error {
+	err := ca.validateLifetime(now, caMinRemainingLifetime)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = ca.verifyLeaf(now, legacyCert)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// getEtcdCABundle returns the CA which issued the legacy etcd certificate.
+// Most clusters use the cluster CA for etcd. Otherwise the etcd CA is read
+// from the separate etcd PKI in Vault.
+func getEtcdCABundle(vaultClient *vaultclient.Client, ca *caBundle, clusterID string, etcdCert []byte, now time.Time) (*caBundle, error) {
+	err := ca.verifyLeaf(now, etcdCert)
+	if err == nil {
+		return ca, nil
+	} else if !IsInvalidCertificate(err) {
+		return nil, microerror.Mask(err)
+	}
+
+	etcdCA, err := getCABundle(vaultClient, key.VaultEtcdPKIHackyEndpoint(clusterID))
+	if err != nil {
+		return nil, microerror.Maskf(invalidCertificateError, "etcd certificate is not issued by the cluster CA and etcd CA can't be read: %s", microerror.Pretty(err, false))
+	}
+	err = validateCABundle(etcdCA, etcdCert, now)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return etcdCA, nil
+}
+
+// getLegacyCertificate returns the PEM encoded certificate and private key of
+// the cert-operator secret.
+func getLegacyCertificate(ctx context.Context, c ctrl.Client, name string) ([]byte, []byte, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: name}, secret)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	for _, k := range []string{legacyCertKey, legacyKeyKey} {
+		if len(secret.Data[k]) == 0 {
+			return nil, nil, microerror.Maskf(invalidCertificateError, "secret %q has no %q key", name, k)
+		}
+	}
+
+	return secret.Data[legacyCertKey], secret.Data[legacyKeyKey], nil
+}
+
+// ensureCAPICertSecret creates or updates the secret of the given purpose in
+// the Cluster namespace the way KubeadmControlPlane expects it. Keys of
+// cert-operator secrets with the same name are never written.
+func ensureCAPICertSecret(ctx context.Context, c ctrl.Client, cluster *capi.Cluster, purpose capisecret.Purpose, cert, key []byte) error {
+	desired := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      capisecret.Name(cluster.Name, purpose),
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				capi.ClusterLabelName: cluster.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					// Type meta is cleared when the object is decoded by the
+					// API client.
+					APIVersion: capi.GroupVersion.String(),
+					Kind:       "Cluster",
+					Name:       cluster.Name,
+					UID:        cluster.UID,
+				},
+			},
+		},
+		Type: capi.ClusterSecretType,
+		Data: map[string][]byte{
+			capisecret.TLSCrtDataName: cert,
+			capisecret.TLSKeyDataName: key,
+		},
+	}
+
+	current := &corev1.Secret{}
+	err := c.Get(ctx, ctrl.ObjectKey{Namespace: desired.Namespace, Name: desired.Name}, current)
+	if apierrors.IsNotFound(err) {
+		err = c.Create(ctx, desired)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// cert-operator's etcd secret has the name of the CAPI etcd CA secret
+	// for clusters in the default namespace. KubeadmControlPlane looks
+	// secrets up by name only, so CAPI keys are added next to the keys
+	// legacy operators keep reading. Labels, owner references and type are
+	// left alone, so the secret is not deleted together with the Cluster.
+	if isLegacyCertSecret(current) {
+		if current.Data == nil {
+			current.Data = map[string][]byte{}
+		}
+		for k, v := range desired.Data {
+			current.Data[k] = v
+		}
+
+		err = c.Update(ctx, current)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	// Labels are merged, so meta.Label.Cluster set on creation is kept.
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		current.Labels[k] = v
+	}
+	current.OwnerReferences = desired.OwnerReferences
+	current.Data = desired.Data
+
+	err = c.Update(ctx, current)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// getServiceAccountKeyPair returns the public and private key of the legacy
+// service account secret in PEM. Legacy secrets hold a certificate, while
+// kubeadm expects the bare public key.
+func getServiceAccountKeyPair(ctx context.Context, c ctrl.Client, clusterID string) ([]byte, []byte, error) {
+	name := key.SACertsSecretName(clusterID)
+	_, keyPEM, err := getLegacyCertificate(ctx, c, name)
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+
+	privateKey, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, nil, microerror.Maskf(invalidCertificateError, "secret %q: %s", name, microerror.Pretty(err, false))
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, microerror.Mask(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	return publicPEM, keyPEM, nil
+}
+
+// getCABundle reads vault PKI endpoint at path and parses CA private key and
+// CA certificate.
+func getCABundle(vaultClient *vaultclient.Client, path string) (*caBundle, error) {
+	secret, err := vaultClient.Logical().Read(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if secret == nil {
+		return nil, microerror.Maskf(invalidCertificateError, "vault returned no data at %q", path)
+	}
+
+	// Vault responses are JSON, so PEM data is always decoded as string.
+	keyData, ok := secret.Data["private_key"].(string)
+	if !ok {
+		return nil, microerror.Maskf(invalidCertificateError, "vault returned no private key at %q", path)
+	}
+	certData, ok := secret.Data["certificate"].(string)
+	if !ok {
+		return nil, microerror.Maskf(invalidCertificateError, "vault returned no certificate at %q", path)
+	}
+
+	ca, err := parseCABundle([]byte(certData), []byte(keyData))
+	if err != nil {
+		return nil, microerror.Maskf(invalidCertificateError, "CA at %q: %s", path, microerror.Pretty(err, false))
+	}
+
+	return ca, nil
+}
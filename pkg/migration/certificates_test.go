@@ -0,0 +1,542 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	vaultclient "github.com/hashicorp/vault/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capisecret "sigs.k8s.io/cluster-api/util/secret"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+func Test_ensureCAPICertSecret(t *testing.T) {
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default", UID: "1234"},
+	}
+
+	testCases := []struct {
+		name          string
+		existing      []runtime.Object
+		purpose       capisecret.Purpose
+		errorMatcher  func(error) bool
+		expectedType  corev1.SecretType
+		expectedData  map[string]string
+		expectedOwned bool
+		// expectedLabel is a label which must be kept on update.
+		expectedLabel string
+	}{
+		{
+			name:          "case 0: secret is created",
+			purpose:       capisecret.ClusterCA,
+			expectedType:  capi.ClusterSecretType,
+			expectedData:  map[string]string{"tls.crt": "cert", "tls.key": "key"},
+			expectedOwned: true,
+		},
+		{
+			name: "case 1: secret of a previous attempt is updated",
+			existing: []runtime.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "abc12-sa",
+						Namespace: "default",
+						Labels:    map[string]string{meta.Label.Cluster.Key(): "abc12"},
+					},
+					Type: capi.ClusterSecretType,
+					Data: map[string][]byte{"tls.crt": []byte("old"), "tls.key": []byte("old")},
+				},
+			},
+			purpose:       capisecret.ServiceAccount,
+			expectedType:  capi.ClusterSecretType,
+			expectedData:  map[string]string{"tls.crt": "cert", "tls.key": "key"},
+			expectedOwned: true,
+			expectedLabel: meta.Label.Cluster.Key(),
+		},
+		{
+			name: "case 2: CAPI keys are added next to keys of legacy etcd secret",
+			existing: []runtime.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "abc12-etcd",
+						Namespace: "default",
+						Labels:    map[string]string{legacyCertificateLabel: "etcd"},
+					},
+					Type: corev1.SecretTypeOpaque,
+					Data: map[string][]byte{"crt": []byte("legacy-crt"), "key": []byte("legacy-key")},
+				},
+			},
+			purpose:      capisecret.EtcdCA,
+			expectedType: corev1.SecretTypeOpaque,
+			expectedData: map[string]string{"crt": "legacy-crt", "key": "legacy-key", "tls.crt": "cert", "tls.key": "key"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			c := fake.NewFakeClientWithScheme(scheme, tc.existing...)
+
+			err := ensureCAPICertSecret(ctx, c, cluster, tc.purpose, []byte("cert"), []byte("key"))
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			secret := &corev1.Secret{}
+			err = c.Get(ctx, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: capisecret.Name("abc12", tc.purpose)}, secret)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if secret.Type != tc.expectedType {
+				t.Fatalf("expected type %q, got %q", tc.expectedType, secret.Type)
+			}
+			if len(secret.Data) != len(tc.expectedData) {
+				t.Fatalf("expected %d keys, got %d", len(tc.expectedData), len(secret.Data))
+			}
+			for k, v := range tc.expectedData {
+				if string(secret.Data[k]) != v {
+					t.Fatalf("expected %q in %q, got %q", v, k, secret.Data[k])
+				}
+			}
+			if tc.expectedOwned && secret.Labels[capi.ClusterLabelName] != "abc12" {
+				t.Fatalf("expected cluster label, got %#v", secret.Labels)
+			}
+			if !tc.expectedOwned && secret.Labels[capi.ClusterLabelName] != "" {
+				t.Fatalf("expected no cluster label, got %#v", secret.Labels)
+			}
+			if tc.expectedLabel != "" && secret.Labels[tc.expectedLabel] != "abc12" {
+				t.Fatalf("expected label %q to be kept, got %#v", tc.expectedLabel, secret.Labels)
+			}
+			if tc.expectedOwned && (len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != cluster.UID) {
+				t.Fatalf("expected owner reference to the Cluster, got %#v", secret.OwnerReferences)
+			}
+			if !tc.expectedOwned && len(secret.OwnerReferences) != 0 {
+				t.Fatalf("expected no owner references, got %#v", secret.OwnerReferences)
+			}
+		})
+	}
+}
+
+func Test_certsMigrator_validate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		clusterNamespace string
+		expectedFindings int
+	}{
+		{
+			name:             "case 0: Cluster outside of the default namespace is valid",
+			clusterNamespace: "org-acme",
+			expectedFindings: 0,
+		},
+		{
+			name:             "case 1: Cluster in the default namespace is valid",
+			clusterNamespace: "default",
+			expectedFindings: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+
+			var objs []runtime.Object
+			for _, name := range []string{"abc12-api", "abc12-etcd", "abc12-service-account"} {
+				objs = append(objs, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels:    map[string]string{legacyCertificateLabel: strings.TrimPrefix(name, "abc12-")},
+					},
+				})
+			}
+			c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+			// Vault is not reachable. Its findings are not checked here.
+			vaultClient, err := vaultclient.NewClient(&vaultclient.Config{Address: "http://127.0.0.1:0"})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: tc.clusterNamespace},
+			}
+
+			f := &validationFindings{}
+			newCertsMigrator(c, vaultClient).validate(ctx, f, cluster)
+
+			var findings []string
+			for _, finding := range f.findings {
+				if finding.Check == checkSecrets {
+					findings = append(findings, finding.Message)
+				}
+			}
+			if len(findings) != tc.expectedFindings {
+				t.Fatalf("expected %d findings, got %#v", tc.expectedFindings, findings)
+			}
+		})
+	}
+}
+
+func Test_certsMigrator_validateCertificates(t *testing.T) {
+	now := time.Now()
+	year := 365 * 24 * time.Hour
+
+	ca := newTestCert(t, "abc12", true, now, year, nil)
+	expiringCA := newTestCert(t, "abc12", true, now, 24*time.Hour, nil)
+
+	testCases := []struct {
+		name             string
+		ca               *testCert
+		issuer           *testCert
+		deniedPath       string
+		expectedFindings int
+	}{
+		{
+			name:             "case 0: certificates can be migrated",
+			ca:               ca,
+			issuer:           ca,
+			expectedFindings: 0,
+		},
+		{
+			name:             "case 1: Vault policy denies reading the CA private key",
+			ca:               ca,
+			issuer:           ca,
+			deniedPath:       "/v1/pki-abc12/gimmeallyourlovin",
+			expectedFindings: 1,
+		},
+		{
+			name:             "case 2: CA expires too soon",
+			ca:               expiringCA,
+			issuer:           expiringCA,
+			expectedFindings: 1,
+		},
+		{
+			name:             "case 3: legacy certificates are not issued by the CA",
+			ca:               ca,
+			issuer:           newTestCert(t, "other", true, now, year, nil),
+			deniedPath:       "/v1/pki-abc12-etcd/gimmeallyourlovin",
+			expectedFindings: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body interface{}
+				switch {
+				case r.URL.Path == tc.deniedPath:
+					w.WriteHeader(http.StatusForbidden)
+					return
+				case r.URL.Path == "/v1/sys/health":
+					body = map[string]interface{}{"initialized": true, "sealed": false}
+				case strings.HasSuffix(r.URL.Path, "/gimmeallyourlovin"):
+					body = map[string]interface{}{
+						"data": map[string]interface{}{
+							"certificate": string(tc.ca.certPEM),
+							"private_key": string(tc.ca.keyPEM),
+						},
+					}
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			vaultClient, err := vaultclient.NewClient(&vaultclient.Config{Address: server.URL})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+
+			var objs []runtime.Object
+			for _, name := range []string{"abc12-api", "abc12-etcd", "abc12-service-account"} {
+				cert := newTestCert(t, name, false, now, year, tc.issuer)
+				objs = append(objs, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels:    map[string]string{legacyCertificateLabel: strings.TrimPrefix(name, "abc12-")},
+					},
+					Data: map[string][]byte{"crt": cert.certPEM, "key": cert.keyPEM},
+				})
+			}
+			c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "org-acme"},
+			}
+
+			f := &validationFindings{}
+			newCertsMigrator(c, vaultClient).validate(ctx, f, cluster)
+
+			if len(f.findings) != tc.expectedFindings {
+				t.Fatalf("expected %d findings, got %#v", tc.expectedFindings, f.findings)
+			}
+			for _, finding := range f.findings {
+				if finding.Check != checkCertificates {
+					t.Fatalf("expected %q finding, got %#v", checkCertificates, finding)
+				}
+			}
+		})
+	}
+}
+
+func Test_certsMigrator_migrate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	year := 365 * 24 * time.Hour
+
+	ca := newTestCert(t, "abc12", true, now, year, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/gimmeallyourlovin") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": string(ca.certPEM),
+				"private_key": string(ca.keyPEM),
+			},
+		})
+	}))
+	defer server.Close()
+
+	vaultClient, err := vaultclient.NewClient(&vaultclient.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	legacyData := map[string]map[string][]byte{}
+	var objs []runtime.Object
+	for _, name := range []string{"abc12-api", "abc12-etcd", "abc12-service-account"} {
+		cert := newTestCert(t, name, false, now, year, ca)
+		legacyData[name] = map[string][]byte{"ca": ca.certPEM, "crt": cert.certPEM, "key": cert.keyPEM}
+		objs = append(objs, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{legacyCertificateLabel: strings.TrimPrefix(name, "abc12-")},
+			},
+			Data: legacyData[name],
+		})
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default", UID: "cluster-uid"},
+	}
+
+	m := newCertsMigrator(c, vaultClient)
+
+	// Migration is retried, so it has to work on top of its own result.
+	for i := 0; i < 2; i++ {
+		err = m.migrate(ctx, cluster)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	for name, data := range legacyData {
+		secret := &corev1.Secret{}
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: name}, secret)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+		for k, v := range data {
+			if !bytes.Equal(secret.Data[k], v) {
+				t.Fatalf("expected key %q of legacy secret %q to be kept", k, name)
+			}
+		}
+		if secret.Labels[legacyCertificateLabel] == "" || len(secret.OwnerReferences) != 0 {
+			t.Fatalf("expected metadata of legacy secret %q to be kept, got %#v", name, secret.ObjectMeta)
+		}
+	}
+
+	etcd := &corev1.Secret{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-etcd"}, etcd)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if !bytes.Equal(etcd.Data["tls.crt"], ca.certPEM) || !bytes.Equal(etcd.Data["tls.key"], ca.keyPEM) {
+		t.Fatalf("expected etcd CA in legacy etcd secret, got %#v", etcd.Data)
+	}
+
+	for _, name := range []string{"abc12-ca", "abc12-sa", "abc12-proxy"} {
+		secret := &corev1.Secret{}
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: name}, secret)
+		if err != nil {
+			t.Fatalf("expected CAPI secret %q, got %#v", name, err)
+		}
+	}
+}
+
+func Test_certsMigrator_rollback(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "abc12-etcd",
+				Namespace: "default",
+				Labels:    map[string]string{legacyCertificateLabel: "etcd"},
+			},
+			Data: map[string][]byte{
+				"crt":     []byte("legacy-crt"),
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12-ca", Namespace: "default"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12-etcd-legacy-client", Namespace: "default"},
+		},
+	)
+
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default"},
+	}
+
+	err := newCertsMigrator(c, nil).rollback(ctx, cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	secrets := &corev1.SecretList{}
+	err = c.List(ctx, secrets)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(secrets.Items) != 1 || secrets.Items[0].Name != "abc12-etcd" {
+		t.Fatalf("expected only legacy etcd secret to be left, got %#v", secrets.Items)
+	}
+
+	data := secrets.Items[0].Data
+	if len(data) != 1 || string(data["crt"]) != "legacy-crt" {
+		t.Fatalf("expected only keys of cert-operator to be left, got %#v", data)
+	}
+}
+
+func Test_getEtcdCABundle(t *testing.T) {
+	now := time.Now()
+	year := 365 * 24 * time.Hour
+
+	ca := newTestCert(t, "abc12", true, now, year, nil)
+	etcdCA := newTestCert(t, "abc12-etcd", true, now, year, nil)
+	otherCA := newTestCert(t, "other", true, now, year, nil)
+
+	// Vault serves the etcd PKI of the cluster only.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki-abc12-etcd/gimmeallyourlovin" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body := map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": string(etcdCA.certPEM),
+				"private_key": string(etcdCA.keyPEM),
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	vaultClient, err := vaultclient.NewClient(&vaultclient.Config{Address: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	clusterCA, err := parseCABundle(ca.certPEM, ca.keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		etcdCert     []byte
+		expectedCA   []byte
+		errorMatcher func(error) bool
+	}{
+		{
+			name:       "case 0: etcd certificate issued by cluster CA",
+			etcdCert:   newTestCert(t, "etcd.abc12", false, now, year, ca).certPEM,
+			expectedCA: ca.certPEM,
+		},
+		{
+			name:       "case 1: etcd certificate issued by separate etcd CA",
+			etcdCert:   newTestCert(t, "etcd.abc12", false, now, year, etcdCA).certPEM,
+			expectedCA: etcdCA.certPEM,
+		},
+		{
+			name:         "case 2: etcd certificate issued by unknown CA",
+			etcdCert:     newTestCert(t, "etcd.abc12", false, now, year, otherCA).certPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := getEtcdCABundle(vaultClient, clusterCA, "abc12", tc.etcdCert, now)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if tc.errorMatcher == nil && string(b.certPEM) != string(tc.expectedCA) {
+				t.Fatalf("expected CA %q, got %q", tc.expectedCA, b.certPEM)
+			}
+		})
+	}
+}
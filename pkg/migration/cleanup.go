@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	labelNodeRoleMaster = "node-role.kubernetes.io/master"
+)
+
+// ensureNewMasterIsReady checks that the workload cluster has exactly one
+// master node created by upstream controllers and that it is ready. Legacy
+// master nodes are recognized by the "role=master" label. An error matching
+// IsWaiting is returned while the new master is not ready. legacy describes
+// the legacy resources waiting for deletion in the emitted event.
+func ensureNewMasterIsReady(ctx context.Context, c ctrl.Client, events *migrationEvents, legacy string) error {
+	nodes := corev1.NodeList{}
+	err := c.List(ctx, &nodes, ctrl.HasLabels{labelNodeRoleMaster})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Filter out nodes having label "role=master".
+	var newMasters []corev1.Node
+	for _, n := range nodes.Items {
+		if n.Labels["role"] == "master" {
+			// Legacy master node.
+		} else {
+			newMasters = append(newMasters, n)
+		}
+	}
+
+	if len(newMasters) == 0 {
+		events.normalf(EventReasonWaitingForControlPlane, "waiting for new master node before deleting %s", legacy)
+		return microerror.Maskf(newMasterNotReadyError, "New master node was not found")
+	}
+
+	if len(newMasters) > 1 {
+		return microerror.Maskf(tooManyMastersError, "Exactly one master node was expected to exist, %d found", len(newMasters))
+	}
+
+	if !isNodeReady(newMasters[0]) {
+		events.normalf(EventReasonWaitingForControlPlane, "waiting for master node %s to become ready", newMasters[0].Name)
+		return microerror.Maskf(newMasterNotReadyError, "Master node %q is not ready", newMasters[0].Name)
+	}
+
+	return nil
+}
+
+// ensureCAPIWorkersAreReady checks that at least oldWorkersCount worker
+// nodes created by upstream controllers are ready. isLegacyWorker recognizes
+// worker nodes of legacy operators, which differ between providers. An error
+// matching IsWaiting is returned while there are not enough ready workers.
+func ensureCAPIWorkersAreReady(ctx context.Context, logger micrologger.Logger, c ctrl.Client, events *migrationEvents, oldWorkersCount int, isLegacyWorker func(corev1.Node) bool) error {
+	workers := corev1.NodeList{}
+	err := c.List(ctx, &workers)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var readyCAPIWorkers int
+	for _, node := range workers.Items {
+		if _, ok := node.Labels[labelNodeRoleMaster]; ok {
+			// Master node, ignore.
+			continue
+		}
+		if isLegacyWorker(node) {
+			// GS worker, ignore.
+			continue
+		}
+		if isNodeReady(node) {
+			readyCAPIWorkers++
+		}
+	}
+
+	if readyCAPIWorkers < oldWorkersCount {
+		events.normalf(EventReasonWaitingForWorkers, "waiting for %d CAPI workers, %d ready", oldWorkersCount, readyCAPIWorkers)
+		return microerror.Maskf(newWorkersNotReady, "Expected at least %d CAPI workers to be ready, %d found", oldWorkersCount, readyCAPIWorkers)
+	}
+
+	logger.Debugf(ctx, "found %d CAPI nodes ready (at least %d wanted)", readyCAPIWorkers, oldWorkersCount)
+
+	return nil
+}
+
+func isNodeReady(node corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// isLegacyWorker recognizes worker nodes of Azure and KVM legacy operators
+// by the "role=worker" label.
+func isLegacyWorker(node corev1.Node) bool {
+	return node.Labels["role"] == "worker"
+}
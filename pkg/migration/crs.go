@@ -0,0 +1,268 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+	"github.com/giantswarm/capi-migration/pkg/migration/templates"
+)
+
+const (
+	joinEtcdClusterScriptKey = "join-etcd-cluster"
+	encryptionKeyKey         = "encryption"
+	kubeProxyConfigKey       = "kubeproxy-config"
+)
+
+// kubernetesVersion returns release component version in the "v1.2.3" form
+// expected by upstream CRs.
+func kubernetesVersion(v string) string {
+	return fmt.Sprintf("v%s", strings.TrimPrefix(v, "v"))
+}
+
+// createEncryptionConfigSecret renders the apiserver EncryptionConfiguration
+// from the legacy encryption key secret, so that new masters can read
+// secrets written by legacy masters. The secret is created in the namespace
+// of the KubeadmControlPlane referencing it.
+func createEncryptionConfigSecret(ctx context.Context, c ctrl.Client, clusterID string, namespace string, encryptionSecret *corev1.Secret) error {
+	encryptionConfigTmpl := `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - aescbc:
+        keys:
+        - name: key1
+          secret: %s
+    - identity: {}`
+
+	renderedConfig := fmt.Sprintf(encryptionConfigTmpl, encryptionSecret.Data["encryption"])
+
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.EncryptionConfigSecretName(clusterID),
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			encryptionKeyKey: renderedConfig,
+		},
+	}
+
+	err := c.Create(ctx, s)
+	if apierrors.IsAlreadyExists(err) {
+		// It's fine. No worries.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// createCustomFilesSecret stores the script joining the first new master to
+// the legacy etcd cluster together with kube-proxy configuration.
+func createCustomFilesSecret(ctx context.Context, c ctrl.Client, clusterID string, namespace string, params templates.CustomFilesParams) error {
+	joinEtcdClusterContent, err := templates.RenderTemplate(templates.JoinEtcdCluster, params)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.CustomFilesSecretName(clusterID),
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			joinEtcdClusterScriptKey: joinEtcdClusterContent,
+			kubeProxyConfigKey:       templates.KubeProxyConfig,
+		},
+	}
+	err = c.Create(ctx, s)
+	if apierrors.IsAlreadyExists(err) {
+		// It's fine. No worries.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// controlPlaneMigrationFiles returns files written on new masters before
+// kubeadm runs. They contain the etcd join script, the encryption config and
+// the legacy certificates new masters share with the legacy etcd cluster.
+func controlPlaneMigrationFiles(clusterID string) []bootstrap.File {
+	return []bootstrap.File{
+		{
+			Path:  "/migration/join-existing-cluster.sh",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.CustomFilesSecretName(clusterID),
+					Key:  joinEtcdClusterScriptKey,
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/encryption/k8s-encryption-config.yaml",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.EncryptionConfigSecretName(clusterID),
+					Key:  encryptionKeyKey,
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/config/proxy-config.yml",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.CustomFilesSecretName(clusterID),
+					Key:  kubeProxyConfigKey,
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/ca.crt",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.CACertsSecretName(clusterID),
+					Key:  "tls.crt",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/ca.key",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.CACertsSecretName(clusterID),
+					Key:  "tls.key",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/etcd/ca.key",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.EtcdCertsSecretName(clusterID),
+					Key:  "tls.key",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/etcd/ca.crt",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.EtcdCertsSecretName(clusterID),
+					Key:  "tls.crt",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/sa.pub",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.SAKeysSecretName(clusterID),
+					Key:  "tls.crt",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/sa.key",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.SAKeysSecretName(clusterID),
+					Key:  "tls.key",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/etcd/old.key",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.EtcdLegacyClientSecretName(clusterID),
+					Key:  "tls.key",
+				},
+			},
+		},
+		{
+			Path:  "/etc/kubernetes/pki/etcd/old.crt",
+			Owner: "root:root",
+			ContentFrom: &bootstrap.FileSource{
+				Secret: bootstrap.SecretFileSource{
+					Name: key.EtcdLegacyClientSecretName(clusterID),
+					Key:  "tls.crt",
+				},
+			},
+		},
+	}
+}
+
+// handOverToUpstream labels given CR created in prepareMissingCRs with
+// watch-filter and release version labels. The CR is re-read before the
+// update, because it may have existed already when it was created.
+func handOverToUpstream(ctx context.Context, c ctrl.Client, obj runtime.Object, watchFilter string, releaseName string) error {
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[watchFilterLabel] = watchFilter
+	labels[label.ReleaseVersion] = releaseName
+	accessor.SetLabels(labels)
+
+	err = c.Update(ctx, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// readObjects reads given objects by their name and namespace, which is all
+// that has to be set in them.
+func readObjects(ctx context.Context, c ctrl.Client, objs ...runtime.Object) error {
+	for _, obj := range objs {
+		accessor, err := apimeta.Accessor(obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// watchFilterLabel is set on CRs handed over to upstream controllers.
+	// Upstream controllers only reconcile CRs with label value matching
+	// their version.
+	watchFilterLabel = "cluster.x-k8s.io/watch-filter"
+)
+
+// migrationState is the observed state of a cluster migration.
+type migrationState struct {
+	// Triggered is true when reconciliation of the Cluster CR has been
+	// handed over to upstream controllers.
+	Triggered bool
+	// ControlPlaneReady is true when at least one Machine owned by
+	// KubeadmControlPlane is Ready.
+	ControlPlaneReady bool
+	// LegacyMasters is the number of nodes in the workload cluster still
+	// labelled as legacy masters.
+	LegacyMasters int
+}
+
+// IsMigrated returns true when the new control plane is up. When the legacy
+// masters are already gone the watch-filter label doesn't matter anymore.
+func (s migrationState) IsMigrated() bool {
+	return s.ControlPlaneReady && (s.Triggered || s.LegacyMasters == 0)
+}
+
+// IsMigrating returns true when the migration has been triggered, but the
+// new control plane is not up yet.
+func (s migrationState) IsMigrating() bool {
+	return s.Triggered && !s.ControlPlaneReady
+}
+
+// observeMigrationState reads CRs in the management cluster and nodes in the
+// workload cluster to find out how far the migration of given cluster got.
+func observeMigrationState(ctx context.Context, mcCtrlClient ctrl.Client, wcCtrlClient ctrl.Client, clusterKey ctrl.ObjectKey) (migrationState, error) {
+	var state migrationState
+
+	{
+		cluster := &capi.Cluster{}
+		err := mcCtrlClient.Get(ctx, clusterKey, cluster)
+		if err != nil {
+			return migrationState{}, microerror.Mask(err)
+		}
+
+		_, state.Triggered = cluster.Labels[watchFilterLabel]
+	}
+
+	{
+		machines := &capi.MachineList{}
+		err := mcCtrlClient.List(ctx, machines,
+			ctrl.InNamespace(clusterKey.Namespace),
+			ctrl.MatchingLabels{capi.ClusterLabelName: clusterKey.Name},
+			ctrl.HasLabels{capi.MachineControlPlaneLabelName},
+		)
+		if err != nil {
+			return migrationState{}, microerror.Mask(err)
+		}
+
+		for i := range machines.Items {
+			machine := &machines.Items[i]
+			if !isOwnedByKind(machine.OwnerReferences, "KubeadmControlPlane") {
+				continue
+			}
+			if machine.Status.NodeRef == nil {
+				continue
+			}
+			if conditions.IsTrue(machine, capi.ReadyCondition) {
+				state.ControlPlaneReady = true
+				break
+			}
+		}
+	}
+
+	{
+		nodes := &corev1.NodeList{}
+		err := wcCtrlClient.List(ctx, nodes, ctrl.MatchingLabels{"role": "master"})
+		if err != nil {
+			return migrationState{}, microerror.Mask(err)
+		}
+
+		state.LegacyMasters = len(nodes.Items)
+	}
+
+	return state, nil
+}
+
+func isOwnedByKind(refs []metav1.OwnerReference, kind string) bool {
+	for _, r := range refs {
+		if r.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
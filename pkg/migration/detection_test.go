@@ -0,0 +1,189 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_observeMigrationState(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mcObjects   []runtime.Object
+		wcObjects   []runtime.Object
+		expected    migrationState
+		isMigrated  bool
+		isMigrating bool
+	}{
+		{
+			name: "case 0: not triggered, legacy master running",
+			mcObjects: []runtime.Object{
+				newTestCluster(false),
+			},
+			wcObjects: []runtime.Object{
+				newTestNode("master-0", map[string]string{"role": "master"}),
+			},
+			expected: migrationState{
+				LegacyMasters: 1,
+			},
+		},
+		{
+			name: "case 1: triggered, control plane machine not ready",
+			mcObjects: []runtime.Object{
+				newTestCluster(true),
+				newTestControlPlaneMachine("kcp-0", "KubeadmControlPlane", true, corev1.ConditionFalse),
+			},
+			wcObjects: []runtime.Object{
+				newTestNode("master-0", map[string]string{"role": "master"}),
+			},
+			expected: migrationState{
+				Triggered:     true,
+				LegacyMasters: 1,
+			},
+			isMigrating: true,
+		},
+		{
+			name: "case 2: triggered, control plane machine without node",
+			mcObjects: []runtime.Object{
+				newTestCluster(true),
+				newTestControlPlaneMachine("kcp-0", "KubeadmControlPlane", false, corev1.ConditionTrue),
+			},
+			expected: migrationState{
+				Triggered: true,
+			},
+			isMigrating: true,
+		},
+		{
+			name: "case 3: triggered, control plane machine ready, legacy master still running",
+			mcObjects: []runtime.Object{
+				newTestCluster(true),
+				newTestControlPlaneMachine("kcp-0", "KubeadmControlPlane", true, corev1.ConditionTrue),
+			},
+			wcObjects: []runtime.Object{
+				newTestNode("master-0", map[string]string{"role": "master"}),
+				newTestNode("kcp-0", map[string]string{"node-role.kubernetes.io/master": ""}),
+			},
+			expected: migrationState{
+				Triggered:         true,
+				ControlPlaneReady: true,
+				LegacyMasters:     1,
+			},
+			isMigrated: true,
+		},
+		{
+			name: "case 4: ready machine not owned by KubeadmControlPlane is ignored",
+			mcObjects: []runtime.Object{
+				newTestCluster(true),
+				newTestControlPlaneMachine("other-0", "MachineSet", true, corev1.ConditionTrue),
+			},
+			expected: migrationState{
+				Triggered: true,
+			},
+			isMigrating: true,
+		},
+		{
+			name: "case 5: watch-filter label dropped after legacy masters are gone",
+			mcObjects: []runtime.Object{
+				newTestCluster(false),
+				newTestControlPlaneMachine("kcp-0", "KubeadmControlPlane", true, corev1.ConditionTrue),
+			},
+			expected: migrationState{
+				ControlPlaneReady: true,
+			},
+			isMigrated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = capi.AddToScheme(scheme)
+
+			mcCtrlClient := fake.NewFakeClientWithScheme(scheme, tc.mcObjects...)
+			wcCtrlClient := fake.NewFakeClientWithScheme(scheme, tc.wcObjects...)
+
+			state, err := observeMigrationState(context.Background(), mcCtrlClient, wcCtrlClient, ctrl.ObjectKey{Namespace: "default", Name: "abc12"})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if state != tc.expected {
+				t.Fatalf("expected state %#v, got %#v", tc.expected, state)
+			}
+			if state.IsMigrated() != tc.isMigrated {
+				t.Fatalf("expected IsMigrated() %t, got %t", tc.isMigrated, state.IsMigrated())
+			}
+			if state.IsMigrating() != tc.isMigrating {
+				t.Fatalf("expected IsMigrating() %t, got %t", tc.isMigrating, state.IsMigrating())
+			}
+		})
+	}
+}
+
+func newTestCluster(triggered bool) *capi.Cluster {
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc12",
+			Namespace: "default",
+			Labels: map[string]string{
+				capi.ClusterLabelName: "abc12",
+			},
+		},
+	}
+
+	if triggered {
+		cluster.Labels[watchFilterLabel] = "0.3.13"
+	}
+
+	return cluster
+}
+
+func newTestControlPlaneMachine(name string, ownerKind string, hasNode bool, ready corev1.ConditionStatus) *capi.Machine {
+	machine := &capi.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				capi.ClusterLabelName:             "abc12",
+				capi.MachineControlPlaneLabelName: "",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Kind: ownerKind,
+					Name: "abc12-control-plane",
+				},
+			},
+		},
+		Status: capi.MachineStatus{
+			Conditions: capi.Conditions{
+				{
+					Type:   capi.ReadyCondition,
+					Status: ready,
+				},
+			},
+		},
+	}
+
+	if hasNode {
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+	}
+
+	return machine
+}
+
+func newTestNode(name string, labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
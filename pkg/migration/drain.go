@@ -0,0 +1,89 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// drainNode cordons the node and evicts its pods, so that workloads are
+// rescheduled on new nodes before the machine is terminated. Evictions
+// respect PodDisruptionBudgets, pods whose eviction is refused are retried
+// with the next call. It returns the number of pods still running on the
+// node. DaemonSet pods and static pods are not evicted.
+func drainNode(ctx context.Context, k8sClient kubernetes.Interface, node *corev1.Node) (int, error) {
+	if !node.Spec.Unschedulable {
+		node.Spec.Unschedulable = true
+
+		_, err := k8sClient.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		if err != nil {
+			return 0, microerror.Mask(err)
+		}
+	}
+
+	pods, err := k8sClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node.Name).String(),
+	})
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	var podsLeft int
+	for _, pod := range pods.Items {
+		if !isEvictable(pod) {
+			continue
+		}
+
+		podsLeft++
+
+		if pod.DeletionTimestamp != nil {
+			// Pod is already terminating.
+			continue
+		}
+
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+		}
+		err = k8sClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		if apierrors.IsNotFound(err) {
+			podsLeft--
+			continue
+		} else if apierrors.IsTooManyRequests(err) {
+			// Eviction is blocked by a PodDisruptionBudget.
+			continue
+		} else if err != nil {
+			return 0, microerror.Mask(err)
+		}
+	}
+
+	return podsLeft, nil
+}
+
+// isEvictable tells whether the pod has to be evicted before the node is
+// removed. Finished pods, static pods and DaemonSet pods are not evicted.
+func isEvictable(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,427 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/k8sclient/v4/pkg/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
+	vaultclient "github.com/hashicorp/vault/api"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+// migratorConfig is the configuration shared by migrator factories of all
+// providers.
+type migratorConfig struct {
+	CtrlClient    ctrl.Client
+	EventRecorder record.EventRecorder
+	Logger        micrologger.Logger
+	Scheme        *runtime.Scheme
+	TenantCluster tenantcluster.Interface
+	VaultClient   *vaultclient.Client
+
+	DryRun             bool
+	SSH                SSHConfig
+	SSHConfigMap       ctrl.ObjectKey
+	TemplatesConfigMap ctrl.ObjectKey
+}
+
+// validate checks the shared configuration. Errors name fields of the
+// provider configuration cfg, which config is copied from.
+func (config migratorConfig) validate(cfg interface{}) error {
+	if config.Scheme == nil {
+		return microerror.Maskf(invalidConfigError, "%T.Scheme must not be empty", cfg)
+	}
+	if config.EventRecorder == nil {
+		return microerror.Maskf(invalidConfigError, "%T.EventRecorder must not be empty", cfg)
+	}
+	if config.VaultClient == nil {
+		return microerror.Maskf(invalidConfigError, "%T.VaultClient must not be empty", cfg)
+	}
+
+	err := config.SSH.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// migratorBase holds clients and migration state shared by migrators of all
+// providers. Provider migrators embed it.
+type migratorBase struct {
+	clusterID        string
+	clusterNamespace string
+
+	logger       micrologger.Logger
+	mcCtrlClient ctrl.Client
+	wcCtrlClient ctrl.Client
+	wcK8sClient  kubernetes.Interface
+	status       *migrationStatus
+	backup       *migrationBackup
+	events       *migrationEvents
+	dryRunPlan   *dryRunPlan
+	ssh          SSHConfig
+	templates    *templateRenderer
+	certs        *certsMigrator
+}
+
+// newMigratorBase creates workload cluster clients of the given cluster and
+// wraps management and workload cluster clients, so that all writes are
+// planned in dry runs, labelled as migration artifacts and reported as
+// events.
+func newMigratorBase(config migratorConfig, cluster *capi.Cluster) (migratorBase, error) {
+	ctx := context.Background()
+
+	url := fmt.Sprintf("%s:%d", cluster.Spec.ControlPlaneEndpoint.Host, cluster.Spec.ControlPlaneEndpoint.Port)
+	restConfig, err := config.TenantCluster.NewRestConfig(ctx, cluster.Name, url)
+	if err != nil {
+		return migratorBase{}, microerror.Mask(err)
+	}
+
+	k8sClient, err := k8sclient.NewClients(k8sclient.ClientsConfig{
+		Logger:     config.Logger,
+		RestConfig: rest.CopyConfig(restConfig),
+	})
+	if err != nil {
+		return migratorBase{}, microerror.Mask(err)
+	}
+
+	ssh, err := loadSSHConfig(ctx, config.CtrlClient, config.SSHConfigMap, config.SSH)
+	if err != nil {
+		return migratorBase{}, microerror.Mask(err)
+	}
+
+	templates, err := loadTemplateRenderer(ctx, config.CtrlClient, config.TemplatesConfigMap)
+	if err != nil {
+		return migratorBase{}, microerror.Mask(err)
+	}
+
+	mcCtrlClient := config.CtrlClient
+	wcCtrlClient := k8sClient.CtrlClient()
+
+	var plan *dryRunPlan
+	if config.DryRun || meta.Annotation.DryRun.IsSet(cluster) {
+		plan = newDryRunPlan(config.Logger, config.CtrlClient, config.Scheme)
+		mcCtrlClient = plan.client(dryRunClusterManagement, mcCtrlClient)
+		wcCtrlClient = plan.client(dryRunClusterWorkload, wcCtrlClient)
+	}
+
+	mcCtrlClient = newArtifactClient(mcCtrlClient, cluster.Name)
+	wcCtrlClient = newArtifactClient(wcCtrlClient, cluster.Name)
+
+	events := newMigrationEvents(config.EventRecorder, cluster, plan != nil)
+	mcCtrlClient = newEventClient(mcCtrlClient, events)
+
+	status := newMigrationStatus(mcCtrlClient, cluster, plan != nil)
+
+	b := migratorBase{
+		clusterID:        cluster.Name,
+		clusterNamespace: cluster.Namespace,
+
+		logger:       config.Logger,
+		mcCtrlClient: mcCtrlClient,
+		wcCtrlClient: wcCtrlClient,
+		wcK8sClient:  k8sClient.K8sClient(),
+		status:       status,
+		backup:       newMigrationBackup(mcCtrlClient, config.Scheme, status, cluster),
+		events:       events,
+		dryRunPlan:   plan,
+		ssh:          ssh,
+		templates:    templates,
+		certs:        newCertsMigrator(mcCtrlClient, config.VaultClient),
+	}
+
+	return b, nil
+}
+
+// prepareStep is a provider specific step of Prepare. Its duration is
+// recorded under its name.
+type prepareStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// providerMigrator implements provider specific steps of the migration
+// lifecycle run by migrationDriver.
+type providerMigrator interface {
+	// cluster returns the Cluster CR read by readCRs.
+	cluster() *capi.Cluster
+	// validate reads the Cluster and runs validateMigration together with
+	// provider specific pre-flight checks.
+	validate(ctx context.Context) *validationFindings
+	// readCRs reads existing CRs involved in the migration.
+	readCRs(ctx context.Context) error
+	// readCreatedCRs reads CRs created by prepareSteps.
+	readCreatedCRs(ctx context.Context) error
+	// backupCRs stores CRs mutated by prepareSteps in the backup.
+	backupCRs(ctx context.Context) error
+	// prepareSteps returns steps run after CRs are read and backed up and
+	// certificates are migrated.
+	prepareSteps() []prepareStep
+	// triggerMigration hands CRs over to upstream controllers.
+	triggerMigration(ctx context.Context) error
+	// cleanup removes legacy resources of a migrated cluster.
+	cleanup(ctx context.Context) error
+	// rollback restores CRs from the backup and deletes created CRs.
+	rollback(ctx context.Context) error
+}
+
+// migrationDriver implements Migrator. It runs the migration lifecycle shared
+// by all providers, tracks its phases and calls the provider migrator for
+// provider specific steps.
+type migrationDriver struct {
+	*migratorBase
+
+	provider string
+	migrator providerMigrator
+
+	// prepared is set once Prepare has read and created all CRs.
+	prepared bool
+}
+
+func newMigrationDriver(provider string, base *migratorBase, migrator providerMigrator) *migrationDriver {
+	return &migrationDriver{
+		migratorBase: base,
+
+		provider: provider,
+		migrator: migrator,
+	}
+}
+
+func (d *migrationDriver) IsMigrated(ctx context.Context) (bool, error) {
+	state, err := observeMigrationState(ctx, d.mcCtrlClient, d.wcCtrlClient, ctrl.ObjectKey{Namespace: d.clusterNamespace, Name: d.clusterID})
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return state.IsMigrated(), nil
+}
+
+func (d *migrationDriver) IsMigrating(ctx context.Context) (bool, error) {
+	state, err := observeMigrationState(ctx, d.mcCtrlClient, d.wcCtrlClient, ctrl.ObjectKey{Namespace: d.clusterNamespace, Name: d.clusterID})
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	return state.IsMigrating(), nil
+}
+
+func (d *migrationDriver) Validate(ctx context.Context) error {
+	err := d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseValidating)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	findings := d.migrator.validate(ctx)
+	err = findings.record(ctx, d.status)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d.events.normalf(EventReasonMigrationValidated, "pre-flight checks passed with %d warnings", len(findings.findings))
+
+	return nil
+}
+
+func (d *migrationDriver) Prepare(ctx context.Context) error {
+	err := d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhasePreparing)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.runPrepareStep(ctx, "readCRs", d.migrator.readCRs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.migrator.backupCRs(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.certs.migrate(ctx, d.migrator.cluster())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, step := range d.migrator.prepareSteps() {
+		err = d.runPrepareStep(ctx, step.name, step.run)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	d.prepared = true
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhasePrepared)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d.events.normalf(EventReasonMigrationPrepared, "migration prepared, upstream CRs created")
+
+	return nil
+}
+
+// runPrepareStep runs the given Prepare step. Durations are only recorded
+// for real migrations, so dry runs don't skew them.
+func (d *migrationDriver) runPrepareStep(ctx context.Context, name string, f func(context.Context) error) error {
+	if d.dryRunPlan != nil {
+		return f(ctx)
+	}
+
+	return observePrepareStep(ctx, d.clusterID, d.provider, name, f)
+}
+
+func (d *migrationDriver) TriggerMigration(ctx context.Context) error {
+	// CRs are cached by Prepare. Migrators which only trigger the migration,
+	// e.g. in the CLI, have to read them first.
+	if !d.prepared {
+		err := d.migrator.readCRs(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = d.migrator.readCreatedCRs(ctx)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	err := d.migrator.triggerMigration(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseTriggered)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d.events.normalf(EventReasonMigrationTriggered, "migration triggered, cluster is reconciled by upstream controllers")
+
+	if d.dryRunPlan != nil {
+		err = d.dryRunPlan.emit(ctx, ctrl.ObjectKey{Namespace: d.clusterNamespace, Name: d.clusterID})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (d *migrationDriver) DeleteWorkloadArtifacts(ctx context.Context) error {
+	err := deleteArtifacts(ctx, d.wcCtrlClient, workloadArtifactLists(), d.clusterID)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (d *migrationDriver) Cleanup(ctx context.Context) error {
+	migrated, err := d.IsMigrated(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if !migrated {
+		return fmt.Errorf("cluster has not migrated yet")
+	}
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseCleaningUp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.migrator.cleanup(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseCompleted)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (d *migrationDriver) Rollback(ctx context.Context) error {
+	phase, err := d.status.phase(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if phase == v1alpha1.ClusterMigrationPhaseCleaningUp || phase == v1alpha1.ClusterMigrationPhaseCompleted {
+		return microerror.Maskf(rollbackNotPossibleError, "legacy resources are removed in phase %q", phase)
+	}
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseRollingBack)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.migrator.rollback(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.certs.rollback(ctx, d.migrator.cluster())
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.removeMigrateAnnotation(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = d.status.setPhase(ctx, v1alpha1.ClusterMigrationPhaseRolledBack)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d.events.normalf(EventReasonRolledBack, "cluster rolled back to legacy operators")
+
+	return nil
+}
+
+// removeMigrateAnnotation removes the migrate annotation from the Cluster CR
+// of a rolled back cluster. Setting it again requests a new migration
+// attempt.
+func (d *migrationDriver) removeMigrateAnnotation(ctx context.Context) error {
+	cluster := &capi.Cluster{}
+	err := d.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: d.clusterNamespace, Name: d.clusterID}, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	annotations := cluster.GetAnnotations()
+	if _, ok := annotations[meta.Annotation.Migrate.Key()]; !ok {
+		return nil
+	}
+
+	delete(annotations, meta.Annotation.Migrate.Key())
+	cluster.SetAnnotations(annotations)
+
+	err = d.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	d.logger.Debugf(ctx, "removed annotation %q from Cluster %s/%s", meta.Annotation.Migrate.Key(), cluster.Namespace, cluster.Name)
+
+	return nil
+}
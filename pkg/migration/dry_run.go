@@ -0,0 +1,335 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/meta"
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+const (
+	dryRunClusterManagement = "management"
+	dryRunClusterWorkload   = "workload"
+
+	dryRunFailureKey = "failure.txt"
+	dryRunPlanKey    = "plan.yaml"
+)
+
+// dryRunPlan collects all writes migrators would do to management and
+// workload clusters. It is populated through clients returned by client().
+type dryRunPlan struct {
+	logger       micrologger.Logger
+	mcCtrlClient ctrl.Client
+	scheme       *runtime.Scheme
+
+	mu      sync.Mutex
+	entries []*dryRunEntry
+}
+
+type dryRunEntry struct {
+	cluster string
+	verb    string
+	gvk     schema.GroupVersionKind
+	key     ctrl.ObjectKey
+	obj     runtime.Object
+}
+
+func newDryRunPlan(logger micrologger.Logger, mcCtrlClient ctrl.Client, scheme *runtime.Scheme) *dryRunPlan {
+	return &dryRunPlan{
+		logger:       logger,
+		mcCtrlClient: mcCtrlClient,
+		scheme:       scheme,
+	}
+}
+
+// client wraps given client so that reads go through and writes are
+// recorded in the plan. Objects recorded earlier are served on Get so that
+// migration steps depending on previous writes behave as in a real run.
+func (p *dryRunPlan) client(cluster string, c ctrl.Client) ctrl.Client {
+	return &dryRunClient{
+		Client:  c,
+		cluster: cluster,
+		plan:    p,
+	}
+}
+
+// emit renders the plan as a multi-document YAML, logs it and stores it in
+// a ConfigMap next to the Cluster CR.
+func (p *dryRunPlan) emit(ctx context.Context, clusterKey ctrl.ObjectKey) error {
+	rendered, err := p.render()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "dry-run migration plan:\n%s", rendered)
+
+	err = storeDryRunResult(ctx, p.mcCtrlClient, clusterKey, dryRunPlanKey, rendered)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.logger.Debugf(ctx, "stored dry-run migration plan in ConfigMap %s/%s", clusterKey.Namespace, key.MigrationPlanConfigMapName(clusterKey.Name))
+
+	return nil
+}
+
+// EmitDryRunFailure stores the error which stopped the dry run of given
+// cluster under the failure.txt key of its plan ConfigMap. Dry runs must not
+// change the ClusterMigration CR, so it is not moved to the failed phase.
+func EmitDryRunFailure(ctx context.Context, c ctrl.Client, clusterKey ctrl.ObjectKey, cause error) error {
+	err := storeDryRunResult(ctx, c, clusterKey, dryRunFailureKey, []byte(microerror.Pretty(cause, false)))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// storeDryRunResult replaces the data of the plan ConfigMap of given cluster
+// with result stored under dataKey.
+func storeDryRunResult(ctx context.Context, c ctrl.Client, clusterKey ctrl.ObjectKey, dataKey string, result []byte) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.MigrationPlanConfigMapName(clusterKey.Name),
+			Namespace: clusterKey.Namespace,
+		},
+		Data: map[string]string{
+			dataKey: string(result),
+		},
+	}
+
+	err := c.Create(ctx, cm)
+	if apierrors.IsAlreadyExists(err) {
+		existing := &corev1.ConfigMap{}
+		err = c.Get(ctx, ctrl.ObjectKey{Namespace: cm.Namespace, Name: cm.Name}, existing)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		existing.Data = cm.Data
+		err = c.Update(ctx, existing)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Status writes are dropped in dry run, so the stored result is
+	// remembered in an annotation of the ClusterMigration CR. Without it the
+	// cluster would be planned again on every resync.
+	{
+		sum := sha256.Sum256(result)
+		hash := hex.EncodeToString(sum[:])
+
+		clusterMigration := &v1alpha1.ClusterMigration{}
+		err = c.Get(ctx, clusterKey, clusterMigration)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		if meta.Annotation.DryRunPlanHash.Get(clusterMigration) != hash {
+			annotations := clusterMigration.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[meta.Annotation.DryRunPlanHash.Key()] = hash
+			clusterMigration.SetAnnotations(annotations)
+
+			err = c.Update(ctx, clusterMigration)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetDryRunPlan returns the migration plan of given cluster stored by the
+// last dry run.
+func GetDryRunPlan(ctx context.Context, c ctrl.Client, clusterKey ctrl.ObjectKey) (string, error) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, ctrl.ObjectKey{Namespace: clusterKey.Namespace, Name: key.MigrationPlanConfigMapName(clusterKey.Name)}, cm)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return cm.Data[dryRunPlanKey], nil
+}
+
+func (p *dryRunPlan) render() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf := bytes.NewBuffer(nil)
+	for i, e := range p.entries {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+
+		obj := e.obj.DeepCopyObject()
+		obj.GetObjectKind().SetGroupVersionKind(e.gvk)
+		accessor, err := apimeta.Accessor(obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		accessor.SetManagedFields(nil)
+
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		fmt.Fprintf(buf, "# %s %s %s in %s cluster\n", e.verb, e.gvk.Kind, e.key, e.cluster)
+		buf.Write(b)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (p *dryRunPlan) record(cluster string, verb string, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, p.scheme)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries = append(p.entries, &dryRunEntry{
+		cluster: cluster,
+		verb:    verb,
+		gvk:     gvk,
+		key:     ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()},
+		obj:     obj.DeepCopyObject(),
+	})
+
+	return nil
+}
+
+// lookup returns the latest recorded write of the object with given kind and
+// key.
+func (p *dryRunPlan) lookup(cluster string, gvk schema.GroupVersionKind, objKey ctrl.ObjectKey) *dryRunEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.entries) - 1; i >= 0; i-- {
+		e := p.entries[i]
+		if e.cluster == cluster && e.gvk == gvk && e.key == objKey {
+			return e
+		}
+	}
+
+	return nil
+}
+
+type dryRunClient struct {
+	ctrl.Client
+
+	cluster string
+	plan    *dryRunPlan
+}
+
+func (c *dryRunClient) Get(ctx context.Context, objKey ctrl.ObjectKey, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, c.plan.scheme)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	e := c.plan.lookup(c.cluster, gvk, objKey)
+	if e == nil {
+		return c.Client.Get(ctx, objKey, obj)
+	}
+
+	if e.verb == "delete" {
+		return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, objKey.Name)
+	}
+
+	b, err := json.Marshal(e.obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = json.Unmarshal(b, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...ctrl.CreateOption) error {
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Behave like the real API and report objects which already exist.
+	existing := obj.DeepCopyObject()
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, existing)
+	if err == nil {
+		gvk, _ := apiutil.GVKForObject(obj, c.plan.scheme)
+		return apierrors.NewAlreadyExists(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, accessor.GetName())
+	} else if !apierrors.IsNotFound(err) {
+		return microerror.Mask(err)
+	}
+
+	return c.plan.record(c.cluster, "create", obj)
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...ctrl.UpdateOption) error {
+	return c.plan.record(c.cluster, "update", obj)
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch ctrl.Patch, opts ...ctrl.PatchOption) error {
+	return c.plan.record(c.cluster, "patch", obj)
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...ctrl.DeleteOption) error {
+	return c.plan.record(c.cluster, "delete", obj)
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...ctrl.DeleteAllOfOption) error {
+	return c.plan.record(c.cluster, "delete all of", obj)
+}
+
+// Status returns a writer which drops all updates. Status updates are not
+// part of the plan.
+func (c *dryRunClient) Status() ctrl.StatusWriter {
+	return dryRunStatusWriter{}
+}
+
+type dryRunStatusWriter struct{}
+
+func (dryRunStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...ctrl.UpdateOption) error {
+	return nil
+}
+
+func (dryRunStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch ctrl.Patch, opts ...ctrl.PatchOption) error {
+	return nil
+}
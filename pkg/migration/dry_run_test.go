@@ -0,0 +1,159 @@
+package migration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+func Test_dryRunPlan(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12-ca", Namespace: "default"},
+	}
+	clusterMigration := &v1alpha1.ClusterMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default"},
+	}
+	mcCtrlClient := fake.NewFakeClientWithScheme(scheme, existing, clusterMigration)
+
+	plan := newDryRunPlan(microloggertest.New(), mcCtrlClient, scheme)
+	c := plan.client(dryRunClusterManagement, mcCtrlClient)
+
+	created := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "abc12-custom-files", Namespace: "default"},
+		StringData: map[string]string{"foo": "bar"},
+	}
+	err := c.Create(ctx, created)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// Created object is served from the plan.
+	got := &corev1.Secret{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-custom-files"}, got)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if got.StringData["foo"] != "bar" {
+		t.Fatalf("expected recorded object, got %#v", got)
+	}
+
+	// Creating existing object fails as it would against real API.
+	err = c.Create(ctx, existing.DeepCopy())
+	if !apierrors.IsAlreadyExists(err) {
+		t.Fatalf("expected already exists error, got %#v", err)
+	}
+
+	// Updates are recorded, but not written.
+	updated := existing.DeepCopy()
+	updated.Labels = map[string]string{"foo": "bar"}
+	err = c.Update(ctx, updated)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	inCluster := &corev1.Secret{}
+	err = mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-ca"}, inCluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if len(inCluster.Labels) != 0 {
+		t.Fatalf("expected secret not to be updated, got labels %#v", inCluster.Labels)
+	}
+
+	err = mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-custom-files"}, &corev1.Secret{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected secret not to be created, got %#v", err)
+	}
+
+	rendered, err := plan.render()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	docs := strings.Split(string(rendered), "---\n")
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d:\n%s", len(docs), rendered)
+	}
+	if !strings.HasPrefix(docs[0], "# create Secret default/abc12-custom-files in management cluster\n") {
+		t.Fatalf("unexpected first document:\n%s", docs[0])
+	}
+	if !strings.HasPrefix(docs[1], "# update Secret default/abc12-ca in management cluster\n") {
+		t.Fatalf("unexpected second document:\n%s", docs[1])
+	}
+	if !strings.Contains(docs[1], "kind: Secret\n") {
+		t.Fatalf("expected kind to be rendered:\n%s", docs[1])
+	}
+
+	// Emitted plan is stored and remembered on the ClusterMigration CR.
+	clusterKey := ctrl.ObjectKey{Namespace: "default", Name: "abc12"}
+	err = plan.emit(ctx, clusterKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	stored, err := GetDryRunPlan(ctx, mcCtrlClient, clusterKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if stored != string(rendered) {
+		t.Fatalf("expected stored plan to match rendered plan:\n%s", stored)
+	}
+
+	err = mcCtrlClient.Get(ctx, clusterKey, clusterMigration)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if meta.Annotation.DryRunPlanHash.Get(clusterMigration) == "" {
+		t.Fatalf("expected plan hash annotation, got %#v", clusterMigration.Annotations)
+	}
+
+	// A failed dry run replaces the plan and is remembered the same way,
+	// without moving the ClusterMigration CR to the failed phase.
+	planHash := meta.Annotation.DryRunPlanHash.Get(clusterMigration)
+	err = EmitDryRunFailure(ctx, mcCtrlClient, clusterKey, microerror.Maskf(validationFailedError, "release is not supported"))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: "abc12-migration-plan"}, cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if _, ok := cm.Data[dryRunPlanKey]; ok {
+		t.Fatalf("expected plan to be replaced, got %#v", cm.Data)
+	}
+	if !strings.Contains(cm.Data[dryRunFailureKey], "release is not supported") {
+		t.Fatalf("expected failure to be stored, got %#v", cm.Data)
+	}
+
+	err = mcCtrlClient.Get(ctx, clusterKey, clusterMigration)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if h := meta.Annotation.DryRunPlanHash.Get(clusterMigration); h == "" || h == planHash {
+		t.Fatalf("expected new hash annotation, got %#v", clusterMigration.Annotations)
+	}
+	if clusterMigration.Status.Phase != "" {
+		t.Fatalf("expected phase not to change, got %q", clusterMigration.Status.Phase)
+	}
+}
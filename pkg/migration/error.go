@@ -1,14 +1,37 @@
 package migration
 
 import (
+	"strings"
+
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/giantswarm/microerror"
 )
 
+var backupNotFoundError = &microerror.Error{
+	Kind: "backupNotFoundError",
+}
+
 var identityRefNotSetError = &microerror.Error{
 	Kind: "identityRefNotSetError",
 }
 
+var invalidCertificateError = &microerror.Error{
+	Kind: "invalidCertificateError",
+}
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+var legacyASGDeletionInProgressError = &microerror.Error{
+	Kind: "legacyASGDeletionInProgressError",
+}
+
+var legacyStackDeletionInProgressError = &microerror.Error{
+	Kind: "legacyStackDeletionInProgressError",
+}
+
 var missingValueError = &microerror.Error{
 	Kind: "missingValueError",
 }
@@ -21,6 +44,14 @@ var newWorkersNotReady = &microerror.Error{
 	Kind: "newWorkersNotReady",
 }
 
+var providerNotFoundError = &microerror.Error{
+	Kind: "providerNotFoundError",
+}
+
+var rollbackNotPossibleError = &microerror.Error{
+	Kind: "rollbackNotPossibleError",
+}
+
 var subscriptionIDNotSetError = &microerror.Error{
 	Kind: "subscriptionIDNotSetError",
 }
@@ -29,6 +60,27 @@ var tooManyMastersError = &microerror.Error{
 	Kind: "tooManyMastersError",
 }
 
+var validationFailedError = &microerror.Error{
+	Kind: "validationFailedError",
+}
+
+// IsValidationFailed asserts validationFailedError.
+func IsValidationFailed(err error) bool {
+	return microerror.Cause(err) == validationFailedError
+}
+
+// IsWaiting asserts errors returned while cleanup waits for new nodes to
+// become ready or for legacy resources to be removed. They are expected
+// during a healthy migration and must only cause a requeue.
+func IsWaiting(err error) bool {
+	switch microerror.Cause(err) {
+	case legacyASGDeletionInProgressError, legacyStackDeletionInProgressError, newMasterNotReadyError, newWorkersNotReady:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsAzureNotFound detects an azure API 404 error.
 func IsAzureNotFound(err error) bool {
 	if err == nil {
@@ -48,3 +100,44 @@ func IsAzureNotFound(err error) bool {
 
 	return false
 }
+
+// IsAWSStackNotFound detects an AWS CloudFormation API error returned for
+// a stack which doesn't exist.
+func IsAWSStackNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	c := microerror.Cause(err)
+
+	{
+		aErr, ok := c.(awserr.Error)
+		if ok {
+			if aErr.Code() == "ValidationError" && strings.Contains(aErr.Message(), "does not exist") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsBackupNotFound asserts backupNotFoundError.
+func IsBackupNotFound(err error) bool {
+	return microerror.Cause(err) == backupNotFoundError
+}
+
+// IsInvalidCertificate asserts invalidCertificateError.
+func IsInvalidCertificate(err error) bool {
+	return microerror.Cause(err) == invalidCertificateError
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+// IsProviderNotFound asserts providerNotFoundError.
+func IsProviderNotFound(err error) bool {
+	return microerror.Cause(err) == providerNotFoundError
+}
@@ -0,0 +1,127 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of events emitted on the Cluster CR during migration.
+const (
+	EventReasonArtifactsDeleted        = "ArtifactsDeleted"
+	EventReasonLegacyComponentsStarted = "LegacyComponentsStarted"
+	EventReasonLegacyComponentsStopped = "LegacyComponentsStopped"
+	EventReasonLegacyResourceDeleted   = "LegacyResourceDeleted"
+	EventReasonMigrationCompleted      = "MigrationCompleted"
+	EventReasonMigrationFailed         = "MigrationFailed"
+	EventReasonMigrationPrepared       = "MigrationPrepared"
+	EventReasonMigrationScheduled      = "MigrationScheduled"
+	EventReasonMigrationStarted        = "MigrationStarted"
+	EventReasonMigrationTriggered      = "MigrationTriggered"
+	EventReasonMigrationValidated      = "MigrationValidated"
+	EventReasonObjectCreated           = "ObjectCreated"
+	EventReasonObjectDeleted           = "ObjectDeleted"
+	EventReasonRolledBack              = "RolledBack"
+	EventReasonWaitingForControlPlane  = "WaitingForControlPlane"
+	EventReasonWaitingForWorkers       = "WaitingForWorkers"
+)
+
+// migrationEvents emits events on the Cluster CR of the migrated cluster, so
+// that migration can be followed with `kubectl describe cluster`.
+type migrationEvents struct {
+	recorder record.EventRecorder
+	cluster  *capi.Cluster
+	dryRun   bool
+}
+
+func newMigrationEvents(recorder record.EventRecorder, cluster *capi.Cluster, dryRun bool) *migrationEvents {
+	return &migrationEvents{
+		recorder: recorder,
+		cluster:  cluster,
+		dryRun:   dryRun,
+	}
+}
+
+func (e *migrationEvents) normalf(reason, format string, args ...interface{}) {
+	e.eventf(corev1.EventTypeNormal, reason, format, args...)
+}
+
+func (e *migrationEvents) warningf(reason, format string, args ...interface{}) {
+	e.eventf(corev1.EventTypeWarning, reason, format, args...)
+}
+
+func (e *migrationEvents) eventf(eventType, reason, format string, args ...interface{}) {
+	if e == nil || e.recorder == nil {
+		return
+	}
+
+	if e.dryRun {
+		format = "[dry run] " + format
+	}
+
+	e.recorder.Eventf(e.cluster, eventType, reason, format, args...)
+}
+
+// eventClient emits an event for every object created or deleted through it.
+type eventClient struct {
+	ctrl.Client
+
+	events *migrationEvents
+}
+
+func newEventClient(c ctrl.Client, events *migrationEvents) ctrl.Client {
+	return &eventClient{
+		Client: c,
+		events: events,
+	}
+}
+
+func (c *eventClient) Create(ctx context.Context, obj runtime.Object, opts ...ctrl.CreateOption) error {
+	err := c.Client.Create(ctx, obj, opts...)
+	if err != nil {
+		return err
+	}
+
+	c.events.normalf(EventReasonObjectCreated, "%s %s created", objectKind(obj), objectName(obj))
+
+	return nil
+}
+
+func (c *eventClient) Delete(ctx context.Context, obj runtime.Object, opts ...ctrl.DeleteOption) error {
+	err := c.Client.Delete(ctx, obj, opts...)
+	if err != nil {
+		return err
+	}
+
+	c.events.normalf(EventReasonObjectDeleted, "%s %s deleted", objectKind(obj), objectName(obj))
+
+	return nil
+}
+
+func objectKind(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
+
+func objectName(obj runtime.Object) string {
+	m, ok := obj.(metav1.Object)
+	if !ok {
+		return ""
+	}
+
+	if m.GetNamespace() == "" {
+		return m.GetName()
+	}
+
+	return fmt.Sprintf("%s/%s", m.GetNamespace(), m.GetName())
+}
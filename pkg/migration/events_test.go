@@ -0,0 +1,91 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_eventClient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dryRun   bool
+		expected []string
+	}{
+		{
+			name:   "case 0: events for created and deleted objects",
+			dryRun: false,
+			expected: []string{
+				"Normal ObjectCreated Secret default/abc12-k8s-encryption-config created",
+				"Normal ObjectDeleted Secret default/abc12-k8s-encryption-config deleted",
+			},
+		},
+		{
+			name:   "case 1: dry run events are marked",
+			dryRun: true,
+			expected: []string{
+				"Normal ObjectCreated [dry run] Secret default/abc12-k8s-encryption-config created",
+				"Normal ObjectDeleted [dry run] Secret default/abc12-k8s-encryption-config deleted",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = capi.AddToScheme(scheme)
+
+			cluster := newTestCluster(false)
+			recorder := record.NewFakeRecorder(10)
+			c := newEventClient(fake.NewFakeClientWithScheme(scheme, cluster), newMigrationEvents(recorder, cluster, tc.dryRun))
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc12-k8s-encryption-config",
+					Namespace: "default",
+				},
+			}
+
+			err := c.Create(ctx, secret)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			err = c.Delete(ctx, secret)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			// Failed requests don't emit events.
+			err = c.Delete(ctx, secret)
+			if err == nil {
+				t.Fatalf("expected error deleting missing secret")
+			}
+
+			close(recorder.Events)
+			var events []string
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+
+			if len(events) != len(tc.expected) {
+				t.Fatalf("expected events %v, got %v", tc.expected, events)
+			}
+			for i := range events {
+				if events[i] != tc.expected[i] {
+					t.Fatalf("expected event %q, got %q", tc.expected[i], events[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package migration
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+// update rewrites golden files with the current output instead of comparing
+// it, e.g.:
+//
+//	go test ./pkg/migration -run Golden -update
+//
+var update = flag.Bool("update", false, "update golden files in testdata/golden")
+
+// newGoldenScheme returns a scheme with types of all providers, so that
+// golden tests can seed the fake client with legacy and upstream CRs.
+func newGoldenScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		v1alpha1.AddToScheme,
+		release.AddToScheme,
+		capi.AddToScheme,
+		capiexp.AddToScheme,
+		bootstrap.AddToScheme,
+		kubeadm.AddToScheme,
+		AddProvidersToScheme,
+	} {
+		err := add(scheme)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	return scheme
+}
+
+// assertGolden reads every object of objs back from c and compares it with
+// testdata/golden/<dir>/<kind>_<name>.yaml. Only name and namespace of objs
+// need to be set. With -update golden files are rewritten instead.
+func assertGolden(t *testing.T, c ctrl.Client, scheme *runtime.Scheme, dir string, objs ...runtime.Object) {
+	t.Helper()
+
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		err = c.Get(context.Background(), ctrl.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, obj)
+		if err != nil {
+			t.Fatalf("%s %s/%s: unexpected error: %#v", gvk.Kind, accessor.GetNamespace(), accessor.GetName(), err)
+		}
+
+		// The fake client doesn't always fill type meta and resource
+		// versions depend on the order of writes, so both are normalized.
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		accessor.SetResourceVersion("")
+
+		actual, err := yaml.Marshal(obj)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		path := filepath.Join("testdata", "golden", dir, fmt.Sprintf("%s_%s.yaml", strings.ToLower(gvk.Kind), accessor.GetName()))
+
+		if *update {
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+			err = os.WriteFile(path, actual, 0644) // nolint:gosec
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v (run with -update to create golden files)", err)
+		}
+
+		if string(expected) != string(actual) {
+			t.Errorf("%s differs from golden file, run with -update to accept:\n--- expected\n%s\n+++ actual\n%s", path, expected, actual)
+		}
+	}
+}
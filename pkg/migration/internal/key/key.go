@@ -10,6 +10,14 @@ func AzureNodePoolVMSSName(nodePoolID string) string {
 	return fmt.Sprintf("nodepool-%s", nodePoolID)
 }
 
+func AzureControlPlaneName(clusterID string) string {
+	return fmt.Sprintf("%s-control-plane", clusterID)
+}
+
+func AzureWorkersName(clusterID string) string {
+	return fmt.Sprintf("%s-md-0", clusterID)
+}
+
 func AWSKubeadmControlPlaneName(clusterID string) string {
 	return fmt.Sprintf("%s-control-plane", clusterID)
 }
@@ -26,17 +34,62 @@ func AWSAPIEndpointFromDomain(domain string, clusterID string) string {
 	return fmt.Sprintf("api.%s.k8s.%s", clusterID, domain)
 }
 
-func AWSCustomFilesSecretName(clusterID string) string {
+func CustomFilesSecretName(clusterID string) string {
 	return fmt.Sprintf("%s-custom-files", clusterID)
 }
+
+func ProxyConfigSecretName(clusterID string) string {
+	return fmt.Sprintf("%s-proxy-config", clusterID)
+}
+
+func AWSLegacyControlPlaneNodesStackName(clusterID string) string {
+	return fmt.Sprintf("cluster-%s-tccpn", clusterID)
+}
+
+func AWSLegacyNodePoolStackName(clusterID string, machineDeploymentID string) string {
+	return fmt.Sprintf("cluster-%s-tcnp-%s", clusterID, machineDeploymentID)
+}
+
 func AWSEtcdEndpointFromDomain(domain string, clusterID string) string {
 	return fmt.Sprintf("etcd.%s.k8s.%s", clusterID, domain)
 }
 
+func KVMControlPlaneName(clusterID string) string {
+	return fmt.Sprintf("%s-control-plane", clusterID)
+}
+
+func KVMWorkersName(clusterID string) string {
+	return fmt.Sprintf("%s-md-0", clusterID)
+}
+
+// KVMLegacyNamespace is the namespace in the management cluster holding
+// kvm-operator Deployments running legacy node VMs.
+func KVMLegacyNamespace(clusterID string) string {
+	return clusterID
+}
+
+func MigrationPlanConfigMapName(clusterID string) string {
+	return fmt.Sprintf("%s-migration-plan", clusterID)
+}
+
+func MigrationBackupSecretName(clusterID string, attempt int) string {
+	return fmt.Sprintf("%s-migration-backup-%d", clusterID, attempt)
+}
+
+func EncryptionKeySecretName(clusterID string) string {
+	return fmt.Sprintf("%s-encryption", clusterID)
+}
+
 func EncryptionConfigSecretName(clusterID string) string {
 	return fmt.Sprintf("%s-k8s-encryption-config", clusterID)
 }
 
+// APICertsSecretName is the secret of the legacy API server certificate
+// issued by cert-operator.
+func APICertsSecretName(clusterID string) string {
+	return fmt.Sprintf("%s-api", clusterID)
+}
+
 func CACertsSecretName(clusterID string) string {
 	return fmt.Sprintf("%s-ca", clusterID)
 }
@@ -45,10 +98,27 @@ func SACertsSecretName(clusterID string) string {
 	return fmt.Sprintf("%s-service-account", clusterID)
 }
 
+// SAKeysSecretName is the CAPI secret holding the service account key pair.
+func SAKeysSecretName(clusterID string) string {
+	return fmt.Sprintf("%s-sa", clusterID)
+}
+
 func EtcdCertsSecretName(clusterID string) string {
 	return fmt.Sprintf("%s-etcd", clusterID)
 }
 
+// EtcdLegacyClientSecretName is the copy of the legacy etcd certificate used
+// by new masters to join the existing etcd cluster.
+func EtcdLegacyClientSecretName(clusterID string) string {
+	return fmt.Sprintf("%s-etcd-legacy-client", clusterID)
+}
+
 func VaultPKIHackyEndpoint(clusterID string) string {
 	return fmt.Sprintf("pki-%s/gimmeallyourlovin", clusterID)
 }
+
+// VaultEtcdPKIHackyEndpoint is the counterpart of VaultPKIHackyEndpoint for
+// clusters with a separate etcd PKI.
+func VaultEtcdPKIHackyEndpoint(clusterID string) string {
+	return fmt.Sprintf("pki-%s-etcd/gimmeallyourlovin", clusterID)
+}
@@ -0,0 +1,261 @@
+package migration
+
+import (
+	"context"
+
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
+	vaultclient "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/templates"
+)
+
+type KVMMigrationConfig struct {
+	// Migration configuration + dependencies such as k8s client.
+	CtrlClient    ctrl.Client
+	EventRecorder record.EventRecorder
+	Logger        micrologger.Logger
+	Scheme        *runtime.Scheme
+	TenantCluster tenantcluster.Interface
+	// VaultClient reads CAs of workload clusters. It is shared by all
+	// migrators and its token is kept valid by the caller.
+	VaultClient *vaultclient.Client
+
+	// DryRun makes migrators only render the migration plan for all
+	// clusters. It can be enabled for a single cluster with
+	// meta.Annotation.DryRun.
+	DryRun bool
+	// SSH is the SSH access policy for machines of migrated clusters. When
+	// SSHConfigMap is set, the policy stored there is merged over SSH for
+	// every new migrator.
+	SSH          SSHConfig
+	SSHConfigMap ctrl.ObjectKey
+	// TemplatesConfigMap overrides embedded CR templates with templates
+	// stored under their file names. It is read for every new migrator.
+	TemplatesConfigMap ctrl.ObjectKey
+}
+
+type kvmMigratorFactory struct {
+	migratorConfig migratorConfig
+}
+
+type kvmCRs struct {
+	encryptionSecret *corev1.Secret
+	kvmConfig        *provider.KVMConfig
+	release          *release.Release
+
+	cluster                  *capi.Cluster
+	byoCluster               *unstructured.Unstructured
+	kubeadmControlPlane      *kubeadm.KubeadmControlPlane
+	masterByoMachineTemplate *unstructured.Unstructured
+
+	workersKubeadmConfigTemplate *bootstrap.KubeadmConfigTemplate
+	workersByoMachineTemplate    *unstructured.Unstructured
+	workersMachineDeployment     *capi.MachineDeployment
+}
+
+type kvmMigrator struct {
+	migratorBase
+
+	crs kvmCRs
+}
+
+func NewKVMMigratorFactory(cfg KVMMigrationConfig) (MigratorFactory, error) {
+	mc := migratorConfig{
+		CtrlClient:    cfg.CtrlClient,
+		EventRecorder: cfg.EventRecorder,
+		Logger:        cfg.Logger,
+		Scheme:        cfg.Scheme,
+		TenantCluster: cfg.TenantCluster,
+		VaultClient:   cfg.VaultClient,
+
+		DryRun:             cfg.DryRun,
+		SSH:                cfg.SSH,
+		SSHConfigMap:       cfg.SSHConfigMap,
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
+	}
+
+	err := mc.validate(cfg)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return &kvmMigratorFactory{
+		migratorConfig: mc,
+	}, nil
+}
+
+func (f *kvmMigratorFactory) NewMigrator(cluster *capi.Cluster) (Migrator, error) {
+	base, err := newMigratorBase(f.migratorConfig, cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	m := &kvmMigrator{
+		migratorBase: base,
+	}
+
+	return newMigrationDriver(ProviderKVM, &m.migratorBase, m), nil
+}
+
+func (m *kvmMigrator) cluster() *capi.Cluster {
+	return m.crs.cluster
+}
+
+func (m *kvmMigrator) prepareSteps() []prepareStep {
+	return []prepareStep{
+		{name: "prepareMissingCRs", run: m.prepareMissingCRs},
+		{name: "updateCRs", run: m.updateCRs},
+	}
+}
+
+// readCRs reads existing CRs involved in migration. For KVM this contains
+// following CRs:
+// - Cluster
+// - KVMConfig
+// - encryption key Secret
+// - Release
+func (m *kvmMigrator) readCRs(ctx context.Context) error {
+	var err error
+
+	err = m.readEncryptionSecret(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readKVMConfig(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	releaseVer := m.crs.cluster.GetLabels()[label.ReleaseVersion]
+	err = m.readRelease(ctx, releaseVer)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// prepareMissingCRs constructs missing CRs that are needed for CAPI
+// reconciliation to work. This include e.g. KubeadmControlPlane and
+// ByoMachineTemplate for new master nodes.
+func (m *kvmMigrator) prepareMissingCRs(ctx context.Context) error {
+	var err error
+
+	err = createEncryptionConfigSecret(ctx, m.mcCtrlClient, m.clusterID, m.clusterNamespace, m.crs.encryptionSecret)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = createCustomFilesSecret(ctx, m.mcCtrlClient, m.clusterID, m.clusterNamespace, templates.CustomFilesParams{
+		APIEndpoint:  m.crs.kvmConfig.Spec.Cluster.Kubernetes.API.Domain,
+		ETCDEndpoint: m.crs.kvmConfig.Spec.Cluster.Etcd.Domain,
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createByoCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createKubeadmControlPlane(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createMasterByoMachineTemplate(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createWorkersKubeadmConfigTemplate(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createWorkersByoMachineTemplate(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.createWorkersMachineDeployment(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// updateCRs updates existing CRs such as Cluster and KVMConfig with
+// configuration that is compatible with upstream controllers.
+func (m *kvmMigrator) updateCRs(ctx context.Context) error {
+	var err error
+
+	err = m.updateCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.updateKVMConfig(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// triggerMigration executes the last missing updates on CRs so that
+// reconciliation transistions to upstream controllers.
+func (m *kvmMigrator) triggerMigration(ctx context.Context) error {
+	releaseComponents := getReleaseComponents(m.crs.release)
+
+	{
+		m.crs.cluster.Labels[label.ClusterOperatorVersion] = releaseComponents["cluster-operator"]
+		m.crs.cluster.Labels[watchFilterLabel] = releaseComponents["cluster-api-core"]
+		err := m.mcCtrlClient.Update(ctx, m.crs.cluster)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	handOvers := []struct {
+		obj       runtime.Object
+		component string
+	}{
+		{obj: m.crs.byoCluster, component: kvmInfrastructureComponent},
+		{obj: m.crs.kubeadmControlPlane, component: "cluster-api-control-plane"},
+		{obj: m.crs.masterByoMachineTemplate, component: kvmInfrastructureComponent},
+		{obj: m.crs.workersKubeadmConfigTemplate, component: "cluster-api-bootstrap-provider-kubeadm"},
+		{obj: m.crs.workersByoMachineTemplate, component: kvmInfrastructureComponent},
+		{obj: m.crs.workersMachineDeployment, component: "cluster-api-core"},
+	}
+
+	for _, h := range handOvers {
+		err := handOverToUpstream(ctx, m.mcCtrlClient, h.obj, releaseComponents[h.component], m.crs.release.Name)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/giantswarm/microerror"
+	appsv1 "k8s.io/api/apps/v1"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+const (
+	// kvmLabelApp is the label kvm-operator puts on Deployments running
+	// legacy node VMs. Its value is the node role.
+	kvmLabelApp = "app"
+
+	kvmRoleMaster = "master"
+	kvmRoleWorker = "worker"
+)
+
+func (m *kvmMigrator) cleanup(ctx context.Context) error {
+	err := m.ensureLegacyMastersAreDeleted(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.ensureLegacyWorkersAreDeleted(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *kvmMigrator) ensureLegacyMastersAreDeleted(ctx context.Context) error {
+	// Ensure legacy master Deployments exist or exit.
+	deployments, err := m.getLegacyDeployments(ctx, kvmRoleMaster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observeLegacyNodeGroups(m.clusterID, ProviderKVM, metricsRoleMaster, len(deployments))
+
+	if len(deployments) == 0 {
+		m.logger.Debugf(ctx, "no legacy master Deployment found")
+		return nil
+	}
+
+	// Check if the new master exists and is ready or wait.
+	err = ensureNewMasterIsReady(ctx, m.wcCtrlClient, m.events, fmt.Sprintf("%d legacy master Deployments", len(deployments)))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for i := range deployments {
+		err = m.deleteLegacyDeployment(ctx, &deployments[i])
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (m *kvmMigrator) ensureLegacyWorkersAreDeleted(ctx context.Context) error {
+	// Ensure legacy worker Deployments exist or exit.
+	deployments, err := m.getLegacyDeployments(ctx, kvmRoleWorker)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observeLegacyNodeGroups(m.clusterID, ProviderKVM, metricsRoleNodePool, len(deployments))
+
+	if len(deployments) == 0 {
+		m.logger.Debugf(ctx, "no legacy worker Deployment found")
+		return nil
+	}
+
+	// Every legacy worker VM runs in its own Deployment.
+	oldWorkersCount := len(deployments)
+
+	// Check there are at least `oldWorkersCount` CAPI workers in a `Ready` state.
+	err = ensureCAPIWorkersAreReady(ctx, m.logger, m.wcCtrlClient, m.events, oldWorkersCount, isLegacyWorker)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "found %d legacy worker Deployments to be deleted", len(deployments))
+	for i := range deployments {
+		err = m.deleteLegacyDeployment(ctx, &deployments[i])
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// getLegacyDeployments returns kvm-operator Deployments running legacy node
+// VMs with given role.
+func (m *kvmMigrator) getLegacyDeployments(ctx context.Context, role string) ([]appsv1.Deployment, error) {
+	deployments := &appsv1.DeploymentList{}
+	err := m.mcCtrlClient.List(ctx, deployments,
+		ctrl.InNamespace(key.KVMLegacyNamespace(m.clusterID)),
+		ctrl.MatchingLabels{kvmLabelApp: role},
+	)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return deployments.Items, nil
+}
+
+func (m *kvmMigrator) deleteLegacyDeployment(ctx context.Context, d *appsv1.Deployment) error {
+	m.logger.Debugf(ctx, "deleting legacy Deployment %s/%s", d.Namespace, d.Name)
+
+	err := deleteIfExists(ctx, m.mcCtrlClient, d)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "deleted legacy Deployment %s/%s", d.Namespace, d.Name)
+	m.events.normalf(EventReasonLegacyResourceDeleted, "Deployment %s/%s deleted", d.Namespace, d.Name)
+
+	return nil
+}
@@ -0,0 +1,432 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+const (
+	// kvmInfrastructureComponent is the release component of the
+	// infrastructure provider reconciling ByoCluster and ByoMachineTemplate.
+	kvmInfrastructureComponent = "cluster-api-provider-byoh"
+
+	kindByoCluster         = "ByoCluster"
+	kindByoMachineTemplate = "ByoMachineTemplate"
+)
+
+// byoGroupVersion is the API version of the bring-your-own-host
+// infrastructure provider used for KVM clusters. Its types are not vendored,
+// so its CRs are handled as unstructured objects.
+var byoGroupVersion = schema.GroupVersion{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3"}
+
+// kvmKubeadmControlPlaneParams are parameters of
+// kubeadm_controlplane_kvm.yaml.tmpl.
+type kvmKubeadmControlPlaneParams struct {
+	Name                string
+	Namespace           string
+	MachineTemplateName string
+	APIEndpoint         string
+	EtcdPrefix          string
+	ServiceSubnet       string
+	K8sVersion          string
+	Files               []bootstrap.File
+	Users               []bootstrap.User
+}
+
+// kvmKubeadmConfigTemplateParams are parameters of
+// workers_kubeadm_config_template_kvm.yaml.tmpl.
+type kvmKubeadmConfigTemplateParams struct {
+	Name                  string
+	Namespace             string
+	CustomFilesSecretName string
+	Users                 []bootstrap.User
+}
+
+func newByoObject(kind string, namespace string, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(byoGroupVersion.WithKind(kind))
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+
+	return obj
+}
+
+func newByoList(kind string) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(byoGroupVersion.WithKind(kind + "List"))
+
+	return list
+}
+
+func (m *kvmMigrator) createByoCluster(ctx context.Context) error {
+	cfg := map[string]string{
+		"ClusterID":   m.clusterID,
+		"Namespace":   m.clusterNamespace,
+		"APIEndpoint": m.crs.kvmConfig.Spec.Cluster.Kubernetes.API.Domain,
+	}
+
+	obj := &unstructured.Unstructured{}
+	err := m.templates.render("byo_cluster_kvm.yaml.tmpl", cfg, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, obj)
+	if apierrors.IsAlreadyExists(err) {
+		// It's ok. It's already there.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.byoCluster = obj
+
+	return nil
+}
+
+func (m *kvmMigrator) createKubeadmControlPlane(ctx context.Context) error {
+	releaseComponents := getReleaseComponents(m.crs.release)
+	api := m.crs.kvmConfig.Spec.Cluster.Kubernetes.API
+
+	etcdPrefix := m.crs.kvmConfig.Spec.Cluster.Etcd.Prefix
+	if etcdPrefix == "" {
+		etcdPrefix = "giantswarm.io"
+	}
+
+	params := kvmKubeadmControlPlaneParams{
+		Name:                key.KVMControlPlaneName(m.clusterID),
+		Namespace:           m.clusterNamespace,
+		MachineTemplateName: key.KVMControlPlaneName(m.clusterID),
+		APIEndpoint:         api.Domain,
+		EtcdPrefix:          etcdPrefix,
+		ServiceSubnet:       api.ClusterIPRange,
+		K8sVersion:          kubernetesVersion(releaseComponents["kubernetes"]),
+		Files:               controlPlaneMigrationFiles(m.clusterID),
+		Users:               m.ssh.bootstrapUsers(),
+	}
+
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err := m.templates.render("kubeadm_controlplane_kvm.yaml.tmpl", params, kcp)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, kcp)
+	if apierrors.IsAlreadyExists(err) {
+		// It's ok. It's already there.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Store control plane CR for later referencing into Cluster CR.
+	m.crs.kubeadmControlPlane = kcp
+
+	return nil
+}
+
+func (m *kvmMigrator) createMasterByoMachineTemplate(ctx context.Context) error {
+	obj, err := m.createByoMachineTemplate(ctx, key.KVMControlPlaneName(m.clusterID), "master")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.masterByoMachineTemplate = obj
+
+	return nil
+}
+
+func (m *kvmMigrator) createWorkersByoMachineTemplate(ctx context.Context) error {
+	obj, err := m.createByoMachineTemplate(ctx, key.KVMWorkersName(m.clusterID), "worker")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.workersByoMachineTemplate = obj
+
+	return nil
+}
+
+// createByoMachineTemplate creates ByoMachineTemplate selecting hosts of the
+// cluster labelled with given role.
+func (m *kvmMigrator) createByoMachineTemplate(ctx context.Context, name string, role string) (*unstructured.Unstructured, error) {
+	cfg := map[string]string{
+		"ClusterID": m.clusterID,
+		"Name":      name,
+		"Namespace": m.clusterNamespace,
+		"Role":      role,
+	}
+
+	obj := &unstructured.Unstructured{}
+	err := m.templates.render("byo_machine_template_kvm.yaml.tmpl", cfg, obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, obj)
+	if apierrors.IsAlreadyExists(err) {
+		// It's ok. It's already there.
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return obj, nil
+}
+
+func (m *kvmMigrator) createWorkersKubeadmConfigTemplate(ctx context.Context) error {
+	params := kvmKubeadmConfigTemplateParams{
+		Name:                  key.KVMWorkersName(m.clusterID),
+		Namespace:             m.clusterNamespace,
+		CustomFilesSecretName: key.CustomFilesSecretName(m.clusterID),
+		Users:                 m.ssh.bootstrapUsers(),
+	}
+
+	kct := &bootstrap.KubeadmConfigTemplate{}
+	err := m.templates.render("workers_kubeadm_config_template_kvm.yaml.tmpl", params, kct)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.mcCtrlClient.Create(ctx, kct)
+	if apierrors.IsAlreadyExists(err) {
+		// It's ok. It's already there.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.workersKubeadmConfigTemplate = kct
+
+	return nil
+}
+
+func (m *kvmMigrator) createWorkersMachineDeployment(ctx context.Context) error {
+	releaseComponents := getReleaseComponents(m.crs.release)
+
+	cfg := map[string]string{
+		"ClusterID":          m.clusterID,
+		"InfrastructureKind": kindByoMachineTemplate,
+		"K8sVersion":         kubernetesVersion(releaseComponents["kubernetes"]),
+		"Namespace":          m.clusterNamespace,
+	}
+
+	md := &capi.MachineDeployment{}
+	err := m.templates.render("workers_machine_deployment.yaml.tmpl", cfg, md)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Keep the number of legacy workers.
+	replicas := int32(len(m.crs.kvmConfig.Spec.KVM.Workers))
+	md.Spec.Replicas = &replicas
+
+	err = m.mcCtrlClient.Create(ctx, md)
+	if apierrors.IsAlreadyExists(err) {
+		// It's ok. It's already there.
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.workersMachineDeployment = md
+
+	return nil
+}
+
+func (m *kvmMigrator) readEncryptionSecret(ctx context.Context) error {
+	obj := &corev1.Secret{}
+	key := ctrl.ObjectKey{Namespace: "default", Name: key.EncryptionKeySecretName(m.clusterID)}
+	err := m.mcCtrlClient.Get(ctx, key, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.encryptionSecret = obj
+
+	return nil
+}
+
+func (m *kvmMigrator) readCluster(ctx context.Context) error {
+	obj := &capi.Cluster{}
+	err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: m.clusterNamespace, Name: m.clusterID}, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.cluster = obj
+
+	return nil
+}
+
+// readKVMConfig reads the KVMConfig of the cluster. kvm-operator names
+// KVMConfig after the cluster ID and keeps it in the default namespace.
+func (m *kvmMigrator) readKVMConfig(ctx context.Context) error {
+	obj := &provider.KVMConfig{}
+	err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: m.clusterID}, obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.kvmConfig = obj
+
+	return nil
+}
+
+func (m *kvmMigrator) readRelease(ctx context.Context, ver string) error {
+	// Ensure the release name starts with a "v"
+	ver = strings.TrimPrefix(ver, "v")
+	ver = fmt.Sprintf("v%s", ver)
+	r := &release.Release{}
+	err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Name: ver}, r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.crs.release = r
+
+	return nil
+}
+
+// readCreatedCRs reads CRs created in prepareMissingCRs. Prepare caches them,
+// so this is only needed when the migration is triggered by a new migrator,
+// e.g. from the CLI.
+func (m *kvmMigrator) readCreatedCRs(ctx context.Context) error {
+	workers := metav1.ObjectMeta{
+		Name:      key.KVMWorkersName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+	controlPlane := metav1.ObjectMeta{
+		Name:      key.KVMControlPlaneName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+
+	m.crs.byoCluster = newByoObject(kindByoCluster, m.clusterNamespace, m.clusterID)
+	m.crs.kubeadmControlPlane = &kubeadm.KubeadmControlPlane{ObjectMeta: controlPlane}
+	m.crs.masterByoMachineTemplate = newByoObject(kindByoMachineTemplate, controlPlane.Namespace, controlPlane.Name)
+	m.crs.workersKubeadmConfigTemplate = &bootstrap.KubeadmConfigTemplate{ObjectMeta: workers}
+	m.crs.workersByoMachineTemplate = newByoObject(kindByoMachineTemplate, workers.Namespace, workers.Name)
+	m.crs.workersMachineDeployment = &capi.MachineDeployment{ObjectMeta: workers}
+
+	err := readObjects(ctx, m.mcCtrlClient,
+		m.crs.byoCluster,
+		m.crs.kubeadmControlPlane,
+		m.crs.masterByoMachineTemplate,
+		m.crs.workersKubeadmConfigTemplate,
+		m.crs.workersByoMachineTemplate,
+		m.crs.workersMachineDeployment,
+	)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *kvmMigrator) updateCluster(ctx context.Context) error {
+	cluster := m.crs.cluster
+	original := cluster.DeepCopy()
+
+	// Drop operator version label.
+	delete(cluster.Labels, label.KVMOperatorVersion)
+
+	// Drop finalizers of legacy operators.
+	dropLegacyFinalizers(cluster)
+
+	// Type meta of m.crs.kubeadmControlPlane is cleared when it is decoded
+	// by the API client, so it can't be used here.
+	cluster.Spec.ControlPlaneRef = &corev1.ObjectReference{
+		APIVersion: kubeadm.GroupVersion.String(),
+		Kind:       "KubeadmControlPlane",
+		Name:       m.crs.kubeadmControlPlane.Name,
+	}
+
+	// Upstream controllers don't know KVMConfig, the cluster infrastructure
+	// is reconciled through ByoCluster from now on.
+	cluster.Spec.InfrastructureRef = &corev1.ObjectReference{
+		APIVersion: byoGroupVersion.String(),
+		Kind:       kindByoCluster,
+		Name:       m.crs.byoCluster.GetName(),
+		Namespace:  m.crs.byoCluster.GetNamespace(),
+	}
+
+	mutated := cluster.DeepCopy()
+
+	err := m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.status.recordMutation(ctx, "Cluster", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// updateKVMConfig stops kvm-operator from reconciling the KVMConfig, so that
+// it doesn't recreate legacy nodes removed during cleanup.
+func (m *kvmMigrator) updateKVMConfig(ctx context.Context) error {
+	kvmConfig := m.crs.kvmConfig
+	original := kvmConfig.DeepCopy()
+
+	// Drop operator version label.
+	delete(kvmConfig.Labels, label.KVMOperatorVersion)
+
+	// Drop finalizers.
+	kvmConfig.Finalizers = nil
+
+	mutated := kvmConfig.DeepCopy()
+
+	err := m.mcCtrlClient.Update(ctx, kvmConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.status.recordMutation(ctx, "KVMConfig", original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// backupCRs stores CRs and certificate secrets mutated during migration in
+// the backup of the current migration attempt.
+func (m *kvmMigrator) backupCRs(ctx context.Context) error {
+	objs := map[string]runtime.Object{
+		backupCluster:               m.crs.cluster,
+		backupInfrastructureCluster: m.crs.kvmConfig,
+	}
+
+	for _, name := range []string{key.SACertsSecretName(m.clusterID), key.EtcdCertsSecretName(m.clusterID)} {
+		secret := &corev1.Secret{}
+		err := m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "default", Name: name}, secret)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		objs[backupSecret(name)] = secret
+	}
+
+	err := m.backup.save(ctx, objs)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package migration
+
+import (
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=byoclusters;byomachinetemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=provider.giantswarm.io,resources=kvmconfigs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;delete
+
+func init() {
+	RegisterProvider(Provider{
+		Name: ProviderKVM,
+		// Legacy clusters reference KVMConfig, migrated clusters reference
+		// ByoCluster created during migration.
+		InfrastructureKinds: []string{"KVMConfig", kindByoCluster},
+
+		AddToScheme:        addKVMToScheme,
+		NewMigratorFactory: newKVMProviderMigratorFactory,
+	})
+}
+
+func addKVMToScheme(s *runtime.Scheme) error {
+	err := provider.AddToScheme(s)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func newKVMProviderMigratorFactory(cfg ProviderConfig) (MigratorFactory, error) {
+	f, err := NewKVMMigratorFactory(KVMMigrationConfig{
+		CtrlClient:    cfg.CtrlClient,
+		EventRecorder: cfg.EventRecorder,
+		Logger:        cfg.Logger,
+		Scheme:        cfg.Scheme,
+		TenantCluster: cfg.TenantCluster,
+		VaultClient:   cfg.VaultClient,
+
+		DryRun:       cfg.DryRun,
+		SSH:          cfg.SSH,
+		SSHConfigMap: cfg.SSHConfigMap,
+
+		TemplatesConfigMap: cfg.TemplatesConfigMap,
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return f, nil
+}
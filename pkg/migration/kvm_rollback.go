@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"context"
+
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+func (m *kvmMigrator) rollback(ctx context.Context) error {
+	err := m.readCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.readKVMConfig(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreCluster(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.restoreKVMConfig(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = m.deleteCreatedCRs(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (m *kvmMigrator) restoreCluster(ctx context.Context) error {
+	original := &capi.Cluster{}
+	err := m.backup.load(ctx, backupCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cluster := m.crs.cluster
+	restoreMetadata(cluster, original)
+	cluster.Spec.ControlPlaneRef = original.Spec.ControlPlaneRef
+	cluster.Spec.InfrastructureRef = original.Spec.InfrastructureRef
+
+	err = m.mcCtrlClient.Update(ctx, cluster)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored Cluster %s/%s from backup", cluster.Namespace, cluster.Name)
+
+	return nil
+}
+
+func (m *kvmMigrator) restoreKVMConfig(ctx context.Context) error {
+	original := &provider.KVMConfig{}
+	err := m.backup.load(ctx, backupInfrastructureCluster, original)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	kvmConfig := m.crs.kvmConfig
+	restoreMetadata(kvmConfig, original)
+
+	err = m.mcCtrlClient.Update(ctx, kvmConfig)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	m.logger.Debugf(ctx, "restored KVMConfig %s/%s from backup", kvmConfig.Namespace, kvmConfig.Name)
+
+	return nil
+}
+
+// deleteCreatedCRs deletes CRs and secrets created in prepareMissingCRs.
+// Workers go first, so that upstream controllers don't recreate them while
+// the control plane is being deleted.
+func (m *kvmMigrator) deleteCreatedCRs(ctx context.Context) error {
+	workers := metav1.ObjectMeta{
+		Name:      key.KVMWorkersName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+	controlPlane := metav1.ObjectMeta{
+		Name:      key.KVMControlPlaneName(m.clusterID),
+		Namespace: m.clusterNamespace,
+	}
+
+	objs := []runtime.Object{
+		&capi.MachineDeployment{ObjectMeta: workers},
+		&bootstrap.KubeadmConfigTemplate{ObjectMeta: workers},
+		newByoObject(kindByoMachineTemplate, workers.Namespace, workers.Name),
+		&kubeadm.KubeadmControlPlane{ObjectMeta: controlPlane},
+		newByoObject(kindByoMachineTemplate, controlPlane.Namespace, controlPlane.Name),
+		newByoObject(kindByoCluster, m.clusterNamespace, m.clusterID),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.EncryptionConfigSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.CustomFilesSecretName(m.clusterID),
+				Namespace: m.clusterNamespace,
+			},
+		},
+	}
+
+	for _, obj := range objs {
+		err := deleteIfExists(ctx, m.mcCtrlClient, obj)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	m.logger.Debugf(ctx, "deleted CRs and secrets created for upstream controllers")
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	provider "github.com/giantswarm/apiextensions/v3/pkg/apis/provider/v1alpha1"
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_kvmMigrator_Golden(t *testing.T) {
+	ctx := context.Background()
+	scheme := newGoldenScheme(t)
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	clusterLabels := map[string]string{
+		capi.ClusterLabelName:    "abc12",
+		label.KVMOperatorVersion: "3.17.0",
+		label.ReleaseVersion:     "14.1.0",
+	}
+
+	cluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "abc12",
+			Namespace:  "org-giantswarm",
+			Labels:     clusterLabels,
+			Finalizers: []string{"operatorkit.giantswarm.io/cluster-operator-cluster-controller"},
+		},
+	}
+
+	kvmConfig := &provider.KVMConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "abc12",
+			Namespace:  "default",
+			Labels:     clusterLabels,
+			Finalizers: []string{"operatorkit.giantswarm.io/kvm-operator"},
+		},
+	}
+	kvmConfig.Spec.Cluster.Etcd.Domain = "etcd.abc12.k8s.example.com"
+	kvmConfig.Spec.Cluster.Kubernetes.API.Domain = "api.abc12.k8s.example.com"
+	kvmConfig.Spec.Cluster.Kubernetes.API.ClusterIPRange = "172.31.0.0/16"
+	kvmConfig.Spec.KVM.Workers = make([]provider.KVMConfigSpecKVMNode, 3)
+
+	objs := []runtime.Object{
+		cluster,
+		kvmConfig,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "abc12-encryption", Namespace: "default"},
+			Data:       map[string][]byte{"encryption": []byte("c2VjcmV0LWVuY3J5cHRpb24ta2V5")},
+		},
+		&release.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: "v14.1.0"},
+			Spec: release.ReleaseSpec{
+				Components: []release.ReleaseSpecComponent{
+					{Name: "kubernetes", Version: "1.19.9"},
+					{Name: "etcd", Version: "3.4.14"},
+				},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+	_, err = EnsureClusterMigration(ctx, c, scheme, cluster, ProviderKVM)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	m := &kvmMigrator{
+		migratorBase: migratorBase{
+			clusterID:        "abc12",
+			clusterNamespace: "org-giantswarm",
+
+			logger:       logger,
+			mcCtrlClient: c,
+			status:       newMigrationStatus(c, cluster, false),
+			ssh: SSHConfig{
+				Users: []SSHUser{{Name: "jane", AuthorizedKeys: []string{"ssh-ed25519 AAAA jane@example.com"}}},
+			},
+		},
+	}
+
+	err = m.readCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.prepareMissingCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.updateCRs(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	meta := func(namespace, name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace}
+	}
+
+	assertGolden(t, c, scheme, "kvm",
+		&corev1.Secret{ObjectMeta: meta("org-giantswarm", "abc12-k8s-encryption-config")},
+		&corev1.Secret{ObjectMeta: meta("org-giantswarm", "abc12-custom-files")},
+		newByoObject(kindByoCluster, "org-giantswarm", "abc12"),
+		&kubeadm.KubeadmControlPlane{ObjectMeta: meta("org-giantswarm", "abc12-control-plane")},
+		newByoObject(kindByoMachineTemplate, "org-giantswarm", "abc12-control-plane"),
+		&bootstrap.KubeadmConfigTemplate{ObjectMeta: meta("org-giantswarm", "abc12-md-0")},
+		newByoObject(kindByoMachineTemplate, "org-giantswarm", "abc12-md-0"),
+		&capi.MachineDeployment{ObjectMeta: meta("org-giantswarm", "abc12-md-0")},
+		&capi.Cluster{ObjectMeta: meta("org-giantswarm", "abc12")},
+		&provider.KVMConfig{ObjectMeta: meta("default", "abc12")},
+	)
+}
+
+func Test_kvmMigrator_createCRs(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = capi.AddToScheme(scheme)
+	_ = bootstrap.AddToScheme(scheme)
+	_ = kubeadm.AddToScheme(scheme)
+
+	logger, err := micrologger.New(micrologger.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	kvmConfig := &provider.KVMConfig{}
+	kvmConfig.Spec.Cluster.Etcd.Domain = "etcd.abc12.k8s.example.com"
+	kvmConfig.Spec.Cluster.Kubernetes.API.Domain = "api.abc12.k8s.example.com"
+	kvmConfig.Spec.Cluster.Kubernetes.API.ClusterIPRange = "172.31.0.0/16"
+	kvmConfig.Spec.KVM.Workers = make([]provider.KVMConfigSpecKVMNode, 3)
+
+	m := &kvmMigrator{
+		migratorBase: migratorBase{
+			clusterID:        "abc12",
+			clusterNamespace: "org-giantswarm",
+			logger:           logger,
+			mcCtrlClient:     fake.NewFakeClientWithScheme(scheme),
+		},
+		crs: kvmCRs{
+			kvmConfig: kvmConfig,
+			release: &release.Release{
+				Spec: release.ReleaseSpec{
+					Components: []release.ReleaseSpecComponent{
+						{Name: "kubernetes", Version: "1.19.9"},
+					},
+				},
+			},
+		},
+	}
+
+	err = m.createKubeadmControlPlane(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = m.createWorkersMachineDeployment(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err = m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "org-giantswarm", Name: "abc12-control-plane"}, kcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if kcp.Spec.InfrastructureTemplate.Kind != kindByoMachineTemplate {
+		t.Fatalf("expected infrastructure template kind %q, got %q", kindByoMachineTemplate, kcp.Spec.InfrastructureTemplate.Kind)
+	}
+	if kcp.Spec.Version != "v1.19.9" {
+		t.Fatalf("expected version %q, got %q", "v1.19.9", kcp.Spec.Version)
+	}
+	if prefix := kcp.Spec.KubeadmConfigSpec.ClusterConfiguration.APIServer.ExtraArgs["etcd-prefix"]; prefix != "giantswarm.io" {
+		t.Fatalf("expected etcd prefix %q, got %q", "giantswarm.io", prefix)
+	}
+
+	md := &capi.MachineDeployment{}
+	err = m.mcCtrlClient.Get(ctx, ctrl.ObjectKey{Namespace: "org-giantswarm", Name: "abc12-md-0"}, md)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if *md.Spec.Replicas != 3 {
+		t.Fatalf("expected 3 replicas, got %d", *md.Spec.Replicas)
+	}
+	if md.Spec.Template.Spec.InfrastructureRef.Kind != kindByoMachineTemplate {
+		t.Fatalf("expected infrastructure kind %q, got %q", kindByoMachineTemplate, md.Spec.Template.Spec.InfrastructureRef.Kind)
+	}
+}
+
+func Test_templateRenderer_ByoCluster(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	err := (&templateRenderer{}).render("byo_cluster_kvm.yaml.tmpl", map[string]string{
+		"ClusterID":   "abc12",
+		"Namespace":   "org-giantswarm",
+		"APIEndpoint": "api.abc12.k8s.example.com",
+	}, obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if obj.GroupVersionKind() != byoGroupVersion.WithKind(kindByoCluster) {
+		t.Fatalf("unexpected GroupVersionKind %s", obj.GroupVersionKind())
+	}
+
+	port, found, err := unstructured.NestedInt64(obj.Object, "spec", "controlPlaneEndpoint", "port")
+	if err != nil || !found || port != 443 {
+		t.Fatalf("expected port 443, got %d (found %t, error %#v)", port, found, err)
+	}
+}
@@ -0,0 +1,55 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/apiextensions/v3/pkg/label"
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+// validate runs the shared pre-flight checks of validateMigration and checks
+// KVM infrastructure of the cluster.
+func (m *kvmMigrator) validate(ctx context.Context) *validationFindings {
+	f := &validationFindings{}
+
+	err := m.readCluster(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read Cluster: %s", microerror.Cause(err))
+		return f
+	}
+
+	err = m.readRelease(ctx, m.crs.cluster.GetLabels()[label.ReleaseVersion])
+	if err != nil {
+		f.blocking(checkRelease, "failed to read Release: %s", microerror.Cause(err))
+	}
+
+	validateMigration(ctx, f, m.mcCtrlClient, m.wcCtrlClient, m.certs, m.crs.cluster, m.crs.release, kvmInfrastructureComponent)
+
+	err = m.readKVMConfig(ctx)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to read KVMConfig: %s", microerror.Cause(err))
+		return f
+	}
+
+	kvmConfig := m.crs.kvmConfig
+	if kvmConfig.Spec.Cluster.Kubernetes.API.Domain == "" {
+		f.blocking(checkInfrastructure, "KVMConfig %s has no API domain", kvmConfig.Name)
+	}
+	if kvmConfig.Spec.Cluster.Etcd.Domain == "" {
+		f.blocking(checkInfrastructure, "KVMConfig %s has no etcd domain", kvmConfig.Name)
+	}
+	if len(kvmConfig.Spec.KVM.Masters) > 1 {
+		f.warning(checkInfrastructure, "KVMConfig %s has %d masters, the migrated cluster starts with a single master", kvmConfig.Name, len(kvmConfig.Spec.KVM.Masters))
+	}
+
+	masters, err := m.getLegacyDeployments(ctx, kvmRoleMaster)
+	if err != nil {
+		f.blocking(checkInfrastructure, "failed to list legacy master Deployments: %s", microerror.Cause(err))
+	} else if len(masters) == 0 {
+		f.blocking(checkInfrastructure, "no legacy master Deployment found in namespace %s", key.KVMLegacyNamespace(m.clusterID))
+	}
+
+	return f
+}
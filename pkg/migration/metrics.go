@@ -0,0 +1,154 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+const (
+	metricsNamespace = "capi_migration"
+
+	metricsRoleMaster   = "master"
+	metricsRoleNodePool = "node_pool"
+)
+
+var (
+	migrationPhase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "phase",
+			Help:      "Current migration phase of the cluster. The series of the current phase is 1, all others are 0.",
+		},
+		[]string{"cluster", "provider", "phase"},
+	)
+
+	migrationFailedPhase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "failed_phase",
+			Help:      "Phase in which the migration of the cluster failed. The series of that phase is 1 while the cluster is in Failed phase, all others are 0.",
+		},
+		[]string{"cluster", "provider", "failed_phase"},
+	)
+
+	prepareStepDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "prepare_step_duration_seconds",
+			Help:      "Duration of migration Prepare steps.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"cluster", "provider", "step"},
+	)
+
+	migrationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "Number of failed migration reconciliations by error kind.",
+		},
+		[]string{"cluster", "provider", "kind"},
+	)
+
+	legacyNodeGroups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "legacy_node_groups",
+			Help:      "Number of legacy ASGs (AWS) or VMSSes (Azure) still present during cleanup.",
+		},
+		[]string{"cluster", "provider", "role"},
+	)
+)
+
+// migrationPhases lists all phases exposed by migrationPhase, so that
+// series of phases the cluster is no longer in can be reset.
+var migrationPhases = []v1alpha1.ClusterMigrationPhase{
+	v1alpha1.ClusterMigrationPhasePending,
+	v1alpha1.ClusterMigrationPhaseValidating,
+	v1alpha1.ClusterMigrationPhasePreparing,
+	v1alpha1.ClusterMigrationPhasePrepared,
+	v1alpha1.ClusterMigrationPhaseTriggered,
+	v1alpha1.ClusterMigrationPhaseWaitingForControlPlane,
+	v1alpha1.ClusterMigrationPhaseCleaningUp,
+	v1alpha1.ClusterMigrationPhaseCompleted,
+	v1alpha1.ClusterMigrationPhaseRollingBack,
+	v1alpha1.ClusterMigrationPhaseRolledBack,
+	v1alpha1.ClusterMigrationPhaseFailed,
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		migrationPhase,
+		migrationFailedPhase,
+		prepareStepDuration,
+		migrationErrors,
+		legacyNodeGroups,
+	)
+}
+
+// DeleteMetrics removes gauges of the given cluster, so that deleted
+// clusters don't keep firing alerts.
+func DeleteMetrics(clusterID, provider string) {
+	for _, phase := range migrationPhases {
+		migrationPhase.DeleteLabelValues(clusterID, provider, string(phase))
+		migrationFailedPhase.DeleteLabelValues(clusterID, provider, string(phase))
+	}
+	for _, role := range []string{metricsRoleMaster, metricsRoleNodePool} {
+		legacyNodeGroups.DeleteLabelValues(clusterID, provider, role)
+	}
+}
+
+// observePhase exposes the current phase of the migration and, when it
+// failed, the phase in which it failed, so that alerts can tell failed
+// pre-flight checks from failures of a migration in progress.
+func observePhase(cm *v1alpha1.ClusterMigration) {
+	for _, phase := range migrationPhases {
+		var v float64
+		if phase == cm.Status.Phase {
+			v = 1
+		}
+		migrationPhase.WithLabelValues(cm.Spec.ClusterName, cm.Spec.Provider, string(phase)).Set(v)
+
+		var failed float64
+		if cm.Status.Phase == v1alpha1.ClusterMigrationPhaseFailed && phase == cm.Status.FailedPhase {
+			failed = 1
+		}
+		migrationFailedPhase.WithLabelValues(cm.Spec.ClusterName, cm.Spec.Provider, string(phase)).Set(failed)
+	}
+}
+
+// observeError counts the failed reconciliation by error kind. Waiting
+// during cleanup is not a failure and is not counted, see IsWaiting.
+func observeError(cm *v1alpha1.ClusterMigration, err error) {
+	if IsWaiting(err) {
+		return
+	}
+
+	kind := "unknown"
+	var merr *microerror.Error
+	if errors.As(err, &merr) && merr != nil && merr.Kind != "" {
+		kind = merr.Kind
+	}
+
+	migrationErrors.WithLabelValues(cm.Spec.ClusterName, cm.Spec.Provider, kind).Inc()
+}
+
+func observeLegacyNodeGroups(clusterID, provider, role string, count int) {
+	legacyNodeGroups.WithLabelValues(clusterID, provider, role).Set(float64(count))
+}
+
+// observePrepareStep runs the given Prepare step and records its duration.
+func observePrepareStep(ctx context.Context, clusterID, provider, step string, f func(context.Context) error) error {
+	start := time.Now()
+	err := f(ctx)
+	prepareStepDuration.WithLabelValues(clusterID, provider, step).Observe(time.Since(start).Seconds())
+
+	return err
+}
@@ -0,0 +1,162 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+func Test_observeError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		kind     string
+		expected float64
+	}{
+		{
+			name:     "case 0: masked microerror",
+			err:      microerror.Mask(microerror.Maskf(tooManyMastersError, "2 masters found")),
+			kind:     "tooManyMastersError",
+			expected: 1,
+		},
+		{
+			name:     "case 1: plain error",
+			err:      microerror.Mask(errors.New("boom")),
+			kind:     "unknown",
+			expected: 1,
+		},
+		{
+			name:     "case 2: waiting is not counted",
+			err:      microerror.Mask(microerror.Maskf(newWorkersNotReady, "Expected at least 2 CAPI workers to be ready, 1 found")),
+			kind:     "newWorkersNotReady",
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &v1alpha1.ClusterMigration{
+				Spec: v1alpha1.ClusterMigrationSpec{
+					ClusterName: "abc12",
+					Provider:    ProviderAWS,
+				},
+			}
+
+			before := testutil.ToFloat64(migrationErrors.WithLabelValues("abc12", ProviderAWS, tc.kind))
+			observeError(cm, tc.err)
+			after := testutil.ToFloat64(migrationErrors.WithLabelValues("abc12", ProviderAWS, tc.kind))
+
+			if after-before != tc.expected {
+				t.Fatalf("expected %q errors to increase by %v, got %v", tc.kind, tc.expected, after-before)
+			}
+		})
+	}
+}
+
+func Test_observePhase(t *testing.T) {
+	testCases := []struct {
+		name             string
+		status           v1alpha1.ClusterMigrationStatus
+		expectedFailures map[v1alpha1.ClusterMigrationPhase]float64
+	}{
+		{
+			name: "case 0: migration in progress",
+			status: v1alpha1.ClusterMigrationStatus{
+				Phase: v1alpha1.ClusterMigrationPhasePreparing,
+			},
+			expectedFailures: map[v1alpha1.ClusterMigrationPhase]float64{
+				v1alpha1.ClusterMigrationPhaseValidating: 0,
+				v1alpha1.ClusterMigrationPhasePreparing:  0,
+			},
+		},
+		{
+			name: "case 1: pre-flight checks failed",
+			status: v1alpha1.ClusterMigrationStatus{
+				Phase:       v1alpha1.ClusterMigrationPhaseFailed,
+				FailedPhase: v1alpha1.ClusterMigrationPhaseValidating,
+			},
+			expectedFailures: map[v1alpha1.ClusterMigrationPhase]float64{
+				v1alpha1.ClusterMigrationPhaseValidating: 1,
+				v1alpha1.ClusterMigrationPhasePreparing:  0,
+			},
+		},
+		{
+			name: "case 2: prepare failed",
+			status: v1alpha1.ClusterMigrationStatus{
+				Phase:       v1alpha1.ClusterMigrationPhaseFailed,
+				FailedPhase: v1alpha1.ClusterMigrationPhasePreparing,
+			},
+			expectedFailures: map[v1alpha1.ClusterMigrationPhase]float64{
+				v1alpha1.ClusterMigrationPhaseValidating: 0,
+				v1alpha1.ClusterMigrationPhasePreparing:  1,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &v1alpha1.ClusterMigration{
+				Spec: v1alpha1.ClusterMigrationSpec{
+					ClusterName: "abc12",
+					Provider:    ProviderAWS,
+				},
+				Status: tc.status,
+			}
+
+			observePhase(cm)
+
+			for phase, expected := range tc.expectedFailures {
+				v := testutil.ToFloat64(migrationFailedPhase.WithLabelValues("abc12", ProviderAWS, string(phase)))
+				if v != expected {
+					t.Fatalf("expected failed phase %q to be %v, got %v", phase, expected, v)
+				}
+			}
+		})
+	}
+}
+
+func Test_migrationDriver_runPrepareStep(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dryRun   *dryRunPlan
+		expected int
+	}{
+		{
+			name:     "case 0: duration is recorded",
+			expected: 1,
+		},
+		{
+			name:     "case 1: duration is not recorded in dry run",
+			dryRun:   &dryRunPlan{},
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareStepDuration.Reset()
+
+			var called bool
+			d := newMigrationDriver(ProviderAWS, &migratorBase{clusterID: "abc12", dryRunPlan: tc.dryRun}, nil)
+			err := d.runPrepareStep(context.Background(), "test", func(context.Context) error {
+				called = true
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if !called {
+				t.Fatalf("expected step to run")
+			}
+			if n := testutil.CollectAndCount(prepareStepDuration); n != tc.expected {
+				t.Fatalf("expected %d recorded steps, got %d", tc.expected, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/giantswarm/microerror"
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+// recordMutation computes RFC 6902 JSON patch between original and mutated
+// version of a CR and appends it to the ClusterMigration status. mutated must
+// be taken before the CR is written, because the object returned by the API
+// server carries changes made by the server. Empty patches, e.g. when a CR
+// has been mutated by a previous reconciliation already, are not recorded.
+func (s *migrationStatus) recordMutation(ctx context.Context, kind string, original runtime.Object, mutated runtime.Object) error {
+	patch, err := createJSONPatch(original, mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if patch == "" {
+		return nil
+	}
+
+	accessor, err := meta.Accessor(mutated)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cm, err := s.get(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	cm.Status.Mutations = append(cm.Status.Mutations, v1alpha1.ClusterMigrationMutation{
+		Kind:      kind,
+		Name:      accessor.GetName(),
+		Namespace: accessor.GetNamespace(),
+		Patch:     patch,
+		Time:      metav1.Now(),
+	})
+
+	err = s.client.Status().Update(ctx, cm)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// createJSONPatch returns RFC 6902 JSON patch turning original into mutated.
+// Metadata managed by the API server is left out, so that the patch only
+// contains changes made by the migration and can be reviewed or reverted.
+func createJSONPatch(original runtime.Object, mutated runtime.Object) (string, error) {
+	originalJSON, err := marshalWithoutServerFields(original)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	mutatedJSON, err := marshalWithoutServerFields(mutated)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	ops, err := jsonpatch.CreatePatch(originalJSON, mutatedJSON)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if len(ops) == 0 {
+		return "", nil
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return string(patch), nil
+}
+
+func marshalWithoutServerFields(obj runtime.Object) ([]byte, error) {
+	obj = obj.DeepCopyObject()
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	accessor.SetCreationTimestamp(metav1.Time{})
+	accessor.SetGeneration(0)
+	accessor.SetManagedFields(nil)
+	accessor.SetResourceVersion("")
+	accessor.SetSelfLink("")
+	accessor.SetUID("")
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return b, nil
+}
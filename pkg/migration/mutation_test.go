@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+func Test_createJSONPatch(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mutate        func(cluster *capi.Cluster)
+		expectedPatch string
+	}{
+		{
+			name:          "case 0: unchanged object gives empty patch",
+			mutate:        func(cluster *capi.Cluster) {},
+			expectedPatch: "",
+		},
+		{
+			name: "case 1: removed label is patched",
+			mutate: func(cluster *capi.Cluster) {
+				delete(cluster.Labels, "aws-operator.giantswarm.io/version")
+			},
+			expectedPatch: `[{"op":"remove","path":"/metadata/labels/aws-operator.giantswarm.io~1version"}]`,
+		},
+		{
+			name: "case 2: server managed metadata is not patched",
+			mutate: func(cluster *capi.Cluster) {
+				cluster.CreationTimestamp = metav1.Now()
+				cluster.Generation = 2
+				cluster.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "capi-migration"}}
+				cluster.ResourceVersion = "1002"
+				cluster.UID = "3c3f0ef8-5a8b-4f0e-9c3e-6f1c1b0e5f9a"
+			},
+			expectedPatch: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := newTestCluster(false)
+			original.Labels["aws-operator.giantswarm.io/version"] = "10.0.0"
+			original.Generation = 1
+			original.ResourceVersion = "1001"
+
+			mutated := original.DeepCopy()
+			tc.mutate(mutated)
+
+			patch, err := createJSONPatch(original, mutated)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if patch != tc.expectedPatch {
+				t.Fatalf("patch = %s, want %s", patch, tc.expectedPatch)
+			}
+		})
+	}
+}
+
+func Test_migrationStatus_recordMutation(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = capi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	cluster := newTestCluster(false)
+	cluster.Labels["aws-operator.giantswarm.io/version"] = "10.0.0"
+
+	clusterMigration := &v1alpha1.ClusterMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, cluster, clusterMigration)
+	s := newMigrationStatus(c, cluster, false)
+
+	original := cluster.DeepCopy()
+	delete(cluster.Labels, "aws-operator.giantswarm.io/version")
+	mutated := cluster.DeepCopy()
+
+	err := c.Update(ctx, cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = s.recordMutation(ctx, "Cluster", original, mutated)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// Repeated mutation, e.g. by the next reconciliation, is not recorded.
+	err = s.recordMutation(ctx, "Cluster", mutated, mutated)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, clusterMigration)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(clusterMigration.Status.Mutations) != 1 {
+		t.Fatalf("expected 1 mutation, got %#v", clusterMigration.Status.Mutations)
+	}
+
+	mutation := clusterMigration.Status.Mutations[0]
+	if mutation.Kind != "Cluster" || mutation.Name != cluster.Name || mutation.Namespace != cluster.Namespace {
+		t.Fatalf("unexpected mutation target %s %s/%s", mutation.Kind, mutation.Namespace, mutation.Name)
+	}
+
+	expectedPatch := `[{"op":"remove","path":"/metadata/labels/aws-operator.giantswarm.io~1version"}]`
+	if mutation.Patch != expectedPatch {
+		t.Fatalf("patch = %s, want %s", mutation.Patch, expectedPatch)
+	}
+}
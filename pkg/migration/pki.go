@@ -0,0 +1,152 @@
+package migration
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// caMinRemainingLifetime is how long a CA must still be valid to be migrated.
+// Masters created during the migration bootstrap from it, so a CA expiring
+// soon would leave the cluster unusable shortly after.
+const caMinRemainingLifetime = 30 * 24 * time.Hour
+
+// caBundle is a parsed CA certificate with its private key. PEM data is kept
+// as given, so it can be written to secrets unchanged.
+type caBundle struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// parseCABundle parses PEM encoded CA certificate and private key and checks
+// that they belong together and can sign certificates.
+func parseCABundle(certPEM, keyPEM []byte) (*caBundle, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if !publicKeysEqual(cert.PublicKey, key.Public()) {
+		return nil, microerror.Maskf(invalidCertificateError, "private key does not match CA certificate %q", cert.Subject.CommonName)
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil, microerror.Maskf(invalidCertificateError, "certificate %q is not a CA", cert.Subject.CommonName)
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, microerror.Maskf(invalidCertificateError, "CA certificate %q is not allowed to sign certificates", cert.Subject.CommonName)
+	}
+
+	b := &caBundle{
+		cert: cert,
+		key:  key,
+
+		certPEM: certPEM,
+		keyPEM:  keyPEM,
+	}
+
+	return b, nil
+}
+
+// validateLifetime checks that the CA is valid at now and stays valid for at
+// least minRemaining.
+func (b *caBundle) validateLifetime(now time.Time, minRemaining time.Duration) error {
+	if now.Before(b.cert.NotBefore) {
+		return microerror.Maskf(invalidCertificateError, "CA certificate %q is not valid before %s", b.cert.Subject.CommonName, b.cert.NotBefore.UTC())
+	}
+	if now.Add(minRemaining).After(b.cert.NotAfter) {
+		return microerror.Maskf(invalidCertificateError, "CA certificate %q expires at %s, less than %s from now", b.cert.Subject.CommonName, b.cert.NotAfter.UTC(), minRemaining)
+	}
+
+	return nil
+}
+
+// verifyLeaf checks that the PEM encoded leaf certificate was issued by the CA
+// and is valid at now.
+func (b *caBundle) verifyLeaf(now time.Time, leafPEM []byte) error {
+	leaf, err := parseCertificate(leafPEM)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(b.cert)
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return microerror.Maskf(invalidCertificateError, "certificate %q does not chain to CA %q: %s", leaf.Subject.CommonName, b.cert.Subject.CommonName, err)
+	}
+
+	return nil
+}
+
+// parseCertificate parses the first PEM block of data as certificate.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, microerror.Maskf(invalidCertificateError, "no PEM encoded certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, microerror.Maskf(invalidCertificateError, "failed to parse certificate: %s", err)
+	}
+
+	return cert, nil
+}
+
+// parsePrivateKey parses the first PEM block of data as PKCS#1, SEC 1 or
+// PKCS#8 private key.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, microerror.Maskf(invalidCertificateError, "no PEM encoded private key found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, microerror.Maskf(invalidCertificateError, "failed to parse RSA private key: %s", err)
+		}
+		return key, nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, microerror.Maskf(invalidCertificateError, "failed to parse EC private key: %s", err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, microerror.Maskf(invalidCertificateError, "failed to parse PKCS#8 private key: %s", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, microerror.Maskf(invalidCertificateError, "unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, microerror.Maskf(invalidCertificateError, "unsupported PEM block type %q", block.Type)
+	}
+}
+
+// publicKeysEqual compares public keys of any type of the standard library,
+// which all implement Equal.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
@@ -0,0 +1,155 @@
+package migration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate valid from now-1h for lifetime. It is
+// self-signed when parent is nil.
+func newTestCert(t *testing.T, name string, isCA bool, now time.Time, lifetime time.Duration, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(lifetime),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	issuer, issuerKey := template, key
+	if parent != nil {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func Test_caBundle(t *testing.T) {
+	now := time.Now()
+	year := 365 * 24 * time.Hour
+
+	ca := newTestCert(t, "abc12", true, now, year, nil)
+	otherCA := newTestCert(t, "other", true, now, year, nil)
+	expiringCA := newTestCert(t, "abc12", true, now, 24*time.Hour, nil)
+	notCA := newTestCert(t, "abc12", false, now, year, nil)
+
+	testCases := []struct {
+		name         string
+		certPEM      []byte
+		keyPEM       []byte
+		leafPEM      []byte
+		errorMatcher func(error) bool
+	}{
+		{
+			name:    "case 0: valid CA and leaf",
+			certPEM: ca.certPEM,
+			keyPEM:  ca.keyPEM,
+			leafPEM: newTestCert(t, "api.abc12", false, now, year, ca).certPEM,
+		},
+		{
+			name:         "case 1: key of other CA",
+			certPEM:      ca.certPEM,
+			keyPEM:       otherCA.keyPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+		{
+			name:         "case 2: certificate is not a CA",
+			certPEM:      notCA.certPEM,
+			keyPEM:       notCA.keyPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+		{
+			name:         "case 3: CA expires soon",
+			certPEM:      expiringCA.certPEM,
+			keyPEM:       expiringCA.keyPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+		{
+			name:         "case 4: leaf issued by other CA",
+			certPEM:      ca.certPEM,
+			keyPEM:       ca.keyPEM,
+			leafPEM:      newTestCert(t, "api.abc12", false, now, year, otherCA).certPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+		{
+			name:         "case 5: malformed PEM",
+			certPEM:      []byte("not a certificate"),
+			keyPEM:       ca.keyPEM,
+			errorMatcher: IsInvalidCertificate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := func() error {
+				b, err := parseCABundle(tc.certPEM, tc.keyPEM)
+				if err != nil {
+					return err
+				}
+				err = b.validateLifetime(now, caMinRemainingLifetime)
+				if err != nil {
+					return err
+				}
+				if tc.leafPEM != nil {
+					return b.verifyLeaf(now, tc.leafPEM)
+				}
+				return nil
+			}()
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		})
+	}
+}
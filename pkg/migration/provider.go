@@ -0,0 +1,226 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/tenantcluster/v3/pkg/tenantcluster"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/pkg/meta"
+)
+
+const (
+	ProviderAWS   = "aws"
+	ProviderAzure = "azure"
+	ProviderKVM   = "kvm"
+)
+
+// Provider is a migration provider registered with RegisterProvider. RBAC
+// needs of a provider are declared with kubebuilder markers next to its
+// registration.
+type Provider struct {
+	// Name of the provider, e.g. "aws".
+	Name string
+	// InfrastructureKinds are kinds of Cluster infrastructureRef handled by
+	// the provider.
+	InfrastructureKinds []string
+
+	// AddToScheme registers provider specific types.
+	AddToScheme func(s *runtime.Scheme) error
+	// InitFlags binds provider specific flags. Optional.
+	InitFlags func(fs *pflag.FlagSet)
+	// ValidateFlags is called for enabled providers after flags are parsed.
+	// Optional.
+	ValidateFlags func() error
+	// NewMigratorFactory constructs MigratorFactory of the provider.
+	NewMigratorFactory func(cfg ProviderConfig) (MigratorFactory, error)
+}
+
+// ProviderConfig is the configuration shared by all providers.
+type ProviderConfig struct {
+	CtrlClient    ctrl.Client
+	EventRecorder record.EventRecorder
+	Logger        micrologger.Logger
+	Scheme        *runtime.Scheme
+	TenantCluster tenantcluster.Interface
+	VaultClient   *vaultapi.Client
+
+	DryRun       bool
+	SSH          SSHConfig
+	SSHConfigMap ctrl.ObjectKey
+
+	TemplatesConfigMap ctrl.ObjectKey
+
+	// NewAWSClients and NewAzureVMSSClient replace cloud API clients of the
+	// respective providers, e.g. with fakes in tests. Real clients are
+	// created when they are nil.
+	NewAWSClients      AWSClientsFactory
+	NewAzureVMSSClient AzureVMSSClientFactory
+}
+
+var providers = map[string]Provider{}
+
+// RegisterProvider makes a provider available by its name. It is meant to be
+// called from init functions and panics when the provider is invalid or
+// registered twice.
+func RegisterProvider(p Provider) {
+	if p.Name == "" || len(p.InfrastructureKinds) == 0 || p.AddToScheme == nil || p.NewMigratorFactory == nil {
+		panic(fmt.Sprintf("provider %q must have name, infrastructure kinds, scheme and factory", p.Name))
+	}
+	if _, ok := providers[p.Name]; ok {
+		panic(fmt.Sprintf("provider %q registered twice", p.Name))
+	}
+	for _, other := range providers {
+		for _, k := range other.InfrastructureKinds {
+			if containsString(p.InfrastructureKinds, k) {
+				panic(fmt.Sprintf("infrastructure kind %q of provider %q already handled by %q", k, p.Name, other.Name))
+			}
+		}
+	}
+
+	providers[p.Name] = p
+}
+
+// ProviderNames returns sorted names of all registered providers.
+func ProviderNames() []string {
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// AddProvidersToScheme registers types of all registered providers.
+func AddProvidersToScheme(s *runtime.Scheme) error {
+	for _, name := range ProviderNames() {
+		err := providers[name].AddToScheme(s)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// InitProviderFlags binds flags of all registered providers.
+func InitProviderFlags(fs *pflag.FlagSet) {
+	for _, name := range ProviderNames() {
+		if providers[name].InitFlags != nil {
+			providers[name].InitFlags(fs)
+		}
+	}
+}
+
+// ClusterProvider returns name of the registered provider handling given
+// cluster based on its infrastructureRef kind.
+func ClusterProvider(cluster *v1alpha3.Cluster) (string, error) {
+	if cluster.Spec.InfrastructureRef == nil {
+		return "", microerror.Maskf(providerNotFoundError, "cluster %s/%s has no infrastructureRef", cluster.Namespace, cluster.Name)
+	}
+
+	kind := cluster.Spec.InfrastructureRef.Kind
+	for _, name := range ProviderNames() {
+		if containsString(providers[name].InfrastructureKinds, kind) {
+			return name, nil
+		}
+	}
+
+	return "", microerror.Maskf(providerNotFoundError, "no provider handles infrastructureRef kind %#q of cluster %s/%s", kind, cluster.Namespace, cluster.Name)
+}
+
+// ProviderSelector is a MigratorFactory delegating to the factory of the
+// enabled provider handling the cluster.
+type ProviderSelector struct {
+	dryRun    bool
+	factories map[string]MigratorFactory
+}
+
+// NewProviderSelector validates flags of given providers and constructs
+// their factories. All registered providers are enabled when names is empty.
+func NewProviderSelector(cfg ProviderConfig, names []string) (*ProviderSelector, error) {
+	if len(names) == 0 {
+		names = ProviderNames()
+	}
+
+	s := &ProviderSelector{
+		dryRun:    cfg.DryRun,
+		factories: map[string]MigratorFactory{},
+	}
+
+	for _, name := range names {
+		p, ok := providers[name]
+		if !ok {
+			return nil, microerror.Maskf(providerNotFoundError, "provider %#q is not registered, registered providers are %v", name, ProviderNames())
+		}
+
+		if p.ValidateFlags != nil {
+			err := p.ValidateFlags()
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+		}
+
+		f, err := p.NewMigratorFactory(cfg)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		s.factories[name] = f
+	}
+
+	return s, nil
+}
+
+// Provider returns name of the enabled provider handling given cluster.
+func (s *ProviderSelector) Provider(cluster *v1alpha3.Cluster) (string, error) {
+	name, err := ClusterProvider(cluster)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	if _, ok := s.factories[name]; !ok {
+		return "", microerror.Maskf(providerNotFoundError, "provider %#q of cluster %s/%s is not enabled", name, cluster.Namespace, cluster.Name)
+	}
+
+	return name, nil
+}
+
+// IsDryRun returns true when migrators of given cluster only render the
+// migration plan.
+func (s *ProviderSelector) IsDryRun(cluster *v1alpha3.Cluster) bool {
+	return s.dryRun || meta.Annotation.DryRun.IsSet(cluster)
+}
+
+func (s *ProviderSelector) NewMigrator(cluster *v1alpha3.Cluster) (Migrator, error) {
+	name, err := s.Provider(cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	m, err := s.factories[name].NewMigrator(cluster)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return m, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func Test_ClusterProvider(t *testing.T) {
+	testCases := []struct {
+		name             string
+		infrastructure   *corev1.ObjectReference
+		expectedProvider string
+		errorMatcher     func(error) bool
+	}{
+		{
+			name:             "case 0: legacy AWS cluster",
+			infrastructure:   &corev1.ObjectReference{APIVersion: "infrastructure.giantswarm.io/v1alpha2", Kind: "AWSCluster"},
+			expectedProvider: ProviderAWS,
+		},
+		{
+			name:             "case 1: Azure cluster",
+			infrastructure:   &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3", Kind: "AzureCluster"},
+			expectedProvider: ProviderAzure,
+		},
+		{
+			name:           "case 2: unknown kind",
+			infrastructure: &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3", Kind: "DockerCluster"},
+			errorMatcher:   IsProviderNotFound,
+		},
+		{
+			name:         "case 3: missing infrastructureRef",
+			errorMatcher: IsProviderNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &capi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc12", Namespace: "default"},
+				Spec:       capi.ClusterSpec{InfrastructureRef: tc.infrastructure},
+			}
+
+			provider, err := ClusterProvider(cluster)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if provider != tc.expectedProvider {
+				t.Fatalf("expected provider %q, got %q", tc.expectedProvider, provider)
+			}
+		})
+	}
+}
+
+func Test_NewProviderSelector_UnknownProvider(t *testing.T) {
+	_, err := NewProviderSelector(ProviderConfig{}, []string{"vsphere"})
+	if !IsProviderNotFound(err) {
+		t.Fatalf("expected providerNotFoundError, got %#v", err)
+	}
+}
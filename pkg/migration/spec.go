@@ -17,6 +17,11 @@ type Migrator interface {
 	// Cleanup performs cleanup operations after migration has been completed.
 	Cleanup(ctx context.Context) error
 
+	// DeleteWorkloadArtifacts deletes objects created in the workload
+	// cluster during migration. It is called when the cluster is deleted
+	// during migration, together with DeleteArtifacts.
+	DeleteWorkloadArtifacts(ctx context.Context) error
+
 	// IsMigrated performs check to see if given cluster has been already
 	// migrated.
 	IsMigrated(ctx context.Context) (bool, error)
@@ -29,7 +34,17 @@ type Migrator interface {
 	// existing CRs into upstream compatible format and creating missing CRs.
 	Prepare(ctx context.Context) error
 
+	// Rollback returns a cluster which has not been cleaned up yet to the
+	// legacy operators. It restores existing CRs from the backup taken
+	// during Prepare and deletes CRs created for upstream controllers.
+	Rollback(ctx context.Context) error
+
 	// TriggerMigration performs final execution which shifts reconciliation to
 	// upstream controllers.
 	TriggerMigration(ctx context.Context) error
+
+	// Validate runs pre-flight checks without changing anything. Findings are
+	// recorded in the ClusterMigration CR of the cluster. An error matching
+	// IsValidationFailed is returned when any finding blocks the migration.
+	Validate(ctx context.Context) error
 }
@@ -0,0 +1,177 @@
+package migration
+
+import (
+	"context"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	bootstrap "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SSHConfigMapKey is the data key of the SSH access policy ConfigMap.
+	SSHConfigMapKey = "ssh.yaml"
+)
+
+// SSHConfig is the SSH access policy applied to machines created for
+// upstream controllers.
+type SSHConfig struct {
+	// Disabled removes all users and key pairs from rendered CRs.
+	Disabled bool `json:"disabled,omitempty"`
+	// Users are created on control plane and worker nodes through kubeadm
+	// bootstrap config.
+	Users []SSHUser `json:"users,omitempty"`
+	// AWSKeyPairName is the name of the EC2 key pair set on AWS machines.
+	AWSKeyPairName string `json:"awsKeyPairName,omitempty"`
+	// AzureSSHPublicKey is the base64 encoded public key set on Azure
+	// machines.
+	AzureSSHPublicKey string `json:"azureSSHPublicKey,omitempty"`
+}
+
+type SSHUser struct {
+	Name           string   `json:"name"`
+	AuthorizedKeys []string `json:"authorizedKeys"`
+}
+
+// ParseSSHUsers parses users given in "name=authorized-key" form. Keys of
+// users given multiple times are merged.
+func ParseSSHUsers(values []string) ([]SSHUser, error) {
+	var users []SSHUser
+	index := map[string]int{}
+
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, microerror.Maskf(invalidConfigError, "SSH user %q must be in \"name=authorized-key\" form", v)
+		}
+
+		i, ok := index[parts[0]]
+		if !ok {
+			i = len(users)
+			index[parts[0]] = i
+			users = append(users, SSHUser{Name: parts[0]})
+		}
+		users[i].AuthorizedKeys = append(users[i].AuthorizedKeys, parts[1])
+	}
+
+	return users, nil
+}
+
+// ParseSSHConfigMapKey parses "namespace/name" of the SSH access policy
+// ConfigMap. Empty value means there is no ConfigMap.
+func ParseSSHConfigMapKey(value string) (ctrl.ObjectKey, error) {
+	return parseConfigMapKey("SSH", value)
+}
+
+func parseConfigMapKey(what, value string) (ctrl.ObjectKey, error) {
+	if value == "" {
+		return ctrl.ObjectKey{}, nil
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ctrl.ObjectKey{}, microerror.Maskf(invalidConfigError, "%s ConfigMap %q must be in \"namespace/name\" form", what, value)
+	}
+
+	return ctrl.ObjectKey{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// Validate checks that all users have a name and at least one key.
+func (c SSHConfig) Validate() error {
+	for _, u := range c.Users {
+		if u.Name == "" {
+			return microerror.Maskf(invalidConfigError, "SSH user name must not be empty")
+		}
+		if len(u.AuthorizedKeys) == 0 {
+			return microerror.Maskf(invalidConfigError, "SSH user %#q must have at least one authorized key", u.Name)
+		}
+	}
+
+	return nil
+}
+
+// loadSSHConfig reads the SSH access policy from the ConfigMap given by key
+// and merges it over defaults. Fields not set in the ConfigMap keep their
+// default values. Empty key means there is no ConfigMap.
+func loadSSHConfig(ctx context.Context, c ctrl.Client, key ctrl.ObjectKey, defaults SSHConfig) (SSHConfig, error) {
+	if key.Name == "" {
+		return defaults, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, key, cm)
+	if err != nil {
+		return SSHConfig{}, microerror.Mask(err)
+	}
+
+	data, ok := cm.Data[SSHConfigMapKey]
+	if !ok {
+		return SSHConfig{}, microerror.Maskf(invalidConfigError, "ConfigMap %s/%s must have %#q key", key.Namespace, key.Name, SSHConfigMapKey)
+	}
+
+	// Users are decoded into a fresh slice, otherwise decoding would reuse
+	// elements of defaults. An explicit empty list removes all users.
+	config := defaults
+	config.Users = nil
+	err = yaml.Unmarshal([]byte(data), &config)
+	if err != nil {
+		return SSHConfig{}, microerror.Maskf(invalidConfigError, "ConfigMap %s/%s: %s", key.Namespace, key.Name, err)
+	}
+	if config.Users == nil {
+		config.Users = defaults.Users
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return SSHConfig{}, microerror.Mask(err)
+	}
+
+	return config, nil
+}
+
+func (c SSHConfig) bootstrapUsers() []bootstrap.User {
+	if c.Disabled {
+		return nil
+	}
+
+	var users []bootstrap.User
+	for _, u := range c.Users {
+		users = append(users, bootstrap.User{
+			Name:              u.Name,
+			SSHAuthorizedKeys: append([]string(nil), u.AuthorizedKeys...),
+		})
+	}
+
+	return users
+}
+
+// awsKeyPairName never returns nil, because CAPA falls back to its default
+// key pair for machines without a key name. Empty name means no key pair.
+func (c SSHConfig) awsKeyPairName() *string {
+	var name string
+	if !c.Disabled {
+		name = c.AWSKeyPairName
+	}
+
+	return &name
+}
+
+func (c SSHConfig) azureSSHPublicKey() string {
+	if c.Disabled {
+		return ""
+	}
+
+	return c.AzureSSHPublicKey
+}
+
+// azureUsers returns users in the form used by Azure templates.
+func (c SSHConfig) azureUsers() []SSHUser {
+	if c.Disabled {
+		return nil
+	}
+
+	return c.Users
+}
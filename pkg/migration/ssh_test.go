@@ -0,0 +1,121 @@
+package migration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_loadSSHConfig(t *testing.T) {
+	defaults := SSHConfig{
+		Users:          []SSHUser{{Name: "flag", AuthorizedKeys: []string{"ssh-rsa AAAA flag"}}},
+		AWSKeyPairName: "flag-key",
+	}
+
+	testCases := []struct {
+		name         string
+		data         map[string]string
+		expected     SSHConfig
+		errorMatcher func(error) bool
+	}{
+		{
+			name:     "case 0: ConfigMap overrides users and keeps key pair",
+			data:     map[string]string{SSHConfigMapKey: "users:\n- name: cm\n  authorizedKeys:\n  - ssh-rsa BBBB cm\n"},
+			expected: SSHConfig{Users: []SSHUser{{Name: "cm", AuthorizedKeys: []string{"ssh-rsa BBBB cm"}}}, AWSKeyPairName: "flag-key"},
+		},
+		{
+			name:     "case 1: ConfigMap disables SSH",
+			data:     map[string]string{SSHConfigMapKey: "disabled: true\n"},
+			expected: SSHConfig{Disabled: true, Users: defaults.Users, AWSKeyPairName: "flag-key"},
+		},
+		{
+			name:         "case 2: user without keys is rejected",
+			data:         map[string]string{SSHConfigMapKey: "users:\n- name: cm\n"},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 3: missing key is rejected",
+			data:         map[string]string{},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+
+			key := ctrl.ObjectKey{Namespace: "giantswarm", Name: "capi-migration-ssh"}
+			c := fake.NewFakeClientWithScheme(scheme, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Data:       tc.data,
+			})
+
+			config, err := loadSSHConfig(context.Background(), c, key, defaults)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if tc.errorMatcher == nil && !reflect.DeepEqual(config, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, config)
+			}
+		})
+	}
+}
+
+func Test_AzureWorkersTemplateSSHUsers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   SSHConfig
+		expected []cabpkv1.User
+	}{
+		{
+			name: "case 0: users are rendered",
+			config: SSHConfig{
+				Users: []SSHUser{{Name: "jane", AuthorizedKeys: []string{"ssh-rsa AAAA jane@example", "ssh-ed25519 BBBB jane@laptop"}}},
+			},
+			expected: []cabpkv1.User{{Name: "jane", SSHAuthorizedKeys: []string{"ssh-rsa AAAA jane@example", "ssh-ed25519 BBBB jane@laptop"}}},
+		},
+		{
+			name: "case 1: disabled SSH renders no users",
+			config: SSHConfig{
+				Disabled: true,
+				Users:    []SSHUser{{Name: "jane", AuthorizedKeys: []string{"ssh-rsa AAAA jane@example"}}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kct := &cabpkv1.KubeadmConfigTemplate{}
+			err := (&templateRenderer{}).render("workers_kubeadm_config_template_azure.yaml.tmpl", map[string]interface{}{
+				"ClusterID": "abc12",
+				"Namespace": "org-giantswarm",
+				"SSHUsers":  tc.config.azureUsers(),
+			}, kct)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			users := kct.Spec.Template.Spec.Users
+			if !reflect.DeepEqual(users, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, users)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+// EnsureClusterMigration returns ClusterMigration CR tracking migration
+// progress of given cluster. The CR is created in Pending phase when it
+// doesn't exist yet.
+func EnsureClusterMigration(ctx context.Context, c ctrl.Client, scheme *runtime.Scheme, cluster *capi.Cluster, provider string) (*v1alpha1.ClusterMigration, error) {
+	cm := &v1alpha1.ClusterMigration{}
+	err := c.Get(ctx, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, cm)
+	if err == nil {
+		return cm, nil
+	} else if !apierrors.IsNotFound(err) {
+		return nil, microerror.Mask(err)
+	}
+
+	cm = &v1alpha1.ClusterMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+		Spec: v1alpha1.ClusterMigrationSpec{
+			ClusterName: cluster.Name,
+			Provider:    provider,
+		},
+	}
+
+	err = controllerutil.SetOwnerReference(cluster, cm, scheme)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = c.Create(ctx, cm)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	err = SetPhase(ctx, c, cm, v1alpha1.ClusterMigrationPhasePending)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return cm, nil
+}
+
+// SetPhase moves given ClusterMigration to the given phase and clears last
+// error.
+func SetPhase(ctx context.Context, c ctrl.Client, cm *v1alpha1.ClusterMigration, phase v1alpha1.ClusterMigrationPhase) error {
+	if cm.Status.Phase == phase && cm.Status.LastError == "" && cm.Status.LastTransitionTime != nil {
+		observePhase(cm)
+		return nil
+	}
+
+	now := metav1.Now()
+
+	if cm.Status.Phase != phase || cm.Status.LastTransitionTime == nil {
+		cm.Status.LastTransitionTime = &now
+	}
+	if cm.Status.StartedAt == nil && phase != v1alpha1.ClusterMigrationPhasePending {
+		cm.Status.StartedAt = &now
+	}
+	if cm.Status.CompletedAt == nil && phase == v1alpha1.ClusterMigrationPhaseCompleted {
+		cm.Status.CompletedAt = &now
+	}
+
+	if phase == v1alpha1.ClusterMigrationPhaseValidating || phase == v1alpha1.ClusterMigrationPhasePreparing {
+		switch cm.Status.EffectivePhase() {
+		case v1alpha1.ClusterMigrationPhasePending, v1alpha1.ClusterMigrationPhaseRolledBack:
+			cm.Status.Attempt++
+		}
+	}
+
+	cm.Status.Phase = phase
+	cm.Status.FailedPhase = ""
+	cm.Status.LastError = ""
+
+	err := c.Status().Update(ctx, cm)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observePhase(cm)
+
+	return nil
+}
+
+// SetFailed moves given ClusterMigration to Failed phase remembering the
+// phase in which the failure happened and the error message. Errors matching
+// IsWaiting are not failures and leave the ClusterMigration untouched.
+func SetFailed(ctx context.Context, c ctrl.Client, cm *v1alpha1.ClusterMigration, cause error) error {
+	if IsWaiting(cause) {
+		return nil
+	}
+
+	if cm.Status.Phase != v1alpha1.ClusterMigrationPhaseFailed {
+		now := metav1.Now()
+		cm.Status.FailedPhase = cm.Status.EffectivePhase()
+		cm.Status.LastTransitionTime = &now
+	}
+
+	cm.Status.Phase = v1alpha1.ClusterMigrationPhaseFailed
+	cm.Status.LastError = microerror.Cause(cause).Error()
+
+	err := c.Status().Update(ctx, cm)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	observePhase(cm)
+	observeError(cm, cause)
+
+	return nil
+}
+
+// migrationStatus is used by migrators to read and write ClusterMigration CR
+// of the cluster they migrate. In dry run status writes are dropped, so
+// phases are not changed and not exposed as metrics.
+type migrationStatus struct {
+	client ctrl.Client
+	dryRun bool
+	key    ctrl.ObjectKey
+}
+
+func newMigrationStatus(c ctrl.Client, cluster *capi.Cluster, dryRun bool) *migrationStatus {
+	return &migrationStatus{
+		client: c,
+		dryRun: dryRun,
+		key:    ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name},
+	}
+}
+
+func (s *migrationStatus) get(ctx context.Context) (*v1alpha1.ClusterMigration, error) {
+	cm := &v1alpha1.ClusterMigration{}
+	err := s.client.Get(ctx, s.key, cm)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return cm, nil
+}
+
+func (s *migrationStatus) phase(ctx context.Context) (v1alpha1.ClusterMigrationPhase, error) {
+	cm, err := s.get(ctx)
+	if apierrors.IsNotFound(microerror.Cause(err)) {
+		return v1alpha1.ClusterMigrationPhasePending, nil
+	} else if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return cm.Status.EffectivePhase(), nil
+}
+
+func (s *migrationStatus) attempt(ctx context.Context) (int, error) {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return 0, microerror.Mask(err)
+	}
+
+	return cm.Status.Attempt, nil
+}
+
+func (s *migrationStatus) setPhase(ctx context.Context, phase v1alpha1.ClusterMigrationPhase) error {
+	if s.dryRun {
+		return nil
+	}
+
+	cm, err := s.get(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = SetPhase(ctx, s.client, cm, phase)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
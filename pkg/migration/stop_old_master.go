@@ -32,62 +32,20 @@ func (m *azureMigrator) stopOldMasterComponents(ctx context.Context) error {
 		if errors.IsNotFound(err) {
 			m.logger.Debugf(ctx, "creating pod for node %s", podName)
 
-			// Create pod definition.
-			var pod corev1.Pod
-			{
-				command := `
+			command := `
 ([ -f /host/etc/kubernetes/manifests/k8s-controller-manager.yaml ] && mv /host/etc/kubernetes/manifests/k8s-controller-manager.yaml /host/root/) || true ;
 ([ -f /host/etc/kubernetes/manifests/k8s-api-server.yaml ] && mv /host/etc/kubernetes/manifests/k8s-api-server.yaml /host/root/) || true
 `
-
-				pod = corev1.Pod{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      podName,
-						Namespace: podNamespace,
-					},
-					Spec: corev1.PodSpec{
-						Volumes: []corev1.Volume{
-							{
-								Name: "host",
-								VolumeSource: corev1.VolumeSource{
-									HostPath: &corev1.HostPathVolumeSource{
-										Path: "/",
-									},
-								},
-							},
-						},
-						Containers: []corev1.Container{
-							{
-								Name:  "disable-master-node-components",
-								Image: "alpine:latest",
-								Command: []string{
-									"ash",
-									"-c",
-									command,
-								},
-								VolumeMounts: []corev1.VolumeMount{
-									{
-										Name:      "host",
-										ReadOnly:  false,
-										MountPath: "/host",
-									},
-								},
-							},
-						},
-						NodeName:           nodeName,
-						RestartPolicy:      corev1.RestartPolicyNever,
-						ServiceAccountName: serviceAccountName,
-					},
-				}
-			}
+			pod := newHostCommandPod(podName, podNamespace, nodeName, serviceAccountName, "disable-master-node-components", command)
 
 			// Create pod.
-			err = m.wcCtrlClient.Create(ctx, &pod)
+			err = m.wcCtrlClient.Create(ctx, pod)
 			if err != nil {
 				return microerror.Mask(err)
 			}
 
 			m.logger.Debugf(ctx, "created pod for node %s", podName)
+			m.events.normalf(EventReasonLegacyComponentsStopped, "legacy master components stopped on node %s", nodeName)
 			return nil
 		} else if err != nil {
 			return microerror.Mask(err)
@@ -99,6 +57,62 @@ func (m *azureMigrator) stopOldMasterComponents(ctx context.Context) error {
 	return nil
 }
 
+// startOldMasterComponents moves API server and controller manager manifests
+// parked by stopOldMasterComponents back in place on legacy master nodes.
+func (m *azureMigrator) startOldMasterComponents(ctx context.Context) error {
+	nodeNames, err := m.getLegacyMasterNodeNames(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	serviceAccountName := "kube-proxy"
+	podNamespace := "kube-system"
+	m.logger.Debugf(ctx, "found %d legacy nodes", len(nodeNames))
+
+	for _, nodeName := range nodeNames {
+		// Remove pod which disabled the components, so that it is not
+		// confused with the current state of the node.
+		disablePod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("disable-master-node-components-%s", nodeName),
+				Namespace: podNamespace,
+			},
+		}
+		err = deleteIfExists(ctx, m.wcCtrlClient, disablePod)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		podName := fmt.Sprintf("enable-master-node-components-%s", nodeName)
+
+		existing := corev1.Pod{}
+		err = m.wcCtrlClient.Get(ctx, client.ObjectKey{Name: podName, Namespace: podNamespace}, &existing)
+		if errors.IsNotFound(err) {
+			m.logger.Debugf(ctx, "creating pod for node %s", podName)
+
+			command := `
+([ -f /host/root/k8s-controller-manager.yaml ] && mv /host/root/k8s-controller-manager.yaml /host/etc/kubernetes/manifests/) || true ;
+([ -f /host/root/k8s-api-server.yaml ] && mv /host/root/k8s-api-server.yaml /host/etc/kubernetes/manifests/) || true
+`
+			pod := newHostCommandPod(podName, podNamespace, nodeName, serviceAccountName, "enable-master-node-components", command)
+
+			err = m.wcCtrlClient.Create(ctx, pod)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			m.logger.Debugf(ctx, "created pod for node %s", podName)
+			m.events.normalf(EventReasonLegacyComponentsStarted, "legacy master components started on node %s", nodeName)
+		} else if err != nil {
+			return microerror.Mask(err)
+		} else {
+			m.logger.Debugf(ctx, "pod for node %s was already found", podName)
+		}
+	}
+
+	return nil
+}
+
 func (m *azureMigrator) getLegacyMasterNodeNames(ctx context.Context) ([]string, error) {
 	nodeList := corev1.NodeList{}
 	err := m.wcCtrlClient.List(ctx, &nodeList, client.MatchingLabels{"role": "master"})
@@ -114,3 +128,47 @@ func (m *azureMigrator) getLegacyMasterNodeNames(ctx context.Context) ([]string,
 
 	return ret, nil
 }
+
+// newHostCommandPod returns a pod which runs given shell command on the given
+// node with host root file system mounted under /host.
+func newHostCommandPod(name, namespace, nodeName, serviceAccountName, containerName, command string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "host",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{
+							Path: "/",
+						},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:  containerName,
+					Image: "alpine:latest",
+					Command: []string{
+						"ash",
+						"-c",
+						command,
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "host",
+							ReadOnly:  false,
+							MountPath: "/host",
+						},
+					},
+				},
+			},
+			NodeName:           nodeName,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: serviceAccountName,
+		},
+	}
+}
@@ -1,6 +1,176 @@
 package migration
 
-import "embed"
+import (
+	"bytes"
+	"context"
+	"embed"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	templatesDir    = "templates"
+	templatesSuffix = ".yaml.tmpl"
+)
 
 //go:embed templates/*
 var templatesFS embed.FS
+
+// templateFuncs are available in all templates. They allow to render Go
+// values shared between providers, e.g. bootstrap files, as YAML.
+var templateFuncs = template.FuncMap{
+	"indent": indent,
+	"toYaml": toYaml,
+}
+
+// templateRenderer renders CR templates. Templates are embedded in the
+// binary and can be overridden at runtime by keys of a ConfigMap named after
+// the template file, e.g. "kubeadm_controlplane_aws.yaml.tmpl". Nil renderer
+// renders embedded templates only.
+type templateRenderer struct {
+	overrides map[string]string
+}
+
+// ParseTemplatesConfigMapKey parses "namespace/name" of the ConfigMap
+// overriding embedded templates. Empty value means there is no ConfigMap.
+func ParseTemplatesConfigMapKey(value string) (ctrl.ObjectKey, error) {
+	return parseConfigMapKey("templates", value)
+}
+
+// loadTemplateRenderer reads template overrides from the ConfigMap given by
+// key. Every key of the ConfigMap must name an embedded template and hold a
+// template which parses, so that typos are reported before migration starts
+// and not in the middle of it. Empty key means there is no ConfigMap.
+func loadTemplateRenderer(ctx context.Context, c ctrl.Client, key ctrl.ObjectKey) (*templateRenderer, error) {
+	r := &templateRenderer{}
+
+	if key.Name == "" {
+		return r, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, key, cm)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	names, err := templateNames()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	r.overrides = map[string]string{}
+	for name, text := range cm.Data {
+		if !containsString(names, name) {
+			return nil, microerror.Maskf(invalidConfigError, "ConfigMap %s/%s key %#q must be one of %s", key.Namespace, key.Name, name, strings.Join(names, ", "))
+		}
+
+		_, err = template.New(name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "ConfigMap %s/%s: %s", key.Namespace, key.Name, err)
+		}
+
+		r.overrides[name] = text
+	}
+
+	return r, nil
+}
+
+// templateNames returns sorted file names of all embedded templates.
+func templateNames() ([]string, error) {
+	entries, err := fs.ReadDir(templatesFS, templatesDir)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), templatesSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// render executes the template with given file name and decodes the
+// resulting YAML into obj. Unstructured objects are decoded through their own
+// JSON decoder, so that integers are kept as integers.
+func (r *templateRenderer) render(name string, params interface{}, obj interface{}) error {
+	var tmpl *template.Template
+	var err error
+	if text, ok := r.override(name); ok {
+		tmpl, err = template.New(name).Funcs(templateFuncs).Parse(text)
+	} else {
+		tmpl, err = template.New(name).Funcs(templateFuncs).ParseFS(templatesFS, path.Join(templatesDir, name))
+	}
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Missing keys of map parameters are errors, the same way missing fields
+	// of struct parameters are.
+	tmpl.Option("missingkey=error")
+
+	buf := bytes.NewBuffer(nil)
+	err = tmpl.Execute(buf, params)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		data, err := yaml.YAMLToJSON(buf.Bytes())
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = u.UnmarshalJSON(data)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	err = yaml.Unmarshal(buf.Bytes(), obj)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *templateRenderer) override(name string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	text, ok := r.overrides[name]
+	return text, ok
+}
+
+// indent prefixes every line of s with given number of spaces.
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// toYaml marshals v to YAML without the trailing newline.
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
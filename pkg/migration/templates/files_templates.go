@@ -23,7 +23,7 @@ func RenderTemplate(tmpl string, params interface{}) (string, error) {
 	return buff.String(), nil
 }
 
-const AWSJoinCluster = `#!/bin/sh
+const JoinEtcdCluster = `#!/bin/sh
 # get ETCDCTL
 DOWNLOAD_URL=https://github.com/etcd-io/etcd/releases/download
 ETCD_VER=v3.4.13
@@ -0,0 +1,158 @@
+package migration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capa "sigs.k8s.io/cluster-api-provider-aws/api/v1alpha3"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadm "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_loadTemplateRenderer(t *testing.T) {
+	override := `apiVersion: infrastructure.cluster.x-k8s.io/v1alpha3
+kind: AWSMachineTemplate
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  template:
+    spec:
+      instanceType: m5.2xlarge
+`
+
+	testCases := []struct {
+		name                 string
+		data                 map[string]string
+		expectedInstanceType string
+		errorMatcher         func(error) bool
+	}{
+		{
+			name:                 "case 0: embedded template is rendered without overrides",
+			data:                 map[string]string{},
+			expectedInstanceType: "m5.xlarge",
+		},
+		{
+			name:                 "case 1: ConfigMap overrides embedded template",
+			data:                 map[string]string{"controlplane_aws_machine_template.yaml.tmpl": override},
+			expectedInstanceType: "m5.2xlarge",
+		},
+		{
+			name:         "case 2: unknown template is rejected",
+			data:         map[string]string{"controlplane_aws_machine_templates.yaml.tmpl": override},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			name:         "case 3: invalid template is rejected",
+			data:         map[string]string{"controlplane_aws_machine_template.yaml.tmpl": "name: {{.Name"},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+
+			key := ctrl.ObjectKey{Namespace: "giantswarm", Name: "capi-migration-templates"}
+			c := fake.NewFakeClientWithScheme(scheme, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Data:       tc.data,
+			})
+
+			r, err := loadTemplateRenderer(context.Background(), c, key)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			params := awsMachineTemplateParams{
+				Name:            "abc12-control-plane",
+				Namespace:       "default",
+				InstanceType:    "m5.xlarge",
+				SecurityGroupID: "sg-1",
+			}
+
+			obj := &capa.AWSMachineTemplate{}
+			err = r.render("controlplane_aws_machine_template.yaml.tmpl", params, obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if obj.Spec.Template.Spec.InstanceType != tc.expectedInstanceType {
+				t.Fatalf("expected instance type %q, got %q", tc.expectedInstanceType, obj.Spec.Template.Spec.InstanceType)
+			}
+		})
+	}
+}
+
+func Test_templateRenderer_AWSKubeadmControlPlane(t *testing.T) {
+	users := []cabpkv1.User{{Name: "jane", SSHAuthorizedKeys: []string{"ssh-rsa AAAA jane@example"}}}
+	files := controlPlaneMigrationFiles("abc12")
+
+	params := awsKubeadmControlPlaneParams{
+		Name:                "abc12-control-plane",
+		Namespace:           "default",
+		MachineTemplateName: "abc12-control-plane",
+		APIEndpoint:         "api.abc12.k8s.example.com",
+		K8sVersion:          kubernetesVersion("1.19.9"),
+		Files:               files,
+		Users:               users,
+	}
+
+	kcp := &kubeadm.KubeadmControlPlane{}
+	err := (&templateRenderer{}).render("kubeadm_controlplane_aws.yaml.tmpl", params, kcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	spec := kcp.Spec.KubeadmConfigSpec
+	if kcp.Spec.Version != "v1.19.9" {
+		t.Fatalf("expected version %q, got %q", "v1.19.9", kcp.Spec.Version)
+	}
+	if name := spec.JoinConfiguration.NodeRegistration.Name; name != "{{ ds.meta_data.local_hostname }}" {
+		t.Fatalf("expected node name to be left for cloud-init, got %q", name)
+	}
+	if !reflect.DeepEqual(spec.Files, files) {
+		t.Fatalf("expected files %#v, got %#v", files, spec.Files)
+	}
+	if !reflect.DeepEqual(spec.Users, users) {
+		t.Fatalf("expected users %#v, got %#v", users, spec.Users)
+	}
+}
+
+func Test_templateRenderer_AWSMachineTemplateSSHKeyName(t *testing.T) {
+	obj := &capa.AWSMachineTemplate{}
+	err := (&templateRenderer{}).render("controlplane_aws_machine_template.yaml.tmpl", awsMachineTemplateParams{
+		Name:            "abc12-control-plane",
+		Namespace:       "default",
+		InstanceType:    "m5.xlarge",
+		SecurityGroupID: "sg-1",
+	}, obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// CAPA falls back to its default key pair when the key name is nil.
+	name := obj.Spec.Template.Spec.SSHKeyName
+	if name == nil || *name != "" {
+		t.Fatalf("expected empty key pair name, got %#v", name)
+	}
+}
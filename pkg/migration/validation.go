@@ -0,0 +1,185 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	"github.com/giantswarm/microerror"
+	vaultclient "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+	"github.com/giantswarm/capi-migration/pkg/migration/internal/key"
+)
+
+const (
+	checkRelease         = "Release"
+	checkSecrets         = "Secrets"
+	checkCertificates    = "Certificates"
+	checkVault           = "Vault"
+	checkInfrastructure  = "Infrastructure"
+	checkWorkloadCluster = "WorkloadCluster"
+)
+
+// requiredReleaseComponents lists release components all providers read when
+// rendering CRs and handing them over to upstream controllers. The
+// infrastructure provider component is required in addition.
+var requiredReleaseComponents = []string{
+	"cluster-api-bootstrap-provider-kubeadm",
+	"cluster-api-control-plane",
+	"cluster-api-core",
+	"cluster-operator",
+	"etcd",
+	"kubernetes",
+}
+
+// minKubernetesVersion is the oldest Kubernetes version the KubeadmControlPlane
+// created during migration can join.
+var minKubernetesVersion = version.MustParseGeneric("1.19.0")
+
+// validationFindings aggregates results of pre-flight checks.
+type validationFindings struct {
+	findings []v1alpha1.ClusterMigrationFinding
+}
+
+func (f *validationFindings) blocking(check string, format string, args ...interface{}) {
+	f.add(v1alpha1.ClusterMigrationFindingSeverityBlocking, check, format, args...)
+}
+
+func (f *validationFindings) warning(check string, format string, args ...interface{}) {
+	f.add(v1alpha1.ClusterMigrationFindingSeverityWarning, check, format, args...)
+}
+
+func (f *validationFindings) add(severity v1alpha1.ClusterMigrationFindingSeverity, check string, format string, args ...interface{}) {
+	f.findings = append(f.findings, v1alpha1.ClusterMigrationFinding{
+		Severity: severity,
+		Check:    check,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (f *validationFindings) blockingMessages() []string {
+	var messages []string
+	for _, finding := range f.findings {
+		if finding.Severity == v1alpha1.ClusterMigrationFindingSeverityBlocking {
+			messages = append(messages, fmt.Sprintf("%s: %s", finding.Check, finding.Message))
+		}
+	}
+
+	return messages
+}
+
+// record stores findings in the ClusterMigration CR and returns
+// validationFailedError when any of them is blocking.
+func (f *validationFindings) record(ctx context.Context, status *migrationStatus) error {
+	cm, err := status.get(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	now := metav1.Now()
+	cm.Status.Findings = f.findings
+	cm.Status.ValidatedAt = &now
+
+	err = status.client.Status().Update(ctx, cm)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	blocking := f.blockingMessages()
+	if len(blocking) > 0 {
+		return microerror.Maskf(validationFailedError, "%d blocking findings: %s", len(blocking), strings.Join(blocking, "; "))
+	}
+
+	return nil
+}
+
+// validateMigration runs pre-flight checks shared by all providers without
+// modifying any resources. Failing checks are collected as findings rather
+// than returned as errors, so that all problems are reported at once.
+// Migrators read the Cluster and its Release before and run provider
+// specific checks afterwards.
+func validateMigration(ctx context.Context, f *validationFindings, mcCtrlClient, wcCtrlClient ctrl.Client, certs *certsMigrator, cluster *capi.Cluster, r *release.Release, infrastructureComponent string) {
+	validateRelease(f, r, infrastructureComponent)
+
+	validateSecrets(ctx, f, mcCtrlClient, []ctrl.ObjectKey{
+		{Namespace: "default", Name: key.EncryptionKeySecretName(cluster.Name)},
+	})
+
+	certs.validate(ctx, f, cluster)
+	validateWorkloadCluster(ctx, f, wcCtrlClient)
+}
+
+// validateRelease checks that the release exists, is not deprecated and
+// contains all components needed to render upstream CRs and hand them over
+// to upstream controllers, including the given infrastructure provider
+// component.
+func validateRelease(f *validationFindings, r *release.Release, infrastructureComponent string) {
+	if r == nil {
+		return
+	}
+
+	switch r.Spec.State {
+	case release.StateDeprecated:
+		f.warning(checkRelease, "release %q is deprecated", r.Name)
+	case release.StateWIP:
+		f.warning(checkRelease, "release %q is work in progress", r.Name)
+	}
+
+	components := getReleaseComponents(r)
+	for _, c := range append(requiredReleaseComponents, infrastructureComponent) {
+		if components[c] == "" {
+			f.blocking(checkRelease, "release %q has no %q component", r.Name, c)
+		}
+	}
+
+	k8sVersion, ok := components["kubernetes"]
+	if !ok {
+		return
+	}
+
+	v, err := version.ParseGeneric(k8sVersion)
+	if err != nil {
+		f.blocking(checkRelease, "release %q has invalid kubernetes version %q", r.Name, k8sVersion)
+	} else if v.LessThan(minKubernetesVersion) {
+		f.blocking(checkRelease, "release %q has kubernetes version %s, at least %s is supported", r.Name, k8sVersion, minKubernetesVersion)
+	}
+}
+
+// validateSecrets checks that given secrets exist in the management cluster.
+func validateSecrets(ctx context.Context, f *validationFindings, c ctrl.Client, keys []ctrl.ObjectKey) {
+	for _, k := range keys {
+		err := c.Get(ctx, k, &corev1.Secret{})
+		if err != nil {
+			f.blocking(checkSecrets, "failed to get Secret %s: %s", k, microerror.Cause(err))
+		}
+	}
+}
+
+// validateWorkloadCluster checks that the workload cluster API answers.
+func validateWorkloadCluster(ctx context.Context, f *validationFindings, c ctrl.Client) {
+	err := c.List(ctx, &corev1.NodeList{}, ctrl.Limit(1))
+	if err != nil {
+		f.blocking(checkWorkloadCluster, "workload cluster API is not reachable: %s", microerror.Cause(err))
+	}
+}
+
+// validateVault checks that Vault is healthy. Reading the CA is checked
+// by certsMigrator.validate.
+func validateVault(f *validationFindings, vaultClient *vaultclient.Client) {
+	health, err := vaultClient.Sys().Health()
+	if err != nil {
+		f.blocking(checkVault, "Vault is not reachable: %s", err)
+		return
+	}
+	if health.Sealed {
+		f.blocking(checkVault, "Vault is sealed")
+		return
+	}
+}
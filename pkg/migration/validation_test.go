@@ -0,0 +1,162 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	release "github.com/giantswarm/apiextensions/v3/pkg/apis/release/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/capi-migration/api/v1alpha1"
+)
+
+func Test_validateRelease(t *testing.T) {
+	components := func(overrides map[string]string) map[string]string {
+		c := map[string]string{
+			"cluster-api-bootstrap-provider-kubeadm": "0.3.14",
+			"cluster-api-control-plane":              "0.3.14",
+			"cluster-api-core":                       "0.3.13",
+			"cluster-api-provider-aws":               "0.6.5",
+			"cluster-operator":                       "3.6.0",
+			"etcd":                                   "3.4.14",
+			"kubernetes":                             "1.19.9",
+		}
+		for k, v := range overrides {
+			if v == "" {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name       string
+		state      release.ReleaseState
+		components map[string]string
+		blocking   int
+		warnings   int
+	}{
+		{
+			name:       "case 0: active release with all components",
+			state:      release.StateActive,
+			components: components(nil),
+		},
+		{
+			name:       "case 1: deprecated release",
+			state:      release.StateDeprecated,
+			components: components(nil),
+			warnings:   1,
+		},
+		{
+			name:       "case 2: missing etcd component",
+			state:      release.StateActive,
+			components: components(map[string]string{"etcd": ""}),
+			blocking:   1,
+		},
+		{
+			name:       "case 3: unsupported kubernetes version",
+			state:      release.StateActive,
+			components: components(map[string]string{"kubernetes": "1.18.15"}),
+			blocking:   1,
+		},
+		{
+			name:       "case 4: missing components read during trigger",
+			state:      release.StateActive,
+			components: components(map[string]string{"cluster-api-core": "", "cluster-operator": ""}),
+			blocking:   2,
+		},
+		{
+			name:       "case 5: missing infrastructure provider component",
+			state:      release.StateActive,
+			components: components(map[string]string{"cluster-api-provider-aws": ""}),
+			blocking:   1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &release.Release{
+				ObjectMeta: metav1.ObjectMeta{Name: "v14.0.0"},
+				Spec:       release.ReleaseSpec{State: tc.state},
+			}
+			for name, version := range tc.components {
+				r.Spec.Components = append(r.Spec.Components, release.ReleaseSpecComponent{Name: name, Version: version})
+			}
+
+			f := &validationFindings{}
+			validateRelease(f, r, awsInfrastructureComponent)
+
+			var blocking, warnings int
+			for _, finding := range f.findings {
+				switch finding.Severity {
+				case v1alpha1.ClusterMigrationFindingSeverityBlocking:
+					blocking++
+				case v1alpha1.ClusterMigrationFindingSeverityWarning:
+					warnings++
+				}
+			}
+
+			if blocking != tc.blocking {
+				t.Fatalf("expected %d blocking findings, got %d: %v", tc.blocking, blocking, f.findings)
+			}
+			if warnings != tc.warnings {
+				t.Fatalf("expected %d warnings, got %d: %v", tc.warnings, warnings, f.findings)
+			}
+		})
+	}
+}
+
+func Test_validationFindings_record(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = capi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	cluster := newTestCluster(false)
+	cm := &v1alpha1.ClusterMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, cluster, cm)
+	status := newMigrationStatus(c, cluster, false)
+
+	f := &validationFindings{}
+	f.warning(checkRelease, "release is deprecated")
+
+	err := f.record(ctx, status)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	f.blocking(checkSecrets, "secret not found")
+
+	err = f.record(ctx, status)
+	if !IsValidationFailed(err) {
+		t.Fatalf("expected validationFailedError, got %#v", err)
+	}
+
+	recorded := &v1alpha1.ClusterMigration{}
+	err = c.Get(ctx, ctrl.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}, recorded)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(recorded.Status.Findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", recorded.Status.Findings)
+	}
+	if recorded.Status.ValidatedAt == nil {
+		t.Fatalf("expected ValidatedAt to be set")
+	}
+}
@@ -0,0 +1,30 @@
+package vault
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = &microerror.Error{
+	Kind: "invalidConfigError",
+}
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var loginFailedError = &microerror.Error{
+	Kind: "loginFailedError",
+}
+
+// IsLoginFailed asserts loginFailedError.
+func IsLoginFailed(err error) bool {
+	return microerror.Cause(err) == loginFailedError
+}
+
+var unhealthyError = &microerror.Error{
+	Kind: "unhealthyError",
+}
+
+// IsUnhealthy asserts unhealthyError.
+func IsUnhealthy(err error) bool {
+	return microerror.Cause(err) == unhealthyError
+}
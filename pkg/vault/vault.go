@@ -0,0 +1,262 @@
+// Package vault provides the Vault client shared by all migrators. It logs in
+// with the Kubernetes auth method or a static token and keeps the token valid
+// for the lifetime of the process.
+package vault
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	// DefaultKubernetesAuthPath is the mount path of the Kubernetes auth
+	// method used when Config.KubernetesAuthPath is empty.
+	DefaultKubernetesAuthPath = "kubernetes"
+	// DefaultServiceAccountTokenPath is where the service account token is
+	// mounted in pods.
+	DefaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token" // nolint:gosec
+
+	// loginRetryInterval is how long Start waits before logging in again
+	// after the token couldn't be renewed.
+	loginRetryInterval = 30 * time.Second
+)
+
+type Config struct {
+	Logger micrologger.Logger
+
+	// Address of the Vault server.
+	Address string
+
+	// KubernetesRole enables the Kubernetes auth method. The client logs in
+	// with this role and the service account token read from
+	// ServiceAccountTokenPath, which defaults to
+	// DefaultServiceAccountTokenPath. KubernetesAuthPath is the mount path of
+	// the auth method and defaults to DefaultKubernetesAuthPath.
+	KubernetesRole          string
+	KubernetesAuthPath      string
+	ServiceAccountTokenPath string
+
+	// Token is a static token used when KubernetesRole is empty.
+	Token string
+}
+
+// Client is a Vault API client which renews its token in the background when
+// started with Start. API returns the same underlying client at all times, so
+// it can be shared by components created before a token was renewed.
+type Client struct {
+	api    *vaultapi.Client
+	config Config
+	logger micrologger.Logger
+}
+
+// New creates a client and logs in to Vault. It fails when Vault is not
+// reachable or the credentials are rejected.
+func New(config Config) (*Client, error) {
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+	if config.Address == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.Address must not be empty", config)
+	}
+	if config.KubernetesRole == "" && config.Token == "" {
+		return nil, microerror.Maskf(invalidConfigError, "%T.KubernetesRole or %T.Token must not be empty", config, config)
+	}
+
+	if config.KubernetesAuthPath == "" {
+		config.KubernetesAuthPath = DefaultKubernetesAuthPath
+	}
+	if config.ServiceAccountTokenPath == "" {
+		config.ServiceAccountTokenPath = DefaultServiceAccountTokenPath
+	}
+
+	apiConfig := vaultapi.DefaultConfig()
+	apiConfig.Address = config.Address
+	api, err := vaultapi.NewClient(apiConfig)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	c := &Client{
+		api:    api,
+		config: config,
+		logger: config.Logger,
+	}
+
+	_, err = c.login()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return c, nil
+}
+
+// API returns the Vault API client authenticated with the current token.
+func (c *Client) API() *vaultapi.Client {
+	return c.api
+}
+
+// Healthz checks that Vault is initialized, unsealed and accepts the current
+// token. Its signature matches healthz.Checker.
+func (c *Client) Healthz(_ *http.Request) error {
+	health, err := c.api.Sys().Health()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !health.Initialized {
+		return microerror.Maskf(unhealthyError, "vault at %q is not initialized", c.config.Address)
+	}
+	if health.Sealed {
+		return microerror.Maskf(unhealthyError, "vault at %q is sealed", c.config.Address)
+	}
+
+	_, err = c.api.Auth().Token().LookupSelf()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The token is
+// renewed on all replicas as the client is used by health checks as well.
+func (c *Client) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the token until stop is closed. When the token can't be
+// renewed anymore, e.g. because it reached its max TTL, the client logs in
+// again. Static tokens can't be replaced, so they are only looked up again
+// to notice when they were renewed externally. It implements
+// manager.Runnable.
+func (c *Client) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		auth, err := c.login()
+		if err != nil {
+			c.logger.Errorf(ctx, err, "failed to log in to vault, retrying in %s", loginRetryInterval)
+		} else {
+			err = c.renew(ctx, auth)
+			if err != nil {
+				c.logger.Errorf(ctx, err, "failed to renew vault token")
+			}
+		}
+
+		// Log in again right away when the Kubernetes auth method can
+		// issue a new token, otherwise back off.
+		wait := loginRetryInterval
+		if err == nil && c.config.KubernetesRole != "" {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// login authenticates with the configured method, sets the resulting token
+// on the API client and returns its auth information for renewal.
+func (c *Client) login() (*vaultapi.Secret, error) {
+	if c.config.KubernetesRole == "" {
+		c.api.SetToken(c.config.Token)
+
+		// Static tokens are looked up to learn if and for how long they
+		// can be renewed.
+		secret, err := c.api.Auth().Token().LookupSelf()
+		if err != nil {
+			return nil, microerror.Maskf(loginFailedError, "failed to look up vault token: %s", err)
+		}
+		renewable, err := secret.TokenIsRenewable()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		ttl, err := secret.TokenTTL()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return &vaultapi.Secret{
+			Auth: &vaultapi.SecretAuth{
+				ClientToken:   c.config.Token,
+				Renewable:     renewable,
+				LeaseDuration: int(ttl.Seconds()),
+			},
+		}, nil
+	}
+
+	jwt, err := os.ReadFile(c.config.ServiceAccountTokenPath)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	path := "auth/" + strings.Trim(c.config.KubernetesAuthPath, "/") + "/login"
+	secret, err := c.api.Logical().Write(path, map[string]interface{}{
+		"role": c.config.KubernetesRole,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return nil, microerror.Maskf(loginFailedError, "failed to log in at %q with role %q: %s", path, c.config.KubernetesRole, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, microerror.Maskf(loginFailedError, "vault returned no token at %q", path)
+	}
+
+	c.api.SetToken(secret.Auth.ClientToken)
+
+	return secret, nil
+}
+
+// renew keeps the token of auth valid until it can't be renewed anymore or ctx
+// is done. Tokens without TTL never expire and are not renewed.
+func (c *Client) renew(ctx context.Context, auth *vaultapi.Secret) error {
+	if !auth.Auth.Renewable {
+		if auth.Auth.LeaseDuration == 0 {
+			<-ctx.Done()
+			return nil
+		}
+
+		// Log in again before the token expires.
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(auth.Auth.LeaseDuration) * time.Second * 2 / 3):
+		}
+		return nil
+	}
+
+	renewer, err := c.api.NewRenewer(&vaultapi.RenewerInput{Secret: auth})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				return microerror.Mask(err)
+			}
+			c.logger.Debugf(ctx, "vault token reached its max TTL")
+			return nil
+		case o := <-renewer.RenewCh():
+			c.logger.Debugf(ctx, "renewed vault token at %s", o.RenewedAt)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giantswarm/micrologger"
+)
+
+// newFakeVault accepts the "migration" role with the "sa-token" service
+// account token and the static "static" token.
+func newFakeVault(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var req map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req["role"] != "migration" || req["jwt"] != "sa-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			body = map[string]interface{}{"auth": map[string]interface{}{"client_token": "issued", "renewable": true, "lease_duration": 3600}}
+		case "/v1/auth/token/lookup-self":
+			token := r.Header.Get("X-Vault-Token")
+			if token != "static" && token != "issued" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			body = map[string]interface{}{"data": map[string]interface{}{"ttl": 3600, "renewable": true}}
+		case "/v1/sys/health":
+			body = map[string]interface{}{"initialized": true, "sealed": false}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func Test_New(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        Config
+		expectedToken string
+		errorMatcher  func(error) bool
+	}{
+		{
+			name:          "case 0: log in with Kubernetes auth method",
+			config:        Config{KubernetesRole: "migration"},
+			expectedToken: "issued",
+		},
+		{
+			name:          "case 1: role takes precedence over static token",
+			config:        Config{KubernetesRole: "migration", Token: "static"},
+			expectedToken: "issued",
+		},
+		{
+			name:          "case 2: fall back to static token",
+			config:        Config{Token: "static"},
+			expectedToken: "static",
+		},
+		{
+			name:         "case 3: unknown role is rejected",
+			config:       Config{KubernetesRole: "other"},
+			errorMatcher: IsLoginFailed,
+		},
+		{
+			name:         "case 4: invalid static token is rejected",
+			config:       Config{Token: "invalid"},
+			errorMatcher: IsLoginFailed,
+		},
+		{
+			name:         "case 5: missing credentials",
+			config:       Config{},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeVault(t)
+			defer server.Close()
+
+			logger, err := micrologger.New(micrologger.Config{})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			saTokenPath := filepath.Join(t.TempDir(), "token")
+			err = os.WriteFile(saTokenPath, []byte("sa-token\n"), 0600)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			config := tc.config
+			config.Logger = logger
+			config.Address = server.URL
+			config.ServiceAccountTokenPath = saTokenPath
+
+			c, err := New(config)
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("unexpected error: %#v", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("expected error, got nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+
+			if c.API().Token() != tc.expectedToken {
+				t.Fatalf("expected token %q, got %q", tc.expectedToken, c.API().Token())
+			}
+
+			err = c.Healthz(nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		})
+	}
+}